@@ -19,7 +19,6 @@ import (
 	"github.com/openshift/installer-aro-wrapper/pkg/cluster/graph"
 	"github.com/openshift/installer-aro-wrapper/pkg/env"
 	"github.com/openshift/installer-aro-wrapper/pkg/installer"
-	"github.com/openshift/installer-aro-wrapper/pkg/util/azureclient/mgmt/features"
 	"github.com/openshift/installer-aro-wrapper/pkg/util/encryption"
 	"github.com/openshift/installer-aro-wrapper/pkg/util/refreshable"
 	"github.com/openshift/installer-aro-wrapper/pkg/util/storage"
@@ -179,7 +178,6 @@ func _makeInstaller(ctx context.Context, log *logrus.Entry) (installer.Interface
 	}
 
 	storage := storage.NewManager(_env, r.SubscriptionID, fpAuthorizer)
-	deployments := features.NewDeploymentsClient(_env.Environment(), r.SubscriptionID, fpAuthorizer)
 
 	aead, err := encryption.NewMulti(ctx, _env.ServiceKeyvault(), env.EncryptionSecretV2Name, env.EncryptionSecretName)
 	if err != nil {
@@ -189,5 +187,5 @@ func _makeInstaller(ctx context.Context, log *logrus.Entry) (installer.Interface
 	graph := graph.NewManager(log, aead, storage)
 
 	// Generate the installer manifests
-	return installer.NewInstaller(log, _env, os.Getenv("ARO_UUID"), &oc, &sub, fpAuthorizer, deployments, graph), nil
+	return installer.NewInstaller(log, _env, rootOpts.dir, os.Getenv("ARO_UUID"), &oc, &sub, fpAuthorizer, storage, graph)
 }
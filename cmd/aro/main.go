@@ -25,6 +25,7 @@ func main() {
 
 	for _, subCmd := range []*cobra.Command{
 		newCreateCmd(),
+		newValidateCmd(),
 	} {
 		rootCmd.AddCommand(subCmd)
 	}
@@ -0,0 +1,57 @@
+package main
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+	"github.com/openshift/installer-aro-wrapper/pkg/api/validate"
+)
+
+func newValidateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate [FILE...]",
+		Short: "Validates OpenShiftCluster documents",
+		Long:  "Statically validates the given OpenShiftCluster documents, by default /.azure/99_aro.json, without contacting Azure.",
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				args = []string{"/.azure/99_aro.json"}
+			}
+
+			var failed bool
+			for _, path := range args {
+				err := validateFile(path)
+				if err != nil {
+					logrus.Error(errors.Wrap(err, path))
+					failed = true
+				}
+			}
+
+			if failed {
+				logrus.Exit(1)
+			}
+		},
+	}
+}
+
+func validateFile(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var oc api.OpenShiftCluster
+	err = json.Unmarshal(b, &oc)
+	if err != nil {
+		return err
+	}
+
+	return validate.OpenShiftCluster(&oc)
+}
@@ -81,6 +81,8 @@ const (
 	CloudErrorCodeInvalidLinkedVNet                  = "InvalidLinkedVNet"
 	CloudErrorCodeInvalidLinkedRouteTable            = "InvalidLinkedRouteTable"
 	CloudErrorCodeInvalidLinkedDiskEncryptionSet     = "InvalidLinkedDiskEncryptionSet"
+	CloudErrorCodeConflictingClusterResources        = "ConflictingClusterResources"
+	CloudErrorCodeConflictingDenyAssignment          = "ConflictingDenyAssignment"
 	CloudErrorCodeNotFound                           = "NotFound"
 	CloudErrorCodeForbidden                          = "Forbidden"
 	CloudErrorCodeInvalidSubscriptionState           = "InvalidSubscriptionState"
@@ -94,6 +96,10 @@ const (
 	CloudErrorCodeDuplicateDomain                    = "DuplicateDomain"
 	CloudErrorCodeResourceQuotaExceeded              = "ResourceQuotaExceeded"
 	CloudErrorCodeQuotaExceeded                      = "QuotaExceeded"
+	CloudErrorCodeSkuNotAvailable                    = "SkuNotAvailable"
+	CloudErrorCodeAllocationFailed                   = "AllocationFailed"
+	CloudErrorCodeInvalidTemplateDeployment          = "InvalidTemplateDeployment"
+	CloudErrorCodeRequestDisallowedByPolicy          = "RequestDisallowedByPolicy"
 	CloudErrorResourceProviderNotRegistered          = "ResourceProviderNotRegistered"
 )
 
@@ -127,6 +127,9 @@ type OpenShiftClusterProperties struct {
 
 	WorkerProfiles []WorkerProfile `json:"workerProfiles,omitempty"`
 
+	// NodeConfig is applied to the nodes from first boot
+	NodeConfig *NodeConfig `json:"nodeConfig,omitempty"`
+
 	APIServerProfile APIServerProfile `json:"apiserverProfile,omitempty"`
 
 	IngressProfiles []IngressProfile `json:"ingressProfiles,omitempty"`
@@ -397,6 +400,28 @@ type WorkerProfile struct {
 	DiskEncryptionSetID string           `json:"diskEncryptionSetId,omitempty"`
 }
 
+// NodeConfig represents node-level customisations of the masters and workers
+type NodeConfig struct {
+	MissingFields
+
+	Master NodeConfigProfile `json:"master,omitempty"`
+	Worker NodeConfigProfile `json:"worker,omitempty"`
+}
+
+// NodeConfigProfile represents node-level customisations of a machine role
+type NodeConfigProfile struct {
+	MissingFields
+
+	// ChronyServers replace the default NTP servers in chrony.conf
+	ChronyServers []string `json:"chronyServers,omitempty"`
+
+	// KernelArguments are appended to the kernel command line
+	KernelArguments []string `json:"kernelArguments,omitempty"`
+
+	// Sysctls maps sysctl keys, e.g. net.ipv4.tcp_keepalive_time, to values
+	Sysctls map[string]string `json:"sysctls,omitempty"`
+}
+
 // APIServerProfile represents an API server profile
 type APIServerProfile struct {
 	MissingFields
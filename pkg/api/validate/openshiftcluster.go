@@ -0,0 +1,336 @@
+package validate
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/gofrs/uuid"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+)
+
+var (
+	rxDomainName      = regexp.MustCompile(`^(?:[a-z0-9]|[a-z0-9][-a-z0-9]{0,61}[a-z0-9])(?:\.(?:[a-z0-9]|[a-z0-9][-a-z0-9]{0,61}[a-z0-9]))*$`)
+	rxInfraID         = regexp.MustCompile(`^[a-z0-9](?:[-a-z0-9]*[a-z0-9])?$`)
+	rxResourceGroupID = regexp.MustCompile(`(?i)^/subscriptions/[^/]+/resourceGroups/[^/]+$`)
+	rxSubnetID        = regexp.MustCompile(`(?i)^/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft\.Network/virtualNetworks/[^/]+/subnets/[^/]+$`)
+	rxHostname        = regexp.MustCompile(`(?i)^([a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?\.)*[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?$`)
+	rxKernelArgument  = regexp.MustCompile(`^[a-zA-Z0-9_.-]+(=[^\s"']+)?$`)
+	rxSysctlKey       = regexp.MustCompile(`^[a-z0-9_-]+(\.[a-zA-Z0-9_-]+)+$`)
+)
+
+// openShiftClusterValidator collects the problems found in an
+// OpenShiftCluster document
+type openShiftClusterValidator struct {
+	details []api.CloudErrorBody
+}
+
+// OpenShiftCluster checks the fields of the OpenShiftCluster document which
+// the wrapper consumes, so that bad input is rejected up front rather than
+// crashing or failing obscurely part way through an install.  All problems
+// found are returned together as the details of an InvalidParameter
+// CloudError.
+func OpenShiftCluster(oc *api.OpenShiftCluster) error {
+	v := &openShiftClusterValidator{}
+
+	v.validate(oc)
+
+	if len(v.details) == 0 {
+		return nil
+	}
+
+	cloudErr := api.NewCloudError(http.StatusBadRequest, api.CloudErrorCodeInvalidParameter, "", "The provided cluster document is invalid.")
+	cloudErr.Details = v.details
+
+	return cloudErr
+}
+
+func (v *openShiftClusterValidator) invalid(path, format string, a ...interface{}) {
+	v.details = append(v.details, api.CloudErrorBody{
+		Code:    api.CloudErrorCodeInvalidParameter,
+		Target:  path,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+func (v *openShiftClusterValidator) validate(oc *api.OpenShiftCluster) {
+	if _, err := azure.ParseResourceID(oc.ID); err != nil {
+		v.invalid("id", "The provided resource ID '%s' is invalid.", oc.ID)
+	}
+
+	if oc.Location == "" {
+		v.invalid("location", "The provided location is empty.")
+	}
+
+	v.validateProperties("properties", &oc.Properties, oc.UsesWorkloadIdentity())
+}
+
+func (v *openShiftClusterValidator) validateProperties(path string, p *api.OpenShiftClusterProperties, usesWorkloadIdentity bool) {
+	if !rxInfraID.MatchString(p.InfraID) {
+		v.invalid(path+".infraId", "The provided infrastructure ID '%s' is invalid.", p.InfraID)
+	}
+
+	if p.StorageSuffix == "" {
+		v.invalid(path+".storageSuffix", "The provided storage suffix is empty.")
+	}
+
+	if p.ImageRegistryStorageAccountName == "" {
+		v.invalid(path+".imageRegistryStorageAccountName", "The provided image registry storage account name is empty.")
+	}
+
+	if _, err := x509.ParsePKCS1PrivateKey(p.SSHKey); err != nil {
+		v.invalid(path+".sshKey", "The provided SSH key is not a PKCS1 private key.")
+	}
+
+	v.validateClusterProfile(path+".clusterProfile", &p.ClusterProfile)
+
+	if usesWorkloadIdentity {
+		v.validatePlatformWorkloadIdentityProfile(path+".platformWorkloadIdentityProfile", p.PlatformWorkloadIdentityProfile)
+	} else {
+		v.validateServicePrincipalProfile(path+".servicePrincipalProfile", p.ServicePrincipalProfile)
+	}
+
+	v.validateNetworkProfile(path+".networkProfile", &p.NetworkProfile)
+	v.validateMasterProfile(path+".masterProfile", &p.MasterProfile)
+
+	if len(p.WorkerProfiles) == 0 {
+		v.invalid(path+".workerProfiles", "There should be at least one worker profile.")
+	}
+	for i := range p.WorkerProfiles {
+		v.validateWorkerProfile(fmt.Sprintf("%s.workerProfiles[%d]", path, i), &p.WorkerProfiles[i])
+	}
+
+	if p.NodeConfig != nil {
+		v.validateNodeConfigProfile(path+".nodeConfig.master", &p.NodeConfig.Master)
+		v.validateNodeConfigProfile(path+".nodeConfig.worker", &p.NodeConfig.Worker)
+	}
+
+	v.validateAPIServerProfile(path+".apiserverProfile", &p.APIServerProfile)
+
+	if len(p.IngressProfiles) == 0 {
+		v.invalid(path+".ingressProfiles", "There should be at least one ingress profile.")
+	}
+	for i := range p.IngressProfiles {
+		v.validateIngressProfile(fmt.Sprintf("%s.ingressProfiles[%d]", path, i), &p.IngressProfiles[i])
+	}
+}
+
+func (v *openShiftClusterValidator) validateClusterProfile(path string, cp *api.ClusterProfile) {
+	if cp.PullSecret != "" {
+		var pullSecret map[string]interface{}
+		if err := json.Unmarshal([]byte(cp.PullSecret), &pullSecret); err != nil {
+			v.invalid(path+".pullSecret", "The provided pull secret is invalid.")
+		}
+	}
+
+	if !rxDomainName.MatchString(cp.Domain) {
+		v.invalid(path+".domain", "The provided domain '%s' is invalid.", cp.Domain)
+	}
+
+	if !rxResourceGroupID.MatchString(cp.ResourceGroupID) {
+		v.invalid(path+".resourceGroupId", "The provided resource group '%s' is invalid.", cp.ResourceGroupID)
+	}
+
+	switch cp.FipsValidatedModules {
+	case "", api.FipsValidatedModulesEnabled, api.FipsValidatedModulesDisabled:
+	default:
+		v.invalid(path+".fipsValidatedModules", "The provided FIPS type '%s' is invalid.", cp.FipsValidatedModules)
+	}
+}
+
+func (v *openShiftClusterValidator) validateServicePrincipalProfile(path string, spp *api.ServicePrincipalProfile) {
+	if spp == nil {
+		v.invalid(path, "The provided service principal profile is empty.")
+		return
+	}
+
+	if _, err := uuid.FromString(spp.ClientID); err != nil {
+		v.invalid(path+".clientId", "The provided client ID '%s' is invalid.", spp.ClientID)
+	}
+
+	if spp.ClientSecret == "" {
+		v.invalid(path+".clientSecret", "The provided client secret is empty.")
+	}
+}
+
+func (v *openShiftClusterValidator) validatePlatformWorkloadIdentityProfile(path string, pwip *api.PlatformWorkloadIdentityProfile) {
+	if len(pwip.PlatformWorkloadIdentities) == 0 {
+		v.invalid(path+".platformWorkloadIdentities", "There should be at least one platform workload identity.")
+	}
+
+	for i, identity := range pwip.PlatformWorkloadIdentities {
+		identityPath := fmt.Sprintf("%s.platformWorkloadIdentities[%d]", path, i)
+
+		if identity.OperatorName == "" {
+			v.invalid(identityPath+".operatorName", "The provided operator name is empty.")
+		}
+
+		if _, err := azure.ParseResourceID(identity.ResourceID); err != nil {
+			v.invalid(identityPath+".resourceId", "The provided resource ID '%s' is invalid.", identity.ResourceID)
+		}
+
+		if _, err := uuid.FromString(identity.ObjectID); err != nil {
+			v.invalid(identityPath+".objectId", "The provided object ID '%s' is invalid.", identity.ObjectID)
+		}
+	}
+}
+
+func (v *openShiftClusterValidator) validateNetworkProfile(path string, np *api.NetworkProfile) {
+	_, podCIDR, err := net.ParseCIDR(np.PodCIDR)
+	if err != nil || podCIDR.IP.To4() == nil {
+		v.invalid(path+".podCidr", "The provided pod CIDR '%s' is invalid.", np.PodCIDR)
+		podCIDR = nil
+	}
+
+	_, serviceCIDR, err := net.ParseCIDR(np.ServiceCIDR)
+	if err != nil || serviceCIDR.IP.To4() == nil {
+		v.invalid(path+".serviceCidr", "The provided service CIDR '%s' is invalid.", np.ServiceCIDR)
+		serviceCIDR = nil
+	}
+
+	if podCIDR != nil && serviceCIDR != nil &&
+		(podCIDR.Contains(serviceCIDR.IP) || serviceCIDR.Contains(podCIDR.IP)) {
+		v.invalid(path, "The provided pod CIDR '%s' and service CIDR '%s' overlap.", np.PodCIDR, np.ServiceCIDR)
+	}
+
+	switch np.SoftwareDefinedNetwork {
+	case "", api.SoftwareDefinedNetworkOVNKubernetes, api.SoftwareDefinedNetworkOpenShiftSDN:
+	default:
+		v.invalid(path+".softwareDefinedNetwork", "The provided SDN '%s' is invalid.", np.SoftwareDefinedNetwork)
+	}
+
+	switch np.OutboundType {
+	case "", api.OutboundTypeLoadbalancer, api.OutboundTypeUserDefinedRouting:
+	default:
+		v.invalid(path+".outboundType", "The provided outboundType '%s' is invalid.", np.OutboundType)
+	}
+
+	v.validateIP(path+".privateEndpointIp", np.APIServerPrivateEndpointIP)
+	v.validateIP(path+".gatewayPrivateEndpointIp", np.GatewayPrivateEndpointIP)
+}
+
+func (v *openShiftClusterValidator) validateMasterProfile(path string, mp *api.MasterProfile) {
+	if mp.VMSize == "" {
+		v.invalid(path+".vmSize", "The provided master VM size is empty.")
+	}
+
+	v.validateSubnetID(path+".subnetId", mp.SubnetID)
+	v.validateEncryptionAtHost(path+".encryptionAtHost", mp.EncryptionAtHost)
+	v.validateDiskEncryptionSetID(path+".diskEncryptionSetId", mp.DiskEncryptionSetID)
+}
+
+func (v *openShiftClusterValidator) validateWorkerProfile(path string, wp *api.WorkerProfile) {
+	if wp.Name == "" {
+		v.invalid(path+".name", "The provided worker name is empty.")
+	}
+
+	if wp.VMSize == "" {
+		v.invalid(path+".vmSize", "The provided worker VM size is empty.")
+	}
+
+	if wp.DiskSizeGB <= 0 {
+		v.invalid(path+".diskSizeGB", "The provided worker disk size '%d' is invalid.", wp.DiskSizeGB)
+	}
+
+	v.validateSubnetID(path+".subnetId", wp.SubnetID)
+
+	if wp.Count < 0 {
+		v.invalid(path+".count", "The provided worker count '%d' is invalid.", wp.Count)
+	}
+
+	v.validateEncryptionAtHost(path+".encryptionAtHost", wp.EncryptionAtHost)
+	v.validateDiskEncryptionSetID(path+".diskEncryptionSetId", wp.DiskEncryptionSetID)
+}
+
+// validateNodeConfigProfile rejects values which would break chrony.conf, the
+// kernel command line or the sysctl.d file written to the nodes
+func (v *openShiftClusterValidator) validateNodeConfigProfile(path string, p *api.NodeConfigProfile) {
+	for i, server := range p.ChronyServers {
+		if net.ParseIP(server) == nil && (len(server) > 253 || !rxHostname.MatchString(server)) {
+			v.invalid(fmt.Sprintf("%s.chronyServers[%d]", path, i), "The provided chrony server '%s' is invalid.", server)
+		}
+	}
+
+	for i, arg := range p.KernelArguments {
+		if !rxKernelArgument.MatchString(arg) {
+			v.invalid(fmt.Sprintf("%s.kernelArguments[%d]", path, i), "The provided kernel argument '%s' is invalid.", arg)
+		}
+	}
+
+	keys := make([]string, 0, len(p.Sysctls))
+	for key := range p.Sysctls {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := p.Sysctls[key]
+		if !rxSysctlKey.MatchString(key) {
+			v.invalid(fmt.Sprintf("%s.sysctls[%s]", path, key), "The provided sysctl key '%s' is invalid.", key)
+		}
+
+		if strings.TrimSpace(value) == "" || strings.ContainsAny(value, "\r\n") {
+			v.invalid(fmt.Sprintf("%s.sysctls[%s]", path, key), "The provided sysctl value '%s' is invalid.", value)
+		}
+	}
+}
+
+func (v *openShiftClusterValidator) validateAPIServerProfile(path string, ap *api.APIServerProfile) {
+	v.validateVisibility(path+".visibility", ap.Visibility)
+	v.validateIP(path+".ip", ap.IP)
+	v.validateIP(path+".intIp", ap.IntIP)
+}
+
+func (v *openShiftClusterValidator) validateIngressProfile(path string, ip *api.IngressProfile) {
+	v.validateVisibility(path+".visibility", ip.Visibility)
+	v.validateIP(path+".ip", ip.IP)
+}
+
+func (v *openShiftClusterValidator) validateSubnetID(path, subnetID string) {
+	if !rxSubnetID.MatchString(subnetID) {
+		v.invalid(path, "The provided subnet '%s' is invalid.", subnetID)
+	}
+}
+
+func (v *openShiftClusterValidator) validateDiskEncryptionSetID(path, diskEncryptionSetID string) {
+	if diskEncryptionSetID == "" {
+		return
+	}
+
+	if _, err := azure.ParseResourceID(diskEncryptionSetID); err != nil {
+		v.invalid(path, "The provided disk encryption set '%s' is invalid.", diskEncryptionSetID)
+	}
+}
+
+func (v *openShiftClusterValidator) validateEncryptionAtHost(path string, encryptionAtHost api.EncryptionAtHost) {
+	switch encryptionAtHost {
+	case "", api.EncryptionAtHostEnabled, api.EncryptionAtHostDisabled:
+	default:
+		v.invalid(path, "The provided value '%s' is invalid.", encryptionAtHost)
+	}
+}
+
+func (v *openShiftClusterValidator) validateVisibility(path string, visibility api.Visibility) {
+	switch visibility {
+	case "", api.VisibilityPublic, api.VisibilityPrivate:
+	default:
+		v.invalid(path, "The provided visibility '%s' is invalid.", visibility)
+	}
+}
+
+func (v *openShiftClusterValidator) validateIP(path, ip string) {
+	if ip != "" && net.ParseIP(ip) == nil {
+		v.invalid(path, "The provided IP '%s' is invalid.", ip)
+	}
+}
@@ -0,0 +1,211 @@
+package validate
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"testing"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+)
+
+func TestOpenShiftCluster(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vnetID := "/subscriptions/subscriptionId/resourceGroups/vnetResourceGroup/providers/Microsoft.Network/virtualNetworks/vnet"
+
+	validOpenShiftCluster := func() *api.OpenShiftCluster {
+		return &api.OpenShiftCluster{
+			ID:       "/subscriptions/subscriptionId/resourceGroups/resourceGroup/providers/Microsoft.RedHatOpenShift/openShiftClusters/cluster",
+			Location: "eastus",
+			Properties: api.OpenShiftClusterProperties{
+				InfraID:                         "cluster-abcde",
+				StorageSuffix:                   "abcde",
+				ImageRegistryStorageAccountName: "imageregistryabcde",
+				SSHKey:                          x509.MarshalPKCS1PrivateKey(key),
+				ClusterProfile: api.ClusterProfile{
+					PullSecret:      `{"auths":{}}`,
+					Domain:          "cluster.location.aroapp.io",
+					ResourceGroupID: "/subscriptions/subscriptionId/resourceGroups/clusterResourceGroup",
+				},
+				ServicePrincipalProfile: &api.ServicePrincipalProfile{
+					ClientID:     "11111111-1111-1111-1111-111111111111",
+					ClientSecret: "secret",
+				},
+				NetworkProfile: api.NetworkProfile{
+					PodCIDR:     "10.128.0.0/14",
+					ServiceCIDR: "172.30.0.0/16",
+				},
+				MasterProfile: api.MasterProfile{
+					VMSize:   api.VMSizeStandardD8sV3,
+					SubnetID: vnetID + "/subnets/master",
+				},
+				WorkerProfiles: []api.WorkerProfile{
+					{
+						Name:       "worker",
+						VMSize:     api.VMSizeStandardD4sV3,
+						DiskSizeGB: 128,
+						SubnetID:   vnetID + "/subnets/worker",
+						Count:      3,
+					},
+				},
+				APIServerProfile: api.APIServerProfile{
+					Visibility: api.VisibilityPublic,
+					IntIP:      "10.0.0.4",
+				},
+				IngressProfiles: []api.IngressProfile{
+					{
+						Name:       "default",
+						Visibility: api.VisibilityPublic,
+						IP:         "1.2.3.4",
+					},
+				},
+			},
+		}
+	}
+
+	for _, tt := range []struct {
+		name    string
+		modify  func(*api.OpenShiftCluster)
+		wantErr string
+	}{
+		{
+			name: "valid",
+		},
+		{
+			name: "valid workload identity cluster",
+			modify: func(oc *api.OpenShiftCluster) {
+				oc.Properties.ServicePrincipalProfile = nil
+				oc.Properties.PlatformWorkloadIdentityProfile = &api.PlatformWorkloadIdentityProfile{
+					PlatformWorkloadIdentities: []api.PlatformWorkloadIdentity{
+						{
+							OperatorName: "cloud-controller-manager",
+							ResourceID:   "/subscriptions/subscriptionId/resourceGroups/resourceGroup/providers/Microsoft.ManagedIdentity/userAssignedIdentities/ccm",
+							ObjectID:     "22222222-2222-2222-2222-222222222222",
+						},
+					},
+				}
+			},
+		},
+		{
+			name: "missing profiles",
+			modify: func(oc *api.OpenShiftCluster) {
+				oc.Properties.ServicePrincipalProfile = nil
+				oc.Properties.WorkerProfiles = nil
+				oc.Properties.IngressProfiles = nil
+			},
+			wantErr: "400: InvalidParameter: : The provided cluster document is invalid. Details: " +
+				"InvalidParameter: properties.servicePrincipalProfile: The provided service principal profile is empty., " +
+				"InvalidParameter: properties.workerProfiles: There should be at least one worker profile., " +
+				"InvalidParameter: properties.ingressProfiles: There should be at least one ingress profile.",
+		},
+		{
+			name: "bad network profile",
+			modify: func(oc *api.OpenShiftCluster) {
+				oc.Properties.NetworkProfile.PodCIDR = "10.128.0.0"
+				oc.Properties.NetworkProfile.ServiceCIDR = "fd00::/64"
+				oc.Properties.NetworkProfile.OutboundType = "Invalid"
+			},
+			wantErr: "400: InvalidParameter: : The provided cluster document is invalid. Details: " +
+				"InvalidParameter: properties.networkProfile.podCidr: The provided pod CIDR '10.128.0.0' is invalid., " +
+				"InvalidParameter: properties.networkProfile.serviceCidr: The provided service CIDR 'fd00::/64' is invalid., " +
+				"InvalidParameter: properties.networkProfile.outboundType: The provided outboundType 'Invalid' is invalid.",
+		},
+		{
+			name: "overlapping CIDRs",
+			modify: func(oc *api.OpenShiftCluster) {
+				oc.Properties.NetworkProfile.ServiceCIDR = "10.130.0.0/16"
+			},
+			wantErr: "400: InvalidParameter: : The provided cluster document is invalid. Details: " +
+				"InvalidParameter: properties.networkProfile: The provided pod CIDR '10.128.0.0/14' and service CIDR '10.130.0.0/16' overlap.",
+		},
+		{
+			name: "bad SSH key, subnet IDs and IPs",
+			modify: func(oc *api.OpenShiftCluster) {
+				oc.Properties.SSHKey = []byte("not a key")
+				oc.Properties.MasterProfile.SubnetID = vnetID
+				oc.Properties.WorkerProfiles[0].SubnetID = "worker"
+				oc.Properties.WorkerProfiles[0].DiskSizeGB = 0
+				oc.Properties.APIServerProfile.IntIP = "10.0.0"
+				oc.Properties.IngressProfiles[0].Visibility = "Internal"
+			},
+			wantErr: "400: InvalidParameter: : The provided cluster document is invalid. Details: " +
+				"InvalidParameter: properties.sshKey: The provided SSH key is not a PKCS1 private key., " +
+				"InvalidParameter: properties.masterProfile.subnetId: The provided subnet '" + vnetID + "' is invalid., " +
+				"InvalidParameter: properties.workerProfiles[0].diskSizeGB: The provided worker disk size '0' is invalid., " +
+				"InvalidParameter: properties.workerProfiles[0].subnetId: The provided subnet 'worker' is invalid., " +
+				"InvalidParameter: properties.apiserverProfile.intIp: The provided IP '10.0.0' is invalid., " +
+				"InvalidParameter: properties.ingressProfiles[0].visibility: The provided visibility 'Internal' is invalid.",
+		},
+		{
+			name: "valid node config",
+			modify: func(oc *api.OpenShiftCluster) {
+				oc.Properties.NodeConfig = &api.NodeConfig{
+					Master: api.NodeConfigProfile{
+						ChronyServers:   []string{"ntp.example.com", "10.0.0.1"},
+						KernelArguments: []string{"fips=1", "nosmt"},
+						Sysctls: map[string]string{
+							"net.ipv4.tcp_keepalive_time": "600",
+						},
+					},
+					Worker: api.NodeConfigProfile{
+						ChronyServers: []string{"ntp"},
+					},
+				}
+			},
+		},
+		{
+			name: "bad node config",
+			modify: func(oc *api.OpenShiftCluster) {
+				oc.Properties.NodeConfig = &api.NodeConfig{
+					Master: api.NodeConfigProfile{
+						KernelArguments: []string{"console=tty0 rd.break"},
+						Sysctls: map[string]string{
+							"net":                 "1",
+							"net.ipv4.ip_forward": "1\nkernel.panic = 0",
+						},
+					},
+					Worker: api.NodeConfigProfile{
+						ChronyServers: []string{"ntp.example.com", "ntp example"},
+					},
+				}
+			},
+			wantErr: "400: InvalidParameter: : The provided cluster document is invalid. Details: " +
+				"InvalidParameter: properties.nodeConfig.master.kernelArguments[0]: The provided kernel argument 'console=tty0 rd.break' is invalid., " +
+				"InvalidParameter: properties.nodeConfig.master.sysctls[net]: The provided sysctl key 'net' is invalid., " +
+				"InvalidParameter: properties.nodeConfig.master.sysctls[net.ipv4.ip_forward]: The provided sysctl value '1\nkernel.panic = 0' is invalid., " +
+				"InvalidParameter: properties.nodeConfig.worker.chronyServers[1]: The provided chrony server 'ntp example' is invalid.",
+		},
+		{
+			name: "bad cluster profile",
+			modify: func(oc *api.OpenShiftCluster) {
+				oc.Properties.ClusterProfile.PullSecret = "{"
+				oc.Properties.ClusterProfile.Domain = "Cluster"
+				oc.Properties.ClusterProfile.ResourceGroupID = "clusterResourceGroup"
+			},
+			wantErr: "400: InvalidParameter: : The provided cluster document is invalid. Details: " +
+				"InvalidParameter: properties.clusterProfile.pullSecret: The provided pull secret is invalid., " +
+				"InvalidParameter: properties.clusterProfile.domain: The provided domain 'Cluster' is invalid., " +
+				"InvalidParameter: properties.clusterProfile.resourceGroupId: The provided resource group 'clusterResourceGroup' is invalid.",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			oc := validOpenShiftCluster()
+			if tt.modify != nil {
+				tt.modify(oc)
+			}
+
+			err := OpenShiftCluster(oc)
+			if err != nil && err.Error() != tt.wantErr ||
+				err == nil && tt.wantErr != "" {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package graph
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+
+	"github.com/openshift/installer/pkg/asset"
+)
+
+// IgnitionHashes holds the verification hashes of the ignition configs which
+// Save uploads to the ignition container.  It is stored in the graph so that
+// the pointer configs can verify what they fetch.  Graphs persisted before it
+// was introduced do not contain it.
+type IgnitionHashes struct {
+	Bootstrap string `json:"bootstrap,omitempty"`
+	Master    string `json:"master,omitempty"`
+}
+
+var _ asset.Asset = &IgnitionHashes{}
+
+func (*IgnitionHashes) Dependencies() []asset.Asset {
+	return nil
+}
+
+// Generate is a no-op: IgnitionHashes is set by Save
+func (*IgnitionHashes) Generate(asset.Parents) error {
+	return nil
+}
+
+func (*IgnitionHashes) Name() string {
+	return "Ignition Hashes"
+}
+
+// ignitionHash returns b's hash in the form expected by ignition's
+// verification.hash field
+func ignitionHash(b []byte) string {
+	sum := sha512.Sum512(b)
+	return "sha512-" + hex.EncodeToString(sum[:])
+}
@@ -11,6 +11,7 @@ import (
 
 	mgmtstorage "github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2019-06-01/storage"
 	"github.com/openshift/installer/pkg/asset/ignition/bootstrap"
+	"github.com/openshift/installer/pkg/asset/ignition/machine"
 	"github.com/sirupsen/logrus"
 
 	"github.com/openshift/installer-aro-wrapper/pkg/util/encryption"
@@ -61,15 +62,37 @@ func (m *manager) Save(ctx context.Context, resourceGroup, account string, g Gra
 		return err
 	}
 
+	ignition := blobService.GetContainerReference("ignition")
+
 	bootstrap := g.Get(&bootstrap.Bootstrap{}).(*bootstrap.Bootstrap)
-	bootstrapIgn := blobService.GetContainerReference("ignition").GetBlobReference("bootstrap.ign")
+	bootstrapIgn := ignition.GetBlobReference("bootstrap.ign")
 	err = bootstrapIgn.CreateBlockBlobFromReader(bytes.NewReader(bootstrap.File.Data), nil)
 	if err != nil {
 		return err
 	}
 
+	// the master VMs' customData only points to master.ign, see
+	// installer.ignitionPointerCustomData
+	master := g.Get(&machine.Master{}).(*machine.Master)
+	masterIgn := ignition.GetBlobReference("master.ign")
+	err = masterIgn.CreateBlockBlobFromReader(bytes.NewReader(master.File.Data), nil)
+	if err != nil {
+		return err
+	}
+
+	// the hashes are persisted with the graph, without modifying the
+	// caller's graph
+	persisted := make(Graph, len(g)+1)
+	for k, v := range g {
+		persisted[k] = v
+	}
+	persisted.Set(&IgnitionHashes{
+		Bootstrap: ignitionHash(bootstrap.File.Data),
+		Master:    ignitionHash(master.File.Data),
+	})
+
 	graph := blobService.GetContainerReference("aro").GetBlobReference("graph")
-	b, err := json.MarshalIndent(g, "", "    ")
+	b, err := json.MarshalIndent(persisted, "", "    ")
 	if err != nil {
 		return err
 	}
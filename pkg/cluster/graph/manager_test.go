@@ -0,0 +1,135 @@
+package graph
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+
+	mgmtstorage "github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2019-06-01/storage"
+	azstorage "github.com/Azure/azure-sdk-for-go/storage"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/golang/mock/gomock"
+	"github.com/openshift/installer/pkg/asset"
+	"github.com/openshift/installer/pkg/asset/ignition/bootstrap"
+	"github.com/openshift/installer/pkg/asset/ignition/machine"
+	"github.com/sirupsen/logrus"
+
+	mock_encryption "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/encryption"
+	mock_storage "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/storage"
+)
+
+// fakeBlobStore records the blobs uploaded through a blob storage client
+type fakeBlobStore struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func (f *fakeBlobStore) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if req.Method != http.MethodPut {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(&bytes.Buffer{}), Header: http.Header{}, Request: req}, nil
+	}
+
+	b, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.blobs[req.URL.Path] = b
+
+	return &http.Response{StatusCode: http.StatusCreated, Body: io.NopCloser(&bytes.Buffer{}), Header: http.Header{}, Request: req}, nil
+}
+
+func (f *fakeBlobStore) client() *azstorage.BlobStorageClient {
+	c := azstorage.NewAccountSASClient("cluster", url.Values{"sv": []string{"2019-02-02"}}, azure.PublicCloud)
+	c.HTTPClient = &http.Client{Transport: f}
+	blobService := c.GetBlobService()
+	return &blobService
+}
+
+func TestSave(t *testing.T) {
+	ctx := context.Background()
+
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	store := &fakeBlobStore{blobs: map[string][]byte{}}
+
+	storage := mock_storage.NewMockManager(controller)
+	storage.EXPECT().BlobService(ctx, "resourceGroup", "cluster", mgmtstorage.Permissions("cw"), mgmtstorage.SignedResourceTypesO).
+		Return(store.client(), nil)
+
+	aead := mock_encryption.NewMockAEAD(controller)
+	aead.EXPECT().Seal(gomock.Any()).DoAndReturn(func(b []byte) ([]byte, error) { return b, nil })
+
+	m := &manager{
+		log:     logrus.NewEntry(logrus.StandardLogger()),
+		aead:    aead,
+		storage: storage,
+	}
+
+	g := Graph{}
+	g.Set(
+		&bootstrap.Bootstrap{Common: bootstrap.Common{File: &asset.File{Data: []byte("bootstrap")}}},
+		&machine.Master{File: &asset.File{Data: []byte("master")}},
+	)
+
+	err := m.Save(ctx, "resourceGroup", "cluster", g)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for blob, want := range map[string]string{
+		"/ignition/bootstrap.ign": "bootstrap",
+		"/ignition/master.ign":    "master",
+	} {
+		if string(store.blobs[blob]) != want {
+			t.Errorf("%s: got %q", blob, string(store.blobs[blob]))
+		}
+	}
+
+	var pg PersistedGraph
+	err = json.Unmarshal(store.blobs["/aro/graph"], &pg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ignitionHashes *IgnitionHashes
+	err = pg.Get(&ignitionHashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantHashes := &IgnitionHashes{
+		Bootstrap: ignitionHash([]byte("bootstrap")),
+		Master:    ignitionHash([]byte("master")),
+	}
+	if !reflect.DeepEqual(ignitionHashes, wantHashes) {
+		t.Errorf("got ignition hashes %#v", ignitionHashes)
+	}
+
+	// the caller's graph must not be modified
+	if g.Get(&IgnitionHashes{}) != nil {
+		t.Error("ignition hashes were set in the caller's graph")
+	}
+}
+
+func TestIgnitionHash(t *testing.T) {
+	// sha512 of the empty string
+	want := "sha512-cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e"
+
+	got := ignitionHash(nil)
+	if got != want {
+		t.Error(got)
+	}
+}
@@ -31,6 +31,13 @@ func (pg PersistedGraph) Get(is ...interface{}) error {
 	return nil
 }
 
+// Has returns true if the graph contains an object of the type Get would
+// decode into i.  It is used for objects which older graphs may not contain.
+func (pg PersistedGraph) Has(i interface{}) bool {
+	_, ok := pg[reflect.TypeOf(i).Elem().String()]
+	return ok
+}
+
 // Set is currently only used in unit test context.  If you want to use this in
 // production, you will want to be very sure that you are not losing state that
 // you may need later
@@ -31,6 +31,11 @@ const (
 	FeatureEnableDevelopmentAuthorizer
 	FeatureRequireD2sV3Workers
 	FeatureDisableReadinessDelay
+	FeatureEnableManagedBootDiagnostics
+	FeatureEnableDiskEncryptionSetRoleAssignment
+	FeatureAdoptConflictingResources
+	FeatureBlockUnexpectedVMChanges
+	FeatureEnableNetworkRoleAssignment
 )
 
 const (
@@ -6,9 +6,9 @@ import (
 	"fmt"
 )
 
-const _FeatureName = "FeatureDisableDenyAssignmentsFeatureDisableSignedCertificatesFeatureEnableDevelopmentAuthorizerFeatureRequireD2sV3WorkersFeatureDisableReadinessDelay"
+const _FeatureName = "FeatureDisableDenyAssignmentsFeatureDisableSignedCertificatesFeatureEnableDevelopmentAuthorizerFeatureRequireD2sV3WorkersFeatureDisableReadinessDelayFeatureEnableManagedBootDiagnosticsFeatureEnableDiskEncryptionSetRoleAssignmentFeatureAdoptConflictingResourcesFeatureBlockUnexpectedVMChangesFeatureEnableNetworkRoleAssignment"
 
-var _FeatureIndex = [...]uint8{0, 29, 61, 95, 121, 149}
+var _FeatureIndex = [...]uint16{0, 29, 61, 95, 121, 149, 184, 228, 260, 291, 325}
 
 func (i Feature) String() string {
 	if i < 0 || i >= Feature(len(_FeatureIndex)-1) {
@@ -17,7 +17,7 @@ func (i Feature) String() string {
 	return _FeatureName[_FeatureIndex[i]:_FeatureIndex[i+1]]
 }
 
-var _FeatureValues = []Feature{0, 1, 2, 3, 4}
+var _FeatureValues = []Feature{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
 
 var _FeatureNameToValueMap = map[string]Feature{
 	_FeatureName[0:29]:    0,
@@ -25,6 +25,11 @@ var _FeatureNameToValueMap = map[string]Feature{
 	_FeatureName[61:95]:   2,
 	_FeatureName[95:121]:  3,
 	_FeatureName[121:149]: 4,
+	_FeatureName[149:184]: 5,
+	_FeatureName[184:228]: 6,
+	_FeatureName[228:260]: 7,
+	_FeatureName[260:291]: 8,
+	_FeatureName[291:325]: 9,
 }
 
 // FeatureString retrieves an enum value from the enum constants string name.
@@ -0,0 +1,185 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	mgmtcompute "github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2020-06-01/compute"
+	mgmtstorage "github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2019-06-01/storage"
+	"github.com/Azure/go-autorest/autorest/to"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/env"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/stringutils"
+)
+
+const (
+	bootDiagnosticsSerialLog  = "serial.log"
+	bootDiagnosticsScreenshot = "screenshot.bmp"
+)
+
+// diagnosticsProfile returns the boot diagnostics configuration shared by the
+// bootstrap and master VMs.  Managed boot diagnostics are used when
+// FeatureEnableManagedBootDiagnostics is set, otherwise the logs go to the
+// cluster storage account.
+func (m *manager) diagnosticsProfile() *mgmtcompute.DiagnosticsProfile {
+	bootDiagnostics := &mgmtcompute.BootDiagnostics{
+		Enabled: to.BoolPtr(true),
+	}
+
+	// Azure uses managed storage if no storage URI is set
+	if !m.env.FeatureIsSet(env.FeatureEnableManagedBootDiagnostics) {
+		bootDiagnostics.StorageURI = to.StringPtr("https://cluster" + m.oc.Properties.StorageSuffix + ".blob." + m.env.Environment().StorageEndpointSuffix + "/")
+	}
+
+	return &mgmtcompute.DiagnosticsProfile{
+		BootDiagnostics: bootDiagnostics,
+	}
+}
+
+// gatherBootDiagnostics saves the serial console log and screenshot of the
+// bootstrap and master VMs for post-mortem.  It is best effort: errors are
+// logged and never returned, so that they do not mask the install failure.
+func (m *manager) gatherBootDiagnostics(ctx context.Context) {
+	m.log.Print("gathering boot diagnostics")
+
+	diagnostics, err := m.retrieveBootDiagnostics(ctx)
+	if err != nil {
+		m.log.Warnf("failed to retrieve boot diagnostics: %s", err)
+	}
+
+	if len(diagnostics) == 0 {
+		return
+	}
+
+	if m.assetsDir != "" {
+		err = m.writeBootDiagnostics(diagnostics)
+		if err != nil {
+			m.log.Warnf("failed to write boot diagnostics to %s: %s", m.assetsDir, err)
+		}
+	}
+
+	err = m.uploadBootDiagnostics(ctx, diagnostics)
+	if err != nil {
+		m.log.Warnf("failed to upload boot diagnostics: %s", err)
+	}
+}
+
+// retrieveBootDiagnostics downloads the boot diagnostics of the bootstrap and
+// master VMs.  The returned map is keyed by "<vm name>/<file name>".
+func (m *manager) retrieveBootDiagnostics(ctx context.Context) (map[string][]byte, error) {
+	resourceGroup := stringutils.LastTokenByte(m.oc.Properties.ClusterProfile.ResourceGroupID, '/')
+
+	vms, err := m.virtualMachines.List(ctx, resourceGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	diagnostics := map[string][]byte{}
+	for _, vm := range vms {
+		if vm.Name == nil ||
+			(*vm.Name != m.oc.Properties.InfraID+"-bootstrap" &&
+				!strings.HasPrefix(*vm.Name, m.oc.Properties.InfraID+"-master-")) {
+			continue
+		}
+
+		data, err := m.virtualMachines.RetrieveBootDiagnosticsData(ctx, resourceGroup, *vm.Name, to.Int32Ptr(60))
+		if err != nil {
+			m.log.Warnf("failed to retrieve boot diagnostics data for %s: %s", *vm.Name, err)
+			continue
+		}
+
+		for name, uri := range map[string]*string{
+			bootDiagnosticsSerialLog:  data.SerialConsoleLogBlobURI,
+			bootDiagnosticsScreenshot: data.ConsoleScreenshotBlobURI,
+		} {
+			if uri == nil {
+				continue
+			}
+
+			b, err := download(ctx, *uri)
+			if err != nil {
+				m.log.Warnf("failed to download %s for %s: %s", name, *vm.Name, err)
+				continue
+			}
+
+			diagnostics[*vm.Name+"/"+name] = b
+		}
+	}
+
+	return diagnostics, nil
+}
+
+func (m *manager) writeBootDiagnostics(diagnostics map[string][]byte) error {
+	for name, b := range diagnostics {
+		path := filepath.Join(m.assetsDir, "bootdiagnostics", filepath.FromSlash(name))
+
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			return err
+		}
+
+		err = os.WriteFile(path, b, 0644)
+		if err != nil {
+			return err
+		}
+
+		m.log.Printf("wrote %s", path)
+	}
+
+	return nil
+}
+
+// uploadBootDiagnostics persists the boot diagnostics next to the graph in the
+// "aro" container of the cluster storage account.  Each blob is written with a
+// SAS scoped to that blob only, so that the graph can't be overwritten.
+func (m *manager) uploadBootDiagnostics(ctx context.Context, diagnostics map[string][]byte) error {
+	resourceGroup := stringutils.LastTokenByte(m.oc.Properties.ClusterProfile.ResourceGroupID, '/')
+	account := "cluster" + m.oc.Properties.StorageSuffix
+
+	for name, b := range diagnostics {
+		blob, err := m.storage.Blob(ctx, resourceGroup, account, "aro", "bootdiagnostics/"+name, mgmtstorage.Permissions("cw"))
+		if err != nil {
+			return err
+		}
+
+		err = blob.CreateBlockBlobFromReader(bytes.NewReader(b), nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func download(ctx context.Context, uri string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err, ok := err.(*url.Error); ok {
+		// don't leak the SAS token in the URL into the logs
+		return nil, err.Err
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(resp.Body)
+}
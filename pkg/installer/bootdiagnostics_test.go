@@ -0,0 +1,203 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	mgmtcompute "github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2020-06-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+	"github.com/openshift/installer-aro-wrapper/pkg/env"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/azureclient"
+	mock_compute "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/azureclient/mgmt/compute"
+	mock_env "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/env"
+)
+
+func TestDiagnosticsProfile(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		managed bool
+		want    *mgmtcompute.DiagnosticsProfile
+	}{
+		{
+			name: "storage account boot diagnostics",
+			want: &mgmtcompute.DiagnosticsProfile{
+				BootDiagnostics: &mgmtcompute.BootDiagnostics{
+					Enabled:    to.BoolPtr(true),
+					StorageURI: to.StringPtr("https://clusterabcdef.blob.core.windows.net/"),
+				},
+			},
+		},
+		{
+			name:    "managed boot diagnostics",
+			managed: true,
+			want: &mgmtcompute.DiagnosticsProfile{
+				BootDiagnostics: &mgmtcompute.BootDiagnostics{
+					Enabled: to.BoolPtr(true),
+				},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			_env := mock_env.NewMockInterface(controller)
+			_env.EXPECT().FeatureIsSet(env.FeatureEnableManagedBootDiagnostics).Return(tt.managed)
+			_env.EXPECT().Environment().Return(&azureclient.PublicCloud).AnyTimes()
+
+			m := &manager{
+				env: _env,
+				oc: &api.OpenShiftCluster{
+					Properties: api.OpenShiftClusterProperties{
+						StorageSuffix: "abcdef",
+					},
+				},
+			}
+
+			got := m.diagnosticsProfile()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got.BootDiagnostics, tt.want.BootDiagnostics)
+			}
+		})
+	}
+}
+
+func TestRetrieveBootDiagnostics(t *testing.T) {
+	ctx := context.Background()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/serial":
+			_, _ = w.Write([]byte("serial " + r.URL.Query().Get("vm")))
+		case "/screenshot":
+			_, _ = w.Write([]byte("screenshot " + r.URL.Query().Get("vm")))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	bootDiagnosticsData := func(vm string) mgmtcompute.RetrieveBootDiagnosticsDataResult {
+		return mgmtcompute.RetrieveBootDiagnosticsDataResult{
+			SerialConsoleLogBlobURI:  to.StringPtr(srv.URL + "/serial?vm=" + vm),
+			ConsoleScreenshotBlobURI: to.StringPtr(srv.URL + "/screenshot?vm=" + vm),
+		}
+	}
+
+	for _, tt := range []struct {
+		name    string
+		mocks   func(*mock_compute.MockVirtualMachinesClient)
+		want    map[string][]byte
+		wantErr string
+	}{
+		{
+			name: "bootstrap and master diagnostics are retrieved",
+			mocks: func(vmc *mock_compute.MockVirtualMachinesClient) {
+				vmc.EXPECT().List(ctx, "resourceGroup").Return([]mgmtcompute.VirtualMachine{
+					{Name: to.StringPtr("infra-bootstrap")},
+					{Name: to.StringPtr("infra-master-0")},
+					{Name: to.StringPtr("infra-worker-eastus1-abcde")},
+					{Name: to.StringPtr("other-master-0")},
+				}, nil)
+				vmc.EXPECT().RetrieveBootDiagnosticsData(ctx, "resourceGroup", "infra-bootstrap", to.Int32Ptr(60)).Return(bootDiagnosticsData("infra-bootstrap"), nil)
+				vmc.EXPECT().RetrieveBootDiagnosticsData(ctx, "resourceGroup", "infra-master-0", to.Int32Ptr(60)).Return(bootDiagnosticsData("infra-master-0"), nil)
+			},
+			want: map[string][]byte{
+				"infra-bootstrap/serial.log":     []byte("serial infra-bootstrap"),
+				"infra-bootstrap/screenshot.bmp": []byte("screenshot infra-bootstrap"),
+				"infra-master-0/serial.log":      []byte("serial infra-master-0"),
+				"infra-master-0/screenshot.bmp":  []byte("screenshot infra-master-0"),
+			},
+		},
+		{
+			name: "a VM without boot diagnostics is skipped",
+			mocks: func(vmc *mock_compute.MockVirtualMachinesClient) {
+				vmc.EXPECT().List(ctx, "resourceGroup").Return([]mgmtcompute.VirtualMachine{
+					{Name: to.StringPtr("infra-bootstrap")},
+					{Name: to.StringPtr("infra-master-0")},
+				}, nil)
+				vmc.EXPECT().RetrieveBootDiagnosticsData(ctx, "resourceGroup", "infra-bootstrap", to.Int32Ptr(60)).Return(mgmtcompute.RetrieveBootDiagnosticsDataResult{}, errors.New("not found"))
+				vmc.EXPECT().RetrieveBootDiagnosticsData(ctx, "resourceGroup", "infra-master-0", to.Int32Ptr(60)).Return(mgmtcompute.RetrieveBootDiagnosticsDataResult{
+					SerialConsoleLogBlobURI: to.StringPtr(srv.URL + "/serial?vm=infra-master-0"),
+				}, nil)
+			},
+			want: map[string][]byte{
+				"infra-master-0/serial.log": []byte("serial infra-master-0"),
+			},
+		},
+		{
+			name: "listing VMs fails",
+			mocks: func(vmc *mock_compute.MockVirtualMachinesClient) {
+				vmc.EXPECT().List(ctx, "resourceGroup").Return(nil, errors.New("oh no"))
+			},
+			wantErr: "oh no",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			virtualMachines := mock_compute.NewMockVirtualMachinesClient(controller)
+			tt.mocks(virtualMachines)
+
+			m := &manager{
+				log:             logrus.NewEntry(logrus.StandardLogger()),
+				virtualMachines: virtualMachines,
+				oc: &api.OpenShiftCluster{
+					Properties: api.OpenShiftClusterProperties{
+						ClusterProfile: api.ClusterProfile{
+							ResourceGroupID: "/subscriptions/subscriptionId/resourceGroups/resourceGroup",
+						},
+						InfraID: "infra",
+					},
+				},
+			}
+
+			got, err := m.retrieveBootDiagnostics(ctx)
+			if err != nil && err.Error() != tt.wantErr ||
+				err == nil && tt.wantErr != "" {
+				t.Error(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteBootDiagnostics(t *testing.T) {
+	dir := t.TempDir()
+
+	m := &manager{
+		log:       logrus.NewEntry(logrus.StandardLogger()),
+		assetsDir: dir,
+	}
+
+	err := m.writeBootDiagnostics(map[string][]byte{
+		"infra-bootstrap/serial.log": []byte("serial"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "bootdiagnostics", "infra-bootstrap", "serial.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "serial" {
+		t.Error(string(b))
+	}
+}
@@ -72,5 +72,12 @@ func (m *manager) applyInstallConfigCustomisations(installConfig *installconfig.
 		}
 	}
 
+	if m.oc.Properties.NodeConfig != nil {
+		m.log.Print("applying node config")
+		if err = m.applyNodeConfig(g); err != nil {
+			return nil, err
+		}
+	}
+
 	return g, nil
 }
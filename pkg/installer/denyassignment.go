@@ -0,0 +1,150 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	mgmtauthorization "github.com/Azure/azure-sdk-for-go/services/preview/authorization/mgmt/2018-09-01-preview/authorization"
+	"github.com/Azure/go-autorest/autorest/to"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/arm"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/arm/expression"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/azureclient"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/stringutils"
+)
+
+// denyAssignmentName is the ARM expression naming the cluster resource group
+// deny assignment
+var denyAssignmentName = expression.Format(expression.GUID(expression.ResourceGroupID(), expression.Literal("ARO cluster resource group deny assignment")))
+
+const (
+	// everyonePrincipalID is the system defined principal matching every
+	// principal
+	everyonePrincipalID = "00000000-0000-0000-0000-000000000000"
+)
+
+// denyAssignment returns the deny assignment preventing anyone but the cluster
+// identities from modifying the cluster resource group
+func (m *manager) denyAssignment() *arm.Resource {
+	var excludePrincipals []mgmtauthorization.Principal
+	for _, principalID := range m.clusterPrincipalIDs() {
+		excludePrincipals = append(excludePrincipals, mgmtauthorization.Principal{
+			ID:   to.StringPtr(principalID),
+			Type: to.StringPtr(string(mgmtauthorization.ServicePrincipal)),
+		})
+	}
+
+	return &arm.Resource{
+		Resource: &mgmtauthorization.DenyAssignment{
+			Name: to.StringPtr(denyAssignmentName),
+			Type: to.StringPtr("Microsoft.Authorization/denyAssignments"),
+			DenyAssignmentProperties: &mgmtauthorization.DenyAssignmentProperties{
+				DenyAssignmentName: to.StringPtr(denyAssignmentName),
+				Permissions: &[]mgmtauthorization.DenyAssignmentPermission{
+					{
+						Actions: &[]string{
+							"*/action",
+							"*/delete",
+							"*/write",
+						},
+						NotActions: &[]string{
+							"Microsoft.Compute/disks/beginGetAccess/action",
+							"Microsoft.Compute/disks/endGetAccess/action",
+							"Microsoft.Compute/disks/write",
+							"Microsoft.Compute/snapshots/beginGetAccess/action",
+							"Microsoft.Compute/snapshots/delete",
+							"Microsoft.Compute/snapshots/endGetAccess/action",
+							"Microsoft.Compute/snapshots/write",
+							"Microsoft.Network/networkInterfaces/effectiveRouteTable/action",
+							"Microsoft.Network/networkSecurityGroups/join/action",
+							"Microsoft.Resources/tags/write",
+						},
+					},
+				},
+				Scope: &m.oc.Properties.ClusterProfile.ResourceGroupID,
+				Principals: &[]mgmtauthorization.Principal{
+					{
+						ID:   to.StringPtr(everyonePrincipalID),
+						Type: to.StringPtr("SystemDefined"),
+					},
+				},
+				ExcludePrincipals: &excludePrincipals,
+				IsSystemProtected: to.BoolPtr(true),
+			},
+		},
+		APIVersion: azureclient.APIVersion("Microsoft.Authorization/denyAssignments"),
+	}
+}
+
+// isClusterDenyAssignment returns true if the deny assignment applies to
+// everyone and is system protected, as the cluster deny assignment does
+func isClusterDenyAssignment(da *mgmtauthorization.DenyAssignment) bool {
+	if da.DenyAssignmentProperties == nil || !to.Bool(da.IsSystemProtected) || da.Principals == nil {
+		return false
+	}
+
+	for _, principal := range *da.Principals {
+		if to.String(principal.ID) == everyonePrincipalID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateDenyAssignments checks that a cluster deny assignment already on the
+// cluster resource group, e.g. from a previous install attempt, excludes the
+// cluster identities.  Otherwise the deployment and the cluster itself would
+// be denied access to the resource group.
+func (m *manager) validateDenyAssignments(ctx context.Context) error {
+	m.log.Print("validating deny assignments")
+
+	resourceGroup := stringutils.LastTokenByte(m.oc.Properties.ClusterProfile.ResourceGroupID, '/')
+
+	denyAssignments, err := m.denyAssignments.ListForResourceGroup(ctx, resourceGroup, "")
+	if err != nil {
+		return err
+	}
+
+	var details []api.CloudErrorBody
+	for i := range denyAssignments {
+		da := &denyAssignments[i]
+		if !isClusterDenyAssignment(da) {
+			continue
+		}
+
+		excluded := map[string]bool{}
+		if da.ExcludePrincipals != nil {
+			for _, principal := range *da.ExcludePrincipals {
+				excluded[strings.ToLower(to.String(principal.ID))] = true
+			}
+		}
+
+		for _, principalID := range m.clusterPrincipalIDs() {
+			if excluded[strings.ToLower(principalID)] {
+				continue
+			}
+
+			details = append(details, api.CloudErrorBody{
+				Code:    api.CloudErrorCodeConflictingDenyAssignment,
+				Target:  to.String(da.ID),
+				Message: fmt.Sprintf("The deny assignment '%s' does not exclude the cluster identity with object ID '%s'.", to.String(da.ID), principalID),
+			})
+		}
+	}
+
+	if len(details) == 0 {
+		return nil
+	}
+
+	cloudErr := api.NewCloudError(http.StatusBadRequest, api.CloudErrorCodeConflictingDenyAssignment, "", "The cluster resource group '%s' has a deny assignment which would deny the cluster access to it.", resourceGroup)
+	cloudErr.Details = details
+
+	return cloudErr
+}
@@ -0,0 +1,145 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	mgmtauthorization "github.com/Azure/azure-sdk-for-go/services/preview/authorization/mgmt/2018-09-01-preview/authorization"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+	mock_authorization "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/azureclient/mgmt/authorization"
+)
+
+func TestValidateDenyAssignments(t *testing.T) {
+	ctx := context.Background()
+
+	daID := "/subscriptions/subscriptionId/resourceGroups/clusterResourceGroup/providers/Microsoft.Authorization/denyAssignments/da"
+
+	denyAssignment := func(isSystemProtected bool, principalID string, excludePrincipalIDs ...string) mgmtauthorization.DenyAssignment {
+		var excludePrincipals []mgmtauthorization.Principal
+		for _, id := range excludePrincipalIDs {
+			excludePrincipals = append(excludePrincipals, mgmtauthorization.Principal{ID: to.StringPtr(id)})
+		}
+
+		return mgmtauthorization.DenyAssignment{
+			ID: to.StringPtr(daID),
+			DenyAssignmentProperties: &mgmtauthorization.DenyAssignmentProperties{
+				Principals: &[]mgmtauthorization.Principal{
+					{ID: to.StringPtr(principalID)},
+				},
+				ExcludePrincipals: &excludePrincipals,
+				IsSystemProtected: to.BoolPtr(isSystemProtected),
+			},
+		}
+	}
+
+	for _, tt := range []struct {
+		name            string
+		denyAssignments []mgmtauthorization.DenyAssignment
+		wantErr         string
+	}{
+		{
+			name: "no deny assignments",
+		},
+		{
+			name: "identity excluded",
+			denyAssignments: []mgmtauthorization.DenyAssignment{
+				denyAssignment(true, everyonePrincipalID, "SPOBJECTID"),
+			},
+		},
+		{
+			name: "unrelated deny assignment",
+			denyAssignments: []mgmtauthorization.DenyAssignment{
+				denyAssignment(false, "someoneElse"),
+			},
+		},
+		{
+			name: "identity not excluded",
+			denyAssignments: []mgmtauthorization.DenyAssignment{
+				denyAssignment(true, everyonePrincipalID, "otherObjectId"),
+			},
+			wantErr: "400: ConflictingDenyAssignment: : The cluster resource group 'clusterResourceGroup' has a deny assignment which would deny the cluster access to it. Details: " +
+				"ConflictingDenyAssignment: " + daID + ": The deny assignment '" + daID + "' does not exclude the cluster identity with object ID 'spObjectId'.",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			denyAssignments := mock_authorization.NewMockDenyAssignmentClient(controller)
+			denyAssignments.EXPECT().ListForResourceGroup(ctx, "clusterResourceGroup", "").Return(tt.denyAssignments, nil)
+
+			m := &manager{
+				log:             logrus.NewEntry(logrus.StandardLogger()),
+				denyAssignments: denyAssignments,
+				oc: &api.OpenShiftCluster{
+					Properties: api.OpenShiftClusterProperties{
+						ClusterProfile: api.ClusterProfile{
+							ResourceGroupID: "/subscriptions/subscriptionId/resourceGroups/clusterResourceGroup",
+						},
+						ServicePrincipalProfile: &api.ServicePrincipalProfile{
+							SPObjectID: "spObjectId",
+						},
+					},
+				},
+			}
+
+			err := m.validateDenyAssignments(ctx)
+			if err != nil && err.Error() != tt.wantErr ||
+				err == nil && tt.wantErr != "" {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestDenyAssignment(t *testing.T) {
+	m := &manager{
+		oc: &api.OpenShiftCluster{
+			Properties: api.OpenShiftClusterProperties{
+				ClusterProfile: api.ClusterProfile{
+					ResourceGroupID: "/subscriptions/subscriptionId/resourceGroups/clusterResourceGroup",
+				},
+				ServicePrincipalProfile: &api.ServicePrincipalProfile{
+					SPObjectID: "spObjectId",
+				},
+			},
+		},
+	}
+
+	b, err := json.Marshal(m.denyAssignment())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]interface{}
+	err = json.Unmarshal(b, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got["name"] != denyAssignmentName || got["type"] != "Microsoft.Authorization/denyAssignments" || got["apiVersion"] != "2018-07-01-preview" {
+		t.Errorf("unexpected resource %s", string(b))
+	}
+
+	properties := got["properties"].(map[string]interface{})
+
+	wantExcludePrincipals := []interface{}{
+		map[string]interface{}{"id": "spObjectId", "type": "ServicePrincipal"},
+	}
+	if !reflect.DeepEqual(properties["excludePrincipals"], wantExcludePrincipals) {
+		t.Errorf("got excludePrincipals %v", properties["excludePrincipals"])
+	}
+
+	if properties["scope"] != "/subscriptions/subscriptionId/resourceGroups/clusterResourceGroup" {
+		t.Errorf("got scope %v", properties["scope"])
+	}
+}
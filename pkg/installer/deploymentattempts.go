@@ -0,0 +1,226 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	mgmtfeatures "github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-07-01/features"
+	mgmtstorage "github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2019-06-01/storage"
+	"github.com/Azure/go-autorest/autorest/to"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/util/arm"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/azureerrors"
+)
+
+// maxStageDeployments is how many attempts of each stage are kept in the
+// deployment history of the cluster resource group, which is limited to 800
+// deployments
+const maxStageDeployments = 10
+
+// deploymentAttempt records the outcome of an attempt to deploy a stage.
+// Attempts are pruned from the deployment history, so their records are kept
+// in the "aro" container of the cluster storage account.
+type deploymentAttempt struct {
+	Name              string    `json:"name"`
+	Start             time.Time `json:"start"`
+	End               time.Time `json:"end"`
+	Resumed           bool      `json:"resumed,omitempty"`
+	ProvisioningState string    `json:"provisioningState"`
+	Error             string    `json:"error,omitempty"`
+}
+
+// deploymentName returns the name of a new attempt to deploy stage.  The
+// timestamp has millisecond precision, so that runs starting in the same
+// second don't reuse each other's deployment.
+func (m *manager) deploymentName(stage string) string {
+	return stage + "-" + m.now().UTC().Format("20060102t150405.000z")
+}
+
+// legacyResourcesDeployment is the fixed name the whole resources template
+// was deployed under before it was split into stages
+const legacyResourcesDeployment = "resources"
+
+// legacyStages are the stages split out of legacyResourcesDeployment
+var legacyStages = map[string]struct{}{
+	"resources-network":   {},
+	"resources-bootstrap": {},
+	"resources-masters":   {},
+}
+
+// isStageDeployment returns true if the named deployment is an attempt to
+// deploy stage.  legacyResourcesDeployment counts as an attempt of each of the
+// legacyStages, so that a cluster whose install was started by an older
+// wrapper waits for it, and diffs and prunes it like any other attempt.
+func isStageDeployment(stage, name string) bool {
+	if name == legacyResourcesDeployment {
+		_, found := legacyStages[stage]
+		return found
+	}
+
+	return strings.HasPrefix(name, stage+"-")
+}
+
+// isDeploymentActive returns true if d has not reached a terminal state
+func isDeploymentActive(d mgmtfeatures.DeploymentExtended) bool {
+	if d.Properties == nil {
+		return false
+	}
+
+	switch to.String(d.Properties.ProvisioningState) {
+	case "Succeeded", "Failed", "Canceled":
+		return false
+	}
+
+	return true
+}
+
+// stageDeployments returns the attempts to deploy stage in the resource
+// group, newest first
+func (m *manager) stageDeployments(ctx context.Context, resourceGroup, stage string) ([]mgmtfeatures.DeploymentExtended, error) {
+	deployments, err := m.deployments.ListByResourceGroup(ctx, resourceGroup, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var attempts []mgmtfeatures.DeploymentExtended
+	for _, d := range deployments {
+		if isStageDeployment(stage, to.String(d.Name)) {
+			attempts = append(attempts, d)
+		}
+	}
+
+	timestamp := func(d mgmtfeatures.DeploymentExtended) time.Time {
+		if d.Properties == nil || d.Properties.Timestamp == nil {
+			return time.Time{}
+		}
+		return d.Properties.Timestamp.Time
+	}
+
+	sort.SliceStable(attempts, func(i, j int) bool {
+		return timestamp(attempts[i]).After(timestamp(attempts[j]))
+	})
+
+	return attempts, nil
+}
+
+// pruneStageDeployments deletes the oldest of attempts, so that the
+// deployment history keeps at most maxStageDeployments attempts of a stage,
+// including the attempt about to be made.  Active attempts are never deleted.  Pruning is
+// best effort: failures are only logged.
+func (m *manager) pruneStageDeployments(ctx context.Context, resourceGroup string, attempts []mgmtfeatures.DeploymentExtended) {
+	if len(attempts) < maxStageDeployments {
+		return
+	}
+
+	for _, d := range attempts[maxStageDeployments-1:] {
+		if isDeploymentActive(d) {
+			continue
+		}
+
+		m.log.Printf("pruning deployment %s", to.String(d.Name))
+		err := m.deployments.DeleteAndWait(ctx, resourceGroup, to.String(d.Name))
+		if err != nil {
+			m.log.Warnf("pruning deployment %s: %v", to.String(d.Name), err)
+		}
+	}
+}
+
+// recordDeploymentAttempt persists the outcome of an attempt next to the
+// graph.  Recording is best effort: failures are only logged.
+func (m *manager) recordDeploymentAttempt(ctx context.Context, resourceGroup, account string, attempt *deploymentAttempt, err error) {
+	attempt.End = m.now()
+	attempt.ProvisioningState = "Succeeded"
+	if err != nil {
+		attempt.ProvisioningState = "Failed"
+		attempt.Error = err.Error()
+	}
+
+	m.log.WithField("deployment", attempt.Name).Printf("deployment attempt %s: %s", attempt.Name, attempt.ProvisioningState)
+
+	b, err := json.MarshalIndent(attempt, "", "    ")
+	if err != nil {
+		m.log.Warnf("recording deployment %s: %v", attempt.Name, err)
+		return
+	}
+
+	blob, err := m.storage.Blob(ctx, resourceGroup, account, "aro", "deployments/"+attempt.Name+".json", mgmtstorage.Permissions("cw"))
+	if err == nil {
+		err = blob.CreateBlockBlobFromReader(bytes.NewReader(b), nil)
+	}
+	if err != nil {
+		m.log.Warnf("recording deployment %s: %v", attempt.Name, err)
+	}
+}
+
+// deployResourceStage deploys s as a new attempt.  If the newest attempt of s
+// succeeded, e.g. because another stage failed on the previous run, s is not
+// deployed again.  If it is still running, e.g. because the previous installer
+// run was interrupted, it is waited for instead and a new attempt is only made
+// if it fails.
+func (m *manager) deployResourceStage(ctx context.Context, resourceGroup, account string, s *resourceStage) error {
+	attempts, err := m.stageDeployments(ctx, resourceGroup, s.name)
+	if err != nil {
+		return err
+	}
+
+	if len(attempts) > 0 && attempts[0].Properties != nil &&
+		to.String(attempts[0].Properties.ProvisioningState) == "Succeeded" {
+		m.log.Printf("stage %s was deployed by %s", s.name, to.String(attempts[0].Name))
+		return nil
+	}
+
+	if len(attempts) > 0 && isDeploymentActive(attempts[0]) {
+		attempt := &deploymentAttempt{
+			Name:    to.String(attempts[0].Name),
+			Start:   m.now(),
+			Resumed: true,
+		}
+
+		m.log.Printf("waiting for deployment %s", attempt.Name)
+		err = m.deployments.Wait(ctx, resourceGroup, attempt.Name)
+		m.recordDeploymentAttempt(ctx, resourceGroup, account, attempt, err)
+		if err == nil {
+			return nil
+		}
+
+		m.log.Warnf("deployment %s: %v", attempt.Name, err)
+
+		// the attempt is no longer active, so it may now be pruned
+		attempts[0].Properties.ProvisioningState = to.StringPtr("Failed")
+	}
+
+	m.pruneStageDeployments(ctx, resourceGroup, attempts)
+
+	attempt := &deploymentAttempt{
+		Name:  m.deploymentName(s.name),
+		Start: m.now(),
+	}
+
+	if len(attempts) > 0 {
+		err = m.previewResourceTemplate(ctx, resourceGroup, attempt.Name, s.template, s.parameters)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = arm.DeployTemplate(ctx, m.log, m.deployments, m.deploymentOperations, resourceGroup, attempt.Name, s.template, s.parameters)
+	// the role assignments are named deterministically, but the cluster
+	// identities may already have been granted the same roles under other
+	// names, e.g. by the customer.  The error details are the leaf failures of
+	// the deployment, so any other failure alongside still fails the stage.
+	if s.name == roleAssignmentStageName && azureerrors.IsRoleAssignmentExistsError(err) {
+		m.log.Printf("stage %s: ignoring existing role assignments: %v", s.name, err)
+		err = nil
+	}
+
+	m.recordDeploymentAttempt(ctx, resourceGroup, account, attempt, err)
+
+	return err
+}
@@ -0,0 +1,343 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	mgmtfeatures "github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-07-01/features"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/date"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+
+	mock_features "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/azureclient/mgmt/features"
+	mock_storage "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/storage"
+)
+
+func deployment(name, provisioningState string, timestamp time.Time) mgmtfeatures.DeploymentExtended {
+	return mgmtfeatures.DeploymentExtended{
+		Name: to.StringPtr(name),
+		Properties: &mgmtfeatures.DeploymentPropertiesExtended{
+			ProvisioningState: to.StringPtr(provisioningState),
+			Timestamp:         &date.Time{Time: timestamp},
+		},
+	}
+}
+
+func TestDeploymentName(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := &manager{
+		now: func() time.Time { return now },
+	}
+
+	first := m.deploymentName("resources-network")
+	if first != "resources-network-20260101t000000.000z" {
+		t.Error(first)
+	}
+
+	// a second run starting in the same second
+	now = now.Add(time.Millisecond)
+	if second := m.deploymentName("resources-network"); second == first {
+		t.Error(second)
+	}
+}
+
+func TestStageDeployments(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	deployments := mock_features.NewMockDeploymentsClient(controller)
+	deployments.EXPECT().ListByResourceGroup(ctx, "clusterResourceGroup", "", nil).
+		Return([]mgmtfeatures.DeploymentExtended{
+			deployment("resources-network-20251231t220000.000z", "Failed", now.Add(-2*time.Hour)),
+			deployment("resources-bootstrap-20260101t000000.000z", "Succeeded", now),
+			deployment("resources-network-20260101t000000.000z", "Running", now),
+			deployment("resources", "Failed", now.Add(-3*time.Hour)),
+			deployment("resources-network-20251231t230000.000z", "Failed", now.Add(-time.Hour)),
+		}, nil).
+		AnyTimes()
+
+	m := &manager{
+		deployments: deployments,
+	}
+
+	for stage, wantNames := range map[string][]string{
+		"resources-network": {
+			"resources-network-20260101t000000.000z",
+			"resources-network-20251231t230000.000z",
+			"resources-network-20251231t220000.000z",
+			"resources",
+		},
+		"resources-bootstrap": {
+			"resources-bootstrap-20260101t000000.000z",
+			"resources",
+		},
+		// the legacy deployment never created role assignments
+		"resources-roleassignments": nil,
+	} {
+		attempts, err := m.stageDeployments(ctx, "clusterResourceGroup", stage)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var names []string
+		for _, d := range attempts {
+			names = append(names, *d.Name)
+		}
+
+		if !reflect.DeepEqual(names, wantNames) {
+			t.Errorf("%s: %v", stage, names)
+		}
+	}
+}
+
+func TestDeployResourceStage(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	newName := "resources-network-20260101t000000.000z"
+	roleAssignmentsExist := &azure.ServiceError{
+		Code: "DeploymentFailed",
+		Details: []map[string]interface{}{
+			{
+				"code":    "RoleAssignmentExists",
+				"message": "The role assignment already exists.",
+			},
+		},
+	}
+
+	for _, tt := range []struct {
+		name    string
+		stage   string
+		mocks   func(*mock_features.MockDeploymentsClient)
+		wantErr string
+	}{
+		{
+			name: "first attempt",
+			mocks: func(deployments *mock_features.MockDeploymentsClient) {
+				deployments.EXPECT().ListByResourceGroup(ctx, "clusterResourceGroup", "", nil).
+					Return(nil, nil)
+				deployments.EXPECT().CreateOrUpdateAndWait(ctx, "clusterResourceGroup", newName, gomock.Any()).
+					Return(nil)
+			},
+		},
+		{
+			name: "succeeded attempt is not repeated",
+			mocks: func(deployments *mock_features.MockDeploymentsClient) {
+				deployments.EXPECT().ListByResourceGroup(ctx, "clusterResourceGroup", "", nil).
+					Return([]mgmtfeatures.DeploymentExtended{
+						deployment("resources-network-20251231t230000.000z", "Succeeded", now.Add(-time.Hour)),
+						deployment("resources-network-20251231t220000.000z", "Failed", now.Add(-2*time.Hour)),
+					}, nil)
+			},
+		},
+		{
+			name: "active attempt is resumed",
+			mocks: func(deployments *mock_features.MockDeploymentsClient) {
+				deployments.EXPECT().ListByResourceGroup(ctx, "clusterResourceGroup", "", nil).
+					Return([]mgmtfeatures.DeploymentExtended{
+						deployment("resources-network-20251231t230000.000z", "Running", now.Add(-time.Hour)),
+					}, nil)
+				deployments.EXPECT().Wait(ctx, "clusterResourceGroup", "resources-network-20251231t230000.000z").
+					Return(nil)
+			},
+		},
+		{
+			name: "active legacy deployment is resumed",
+			mocks: func(deployments *mock_features.MockDeploymentsClient) {
+				deployments.EXPECT().ListByResourceGroup(ctx, "clusterResourceGroup", "", nil).
+					Return([]mgmtfeatures.DeploymentExtended{
+						deployment("resources", "Running", now.Add(-time.Hour)),
+					}, nil)
+				deployments.EXPECT().Wait(ctx, "clusterResourceGroup", "resources").
+					Return(nil)
+			},
+		},
+		{
+			name: "failed resumed attempt is retried",
+			mocks: func(deployments *mock_features.MockDeploymentsClient) {
+				deployments.EXPECT().ListByResourceGroup(ctx, "clusterResourceGroup", "", nil).
+					Return([]mgmtfeatures.DeploymentExtended{
+						deployment("resources-network-20251231t230000.000z", "Running", now.Add(-time.Hour)),
+					}, nil)
+				deployments.EXPECT().Wait(ctx, "clusterResourceGroup", "resources-network-20251231t230000.000z").
+					Return(errors.New("got provisioningState \"Failed\""))
+				deployments.EXPECT().WhatIfAndWait(ctx, "clusterResourceGroup", newName, gomock.Any()).
+					Return(mgmtfeatures.WhatIfOperationResult{}, nil)
+				deployments.EXPECT().CreateOrUpdateAndWait(ctx, "clusterResourceGroup", newName, gomock.Any()).
+					Return(nil)
+			},
+		},
+		{
+			name: "old attempts are pruned",
+			mocks: func(deployments *mock_features.MockDeploymentsClient) {
+				var attempts []mgmtfeatures.DeploymentExtended
+				for i := 0; i < maxStageDeployments+1; i++ {
+					timestamp := now.Add(-time.Duration(i+1) * time.Hour)
+					attempts = append(attempts, deployment(fmt.Sprintf("resources-network-%d", i), "Failed", timestamp))
+				}
+				attempts[maxStageDeployments].Properties.ProvisioningState = to.StringPtr("Running")
+
+				deployments.EXPECT().ListByResourceGroup(ctx, "clusterResourceGroup", "", nil).
+					Return(attempts, nil)
+				deployments.EXPECT().DeleteAndWait(ctx, "clusterResourceGroup", fmt.Sprintf("resources-network-%d", maxStageDeployments-1)).
+					Return(errors.New("random error"))
+				deployments.EXPECT().WhatIfAndWait(ctx, "clusterResourceGroup", newName, gomock.Any()).
+					Return(mgmtfeatures.WhatIfOperationResult{}, nil)
+				deployments.EXPECT().CreateOrUpdateAndWait(ctx, "clusterResourceGroup", newName, gomock.Any()).
+					Return(nil)
+			},
+		},
+		{
+			name:  "existing role assignments are accepted",
+			stage: roleAssignmentStageName,
+			mocks: func(deployments *mock_features.MockDeploymentsClient) {
+				deployments.EXPECT().ListByResourceGroup(ctx, "clusterResourceGroup", "", nil).
+					Return(nil, nil)
+				deployments.EXPECT().CreateOrUpdateAndWait(ctx, "clusterResourceGroup", "resources-roleassignments-20260101t000000.000z", gomock.Any()).
+					Return(roleAssignmentsExist)
+			},
+		},
+		{
+			name:  "existing role assignments don't hide other failures",
+			stage: roleAssignmentStageName,
+			mocks: func(deployments *mock_features.MockDeploymentsClient) {
+				deployments.EXPECT().ListByResourceGroup(ctx, "clusterResourceGroup", "", nil).
+					Return(nil, nil)
+				deployments.EXPECT().CreateOrUpdateAndWait(ctx, "clusterResourceGroup", "resources-roleassignments-20260101t000000.000z", gomock.Any()).
+					Return(&azure.ServiceError{
+						Code: "DeploymentFailed",
+						Details: []map[string]interface{}{
+							{
+								"code":    "DeploymentFailed",
+								"message": "At least one resource deployment operation failed.",
+								"details": []interface{}{
+									map[string]interface{}{
+										"code":    "RoleAssignmentExists",
+										"message": "The role assignment already exists.",
+									},
+									map[string]interface{}{
+										"code":    "AuthorizationFailed",
+										"message": "The client does not have authorization.",
+									},
+								},
+							},
+						},
+					})
+			},
+			wantErr: "400: DeploymentFailed: : Deployment failed. Details: RoleAssignmentExists: : The role assignment already exists., AuthorizationFailed: : The client does not have authorization.",
+		},
+		{
+			name: "existing role assignments fail other stages",
+			mocks: func(deployments *mock_features.MockDeploymentsClient) {
+				deployments.EXPECT().ListByResourceGroup(ctx, "clusterResourceGroup", "", nil).
+					Return(nil, nil)
+				deployments.EXPECT().CreateOrUpdateAndWait(ctx, "clusterResourceGroup", newName, gomock.Any()).
+					Return(roleAssignmentsExist)
+			},
+			wantErr: "400: DeploymentFailed: : Deployment failed. Details: RoleAssignmentExists: : The role assignment already exists.",
+		},
+		{
+			name: "failing deployment",
+			mocks: func(deployments *mock_features.MockDeploymentsClient) {
+				deployments.EXPECT().ListByResourceGroup(ctx, "clusterResourceGroup", "", nil).
+					Return(nil, nil)
+				deployments.EXPECT().CreateOrUpdateAndWait(ctx, "clusterResourceGroup", newName, gomock.Any()).
+					Return(&azure.ServiceError{
+						Code: "DeploymentFailed",
+						Details: []map[string]interface{}{
+							{
+								"code":    "InternalServerError",
+								"message": "An error has occurred.",
+							},
+						},
+					})
+			},
+			wantErr: "400: DeploymentFailed: : Deployment failed. Details: InternalServerError: : An error has occurred.",
+		},
+		{
+			name: "listing fails",
+			mocks: func(deployments *mock_features.MockDeploymentsClient) {
+				deployments.EXPECT().ListByResourceGroup(ctx, "clusterResourceGroup", "", nil).
+					Return(nil, errors.New("random error"))
+			},
+			wantErr: "random error",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			deployments := mock_features.NewMockDeploymentsClient(controller)
+			tt.mocks(deployments)
+
+			storage := mock_storage.NewMockManager(controller)
+			storage.EXPECT().Blob(ctx, "clusterResourceGroup", "cluster", "aro", gomock.Any(), gomock.Any()).
+				Return(nil, errors.New("no storage")).AnyTimes()
+
+			m := &manager{
+				log:         logrus.NewEntry(logrus.StandardLogger()),
+				deployments: deployments,
+				storage:     storage,
+				now:         func() time.Time { return now },
+			}
+
+			stage := "resources-network"
+			if tt.stage != "" {
+				stage = tt.stage
+			}
+
+			err := m.deployResourceStage(ctx, "clusterResourceGroup", "cluster", newResourceStage(stage))
+			if err != nil && err.Error() != tt.wantErr ||
+				err == nil && tt.wantErr != "" {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestDeployResourceStages(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	// a failing stage must not interrupt the other
+	deployments := mock_features.NewMockDeploymentsClient(controller)
+	deployments.EXPECT().ListByResourceGroup(ctx, "clusterResourceGroup", "", nil).
+		Return(nil, nil).Times(2)
+	deployments.EXPECT().CreateOrUpdateAndWait(ctx, "clusterResourceGroup", "resources-bootstrap-20260101t000000.000z", gomock.Any()).
+		Return(errors.New("random error"))
+	deployments.EXPECT().CreateOrUpdateAndWait(ctx, "clusterResourceGroup", "resources-masters-20260101t000000.000z", gomock.Any()).
+		Return(nil)
+
+	storage := mock_storage.NewMockManager(controller)
+	storage.EXPECT().Blob(ctx, "clusterResourceGroup", "cluster", "aro", gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("no storage")).AnyTimes()
+
+	m := &manager{
+		log:         logrus.NewEntry(logrus.StandardLogger()),
+		deployments: deployments,
+		storage:     storage,
+		now:         func() time.Time { return now },
+	}
+
+	err := m.deployResourceStages(ctx, "clusterResourceGroup", "cluster", []*resourceStage{
+		newResourceStage("resources-bootstrap"),
+		newResourceStage("resources-masters"),
+	})
+	if err == nil || err.Error() != "random error" {
+		t.Error(err)
+	}
+}
@@ -5,14 +5,18 @@ package installer
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"reflect"
-	"time"
+	"sync"
 
 	"github.com/openshift/installer/pkg/asset/ignition/machine"
 	"github.com/openshift/installer/pkg/asset/installconfig"
 
+	"github.com/openshift/installer-aro-wrapper/pkg/cluster/graph"
+	"github.com/openshift/installer-aro-wrapper/pkg/env"
 	"github.com/openshift/installer-aro-wrapper/pkg/util/arm"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/arm/expression"
 	"github.com/openshift/installer-aro-wrapper/pkg/util/stringutils"
 )
 
@@ -27,43 +31,211 @@ func (m *manager) deployResourceTemplate(ctx context.Context) error {
 
 	var installConfig *installconfig.InstallConfig
 	var machineMaster *machine.Master
-	err = pg.Get(&installConfig, &machineMaster)
+	var machineWorker *machine.Worker
+	err = pg.Get(&installConfig, &machineMaster, &machineWorker)
 	if err != nil {
 		return err
 	}
 
-	zones, err := zones(installConfig)
+	inlineMaster, ignitionHashes, err := m.persistedIgnition(ctx, resourceGroup, account, pg, machineMaster)
 	if err != nil {
 		return err
 	}
 
-	t := &arm.Template{
-		Schema:         "https://schema.management.azure.com/schemas/2015-01-01/deploymentTemplate.json#",
-		ContentVersion: "1.0.0.0",
-		Parameters: map[string]*arm.TemplateParameter{
-			"sas": {
-				Type: "object",
-			},
-		},
-		Resources: []*arm.Resource{
-			m.networkBootstrapNIC(installConfig),
-			m.networkMasterNICs(installConfig),
-			m.computeBootstrapVM(installConfig),
-			m.computeMasterVMs(installConfig, zones, machineMaster),
-		},
+	stages, err := m.resourceStages(ctx, installConfig, inlineMaster, machineWorker, ignitionHashes)
+	if err != nil {
+		return err
+	}
+
+	// the network infrastructure is normally created by the RP
+	infrastructure, err := m.infrastructureStage(ctx, resourceGroup, installConfig)
+	if err != nil {
+		return err
+	}
+	if infrastructure != nil {
+		stages = append([][]*resourceStage{{infrastructure}}, stages...)
 	}
-	return arm.DeployTemplate(ctx, m.log, m.deployments, resourceGroup, "resources", t, map[string]interface{}{
-		"sas": map[string]interface{}{
-			"value": map[string]interface{}{
-				"signedStart":         m.oc.Properties.Install.Now.Format(time.RFC3339),
-				"signedExpiry":        m.oc.Properties.Install.Now.Add(24 * time.Hour).Format(time.RFC3339),
-				"signedPermission":    "rl",
-				"signedResourceTypes": "o",
-				"signedServices":      "b",
-				"signedProtocol":      "https",
-			},
+
+	for _, concurrent := range stages {
+		for _, s := range concurrent {
+			err = arm.Validate(s.template)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, concurrent := range stages {
+		err = m.deployResourceStages(ctx, resourceGroup, account, concurrent)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = m.attachNetworkSecurityGroups(ctx)
+	if err != nil {
+		return err
+	}
+
+	return m.setAPIServerPrivateEndpointIP(ctx, resourceGroup)
+}
+
+// persistedIgnition returns the hashes of the ignition configs uploaded with
+// the graph, and the master config if it must be passed inline.  Graphs
+// persisted by older versions don't record the hashes, in which case the
+// pointer configs are not verified.  The oldest of them were saved without
+// uploading master.ign either, in which case the master config is inline.
+func (m *manager) persistedIgnition(ctx context.Context, resourceGroup, account string, pg graph.PersistedGraph, machineMaster *machine.Master) (*machine.Master, *graph.IgnitionHashes, error) {
+	var ignitionHashes *graph.IgnitionHashes
+	if pg.Has(&ignitionHashes) {
+		err := pg.Get(&ignitionHashes)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		ignitionHashes = &graph.IgnitionHashes{}
+	}
+
+	// Save records the hashes of the configs it uploads
+	if ignitionHashes.Master != "" {
+		return nil, ignitionHashes, nil
+	}
+
+	exists, err := m.ignitionBlobExists(ctx, resourceGroup, account, "master.ign")
+	if err != nil {
+		return nil, nil, err
+	}
+	if exists {
+		return nil, ignitionHashes, nil
+	}
+
+	m.log.Print("master.ign was not uploaded, passing the master ignition config inline")
+	return machineMaster, ignitionHashes, nil
+}
+
+// resourceStage is a deployment creating some of the resources of the
+// cluster.  Each stage is deployed, retried and recorded on its own.
+type resourceStage struct {
+	name       string
+	template   *arm.Template
+	parameters map[string]interface{}
+}
+
+func newResourceStage(name string, resources ...*arm.Resource) *resourceStage {
+	return &resourceStage{
+		name: name,
+		template: &arm.Template{
+			Schema:         "https://schema.management.azure.com/schemas/2015-01-01/deploymentTemplate.json#",
+			ContentVersion: "1.0.0.0",
+			Parameters:     map[string]*arm.TemplateParameter{},
+			Resources:      resources,
 		},
-	})
+		parameters: map[string]interface{}{},
+	}
+}
+
+// addIgnitionSASParameter adds the parameter holding the SAS of the blob
+// ignition pointer config to s
+func (m *manager) addIgnitionSASParameter(s *resourceStage, blob string) {
+	s.template.Parameters[ignitionSASParameter(blob)] = &arm.TemplateParameter{
+		Type: "object",
+	}
+	s.parameters[ignitionSASParameter(blob)] = map[string]interface{}{
+		"value": m.ignitionSASParameters(blob),
+	}
+}
+
+// deployResourceStages deploys stages concurrently.  The stages are
+// independent, so a failing stage does not interrupt the others, and the
+// first error in stage order is returned once all have finished.
+func (m *manager) deployResourceStages(ctx context.Context, resourceGroup, account string, stages []*resourceStage) error {
+	errs := make([]error, len(stages))
+
+	var wg sync.WaitGroup
+	for i, s := range stages {
+		wg.Add(1)
+		go func(i int, s *resourceStage) {
+			defer wg.Done()
+			errs[i] = m.deployResourceStage(ctx, resourceGroup, account, s)
+		}(i, s)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resourceStages returns the stages creating the bootstrap and master VMs.
+// The stages of each element are deployed concurrently, after those of the
+// previous element: first the network interfaces, deny assignment and role
+// assignments, then the bootstrap VM and the master VMs.  If inlineMaster is
+// set, the masters get it as customData instead of a pointer to master.ign.
+func (m *manager) resourceStages(ctx context.Context, installConfig *installconfig.InstallConfig, inlineMaster *machine.Master, machineWorker *machine.Worker, ignitionHashes *graph.IgnitionHashes) ([][]*resourceStage, error) {
+	bootstrapCustomData, err := m.ignitionPointerCustomData("bootstrap.ign", ignitionHashes.Bootstrap, installConfig.Config.AdditionalTrustBundle)
+	if err != nil {
+		return nil, err
+	}
+
+	var masterCustomData string
+	if inlineMaster != nil {
+		err = checkCustomDataSize("master", len(inlineMaster.File.Data))
+		masterCustomData = base64.StdEncoding.EncodeToString(inlineMaster.File.Data)
+	} else {
+		masterCustomData, err = m.ignitionPointerCustomData("master.ign", ignitionHashes.Master, installConfig.Config.AdditionalTrustBundle)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// workers are created later by the machine API, which keeps using the
+	// worker pointer config inline: a SAS-scoped pointer would expire.  It
+	// only points to the machine config server, so just its size is checked.
+	err = checkCustomDataSize("worker", len(machineWorker.File.Data))
+	if err != nil {
+		return nil, err
+	}
+
+	zones, err := zones(installConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	network := newResourceStage("resources-network",
+		m.networkBootstrapNIC(installConfig),
+		m.networkMasterNICs(installConfig),
+	)
+
+	if !m.env.FeatureIsSet(env.FeatureDisableDenyAssignments) {
+		network.template.Resources = append(network.template.Resources, m.denyAssignment())
+	}
+
+	bootstrap := newResourceStage("resources-bootstrap", m.computeBootstrapVM(installConfig, bootstrapCustomData))
+	m.addIgnitionSASParameter(bootstrap, "bootstrap.ign")
+
+	masters := newResourceStage("resources-masters", m.computeMasterVMs(installConfig, zones, masterCustomData))
+	if inlineMaster == nil {
+		m.addIgnitionSASParameter(masters, "master.ign")
+	}
+
+	roleAssignments, err := m.roleAssignmentStage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	first := []*resourceStage{network}
+	if roleAssignments != nil {
+		first = append(first, roleAssignments)
+	}
+
+	return [][]*resourceStage{
+		first,
+		{bootstrap, masters},
+	}, nil
 }
 
 // zones configures how master nodes are distributed across availability zones. In regions where the number of zones matches
@@ -80,7 +252,7 @@ func zones(installConfig *installconfig.InstallConfig) (zones *[]string, err err
 	} else if zoneCount <= 2 {
 		zones = &installConfig.Config.ControlPlane.Platform.Azure.Zones
 	} else {
-		zones = &[]string{"[string(copyIndex(1))]"}
+		zones = &[]string{expression.Format(expression.ToString(expression.CopyIndex(1)))}
 	}
 
 	return
@@ -0,0 +1,605 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	mgmtnetwork "github.com/Azure/azure-sdk-for-go/services/network/mgmt/2020-08-01/network"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/openshift/installer/pkg/asset/installconfig"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/arm"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/arm/expression"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/azureclient"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/azureerrors"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/stringutils"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/subnet"
+)
+
+// loadBalancerSubResourceID returns the ARM expression of the ID of the named
+// child of the kind (e.g. probes) of load balancer lb
+func loadBalancerSubResourceID(kind, lb, name string) *string {
+	return expression.FormatPtr(expression.ResourceID("Microsoft.Network/loadBalancers/"+kind, expression.Literal(lb), expression.Literal(name)))
+}
+
+// infrastructureResources returns the network infrastructure the cluster VMs
+// are attached to, which the RP normally creates before running the
+// installer: the NSGs, the internal and public load balancers and their
+// public IPs, and the private link service through which the RP reaches the
+// API server, with a private endpoint to it.
+func (m *manager) infrastructureResources(installConfig *installconfig.InstallConfig) ([]*arm.Resource, error) {
+	resources, err := m.networkSecurityGroups(installConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	pls, err := m.networkPrivateLinkService(installConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	resources = append(resources,
+		m.networkInternalLoadBalancer(installConfig),
+		pls,
+		m.networkPrivateEndpoint(installConfig),
+	)
+
+	for _, name := range m.publicIPAddressNames() {
+		resources = append(resources, m.networkPublicIPAddress(installConfig, name))
+	}
+
+	if m.oc.Properties.APIServerProfile.Visibility == api.VisibilityPublic ||
+		m.oc.Properties.NetworkProfile.OutboundType == api.OutboundTypeLoadbalancer {
+		resources = append(resources, m.networkPublicLoadBalancer(installConfig))
+	}
+
+	return resources, nil
+}
+
+// clusterSubnetIDs returns the master and worker subnets of the cluster
+func (m *manager) clusterSubnetIDs() []string {
+	subnetIDs := []string{m.oc.Properties.MasterProfile.SubnetID}
+	for _, wp := range m.oc.Properties.WorkerProfiles {
+		subnetIDs = append(subnetIDs, wp.SubnetID)
+	}
+
+	return subnetIDs
+}
+
+// networkSecurityGroups returns the NSGs of the master and worker subnets,
+// which are one and the same from architecture version 2
+func (m *manager) networkSecurityGroups(installConfig *installconfig.InstallConfig) ([]*arm.Resource, error) {
+	var resources []*arm.Resource
+	seen := map[string]struct{}{}
+	for _, subnetID := range m.clusterSubnetIDs() {
+		nsgID, err := subnet.NetworkSecurityGroupID(m.oc, subnetID)
+		if err != nil {
+			return nil, err
+		}
+
+		name := stringutils.LastTokenByte(nsgID, '/')
+		if _, found := seen[strings.ToLower(name)]; found {
+			continue
+		}
+		seen[strings.ToLower(name)] = struct{}{}
+
+		resources = append(resources, m.networkSecurityGroup(installConfig, name))
+	}
+
+	return resources, nil
+}
+
+func (m *manager) networkSecurityGroup(installConfig *installconfig.InstallConfig, name string) *arm.Resource {
+	nsg := &mgmtnetwork.SecurityGroup{
+		SecurityGroupPropertiesFormat: &mgmtnetwork.SecurityGroupPropertiesFormat{},
+		Name:                          to.StringPtr(name),
+		Type:                          to.StringPtr("Microsoft.Network/networkSecurityGroups"),
+		Location:                      &installConfig.Config.Azure.Region,
+	}
+
+	if m.oc.Properties.APIServerProfile.Visibility == api.VisibilityPublic {
+		nsg.SecurityRules = &[]mgmtnetwork.SecurityRule{
+			{
+				SecurityRulePropertiesFormat: &mgmtnetwork.SecurityRulePropertiesFormat{
+					Protocol:                 mgmtnetwork.SecurityRuleProtocolTCP,
+					SourcePortRange:          to.StringPtr("*"),
+					DestinationPortRange:     to.StringPtr("6443"),
+					SourceAddressPrefix:      to.StringPtr("*"),
+					DestinationAddressPrefix: to.StringPtr("*"),
+					Access:                   mgmtnetwork.SecurityRuleAccessAllow,
+					Priority:                 to.Int32Ptr(120),
+					Direction:                mgmtnetwork.SecurityRuleDirectionInbound,
+				},
+				Name: to.StringPtr("apiserver_in"),
+			},
+		}
+	}
+
+	return &arm.Resource{
+		Resource:   nsg,
+		APIVersion: azureclient.APIVersion("Microsoft.Network"),
+	}
+}
+
+// publicIPAddressNames returns the names of the public IPs of the public load
+// balancer: the API server's for public clusters, otherwise the outbound one
+// if the cluster egresses through the load balancer
+func (m *manager) publicIPAddressNames() []string {
+	if m.oc.Properties.APIServerProfile.Visibility == api.VisibilityPublic {
+		return []string{m.oc.Properties.InfraID + "-pip-v4"}
+	}
+
+	if m.oc.Properties.NetworkProfile.OutboundType == api.OutboundTypeLoadbalancer {
+		return []string{m.oc.Properties.InfraID + "-default-v4"}
+	}
+
+	return nil
+}
+
+func (m *manager) networkPublicIPAddress(installConfig *installconfig.InstallConfig, name string) *arm.Resource {
+	return &arm.Resource{
+		Resource: &mgmtnetwork.PublicIPAddress{
+			Sku: &mgmtnetwork.PublicIPAddressSku{
+				Name: mgmtnetwork.PublicIPAddressSkuNameStandard,
+			},
+			PublicIPAddressPropertiesFormat: &mgmtnetwork.PublicIPAddressPropertiesFormat{
+				PublicIPAllocationMethod: mgmtnetwork.Static,
+				PublicIPAddressVersion:   mgmtnetwork.IPv4,
+			},
+			Name:     to.StringPtr(name),
+			Type:     to.StringPtr("Microsoft.Network/publicIPAddresses"),
+			Location: &installConfig.Config.Azure.Region,
+		},
+		APIVersion: azureclient.APIVersion("Microsoft.Network"),
+	}
+}
+
+// networkInternalLoadBalancer returns the load balancer of the API server and
+// machine config server on the master subnet, which also gives SSH access to
+// each master through port 2200 + its index
+func (m *manager) networkInternalLoadBalancer(installConfig *installconfig.InstallConfig) *arm.Resource {
+	name := m.oc.Properties.InfraID + "-internal"
+
+	frontend := &mgmtnetwork.FrontendIPConfigurationPropertiesFormat{
+		PrivateIPAllocationMethod: mgmtnetwork.Dynamic,
+		Subnet: &mgmtnetwork.Subnet{
+			ID: &m.oc.Properties.MasterProfile.SubnetID,
+		},
+	}
+	if m.oc.Properties.APIServerProfile.IntIP != "" {
+		frontend.PrivateIPAllocationMethod = mgmtnetwork.Static
+		frontend.PrivateIPAddress = &m.oc.Properties.APIServerProfile.IntIP
+	}
+
+	backendAddressPools := []mgmtnetwork.BackendAddressPool{
+		{
+			Name: &m.oc.Properties.InfraID,
+		},
+	}
+
+	rule := func(ruleName, pool, probe string, frontendPort, backendPort int32) mgmtnetwork.LoadBalancingRule {
+		return mgmtnetwork.LoadBalancingRule{
+			LoadBalancingRulePropertiesFormat: &mgmtnetwork.LoadBalancingRulePropertiesFormat{
+				FrontendIPConfiguration: &mgmtnetwork.SubResource{
+					ID: loadBalancerSubResourceID("frontendIPConfigurations", name, "internal-lb-ip-v4"),
+				},
+				BackendAddressPool: &mgmtnetwork.SubResource{
+					ID: loadBalancerSubResourceID("backendAddressPools", name, pool),
+				},
+				Probe: &mgmtnetwork.SubResource{
+					ID: loadBalancerSubResourceID("probes", name, probe),
+				},
+				Protocol:             mgmtnetwork.TransportProtocolTCP,
+				LoadDistribution:     mgmtnetwork.LoadDistributionDefault,
+				FrontendPort:         to.Int32Ptr(frontendPort),
+				BackendPort:          to.Int32Ptr(backendPort),
+				IdleTimeoutInMinutes: to.Int32Ptr(30),
+				DisableOutboundSnat:  to.BoolPtr(true),
+			},
+			Name: to.StringPtr(ruleName),
+		}
+	}
+
+	loadBalancingRules := []mgmtnetwork.LoadBalancingRule{
+		rule("api-internal-v4", m.oc.Properties.InfraID, "api-internal-probe", 6443, 6443),
+		rule("sint-v4", m.oc.Properties.InfraID, "sint-probe", 22623, 22623),
+	}
+
+	for i := 0; i < int(*installConfig.Config.ControlPlane.Replicas); i++ {
+		pool := fmt.Sprintf("ssh-%d", i)
+		backendAddressPools = append(backendAddressPools, mgmtnetwork.BackendAddressPool{
+			Name: to.StringPtr(pool),
+		})
+		loadBalancingRules = append(loadBalancingRules, rule(pool, pool, "ssh", 2200+int32(i), 22))
+	}
+
+	return &arm.Resource{
+		Resource: &mgmtnetwork.LoadBalancer{
+			Sku: &mgmtnetwork.LoadBalancerSku{
+				Name: mgmtnetwork.LoadBalancerSkuNameStandard,
+			},
+			LoadBalancerPropertiesFormat: &mgmtnetwork.LoadBalancerPropertiesFormat{
+				FrontendIPConfigurations: &[]mgmtnetwork.FrontendIPConfiguration{
+					{
+						FrontendIPConfigurationPropertiesFormat: frontend,
+						Name:                                    to.StringPtr("internal-lb-ip-v4"),
+					},
+				},
+				BackendAddressPools: &backendAddressPools,
+				LoadBalancingRules:  &loadBalancingRules,
+				Probes: &[]mgmtnetwork.Probe{
+					{
+						ProbePropertiesFormat: &mgmtnetwork.ProbePropertiesFormat{
+							Protocol:          mgmtnetwork.ProbeProtocolHTTPS,
+							Port:              to.Int32Ptr(6443),
+							IntervalInSeconds: to.Int32Ptr(5),
+							NumberOfProbes:    to.Int32Ptr(2),
+							RequestPath:       to.StringPtr("/readyz"),
+						},
+						Name: to.StringPtr("api-internal-probe"),
+					},
+					{
+						ProbePropertiesFormat: &mgmtnetwork.ProbePropertiesFormat{
+							Protocol:          mgmtnetwork.ProbeProtocolHTTPS,
+							Port:              to.Int32Ptr(22623),
+							IntervalInSeconds: to.Int32Ptr(5),
+							NumberOfProbes:    to.Int32Ptr(2),
+							RequestPath:       to.StringPtr("/healthz"),
+						},
+						Name: to.StringPtr("sint-probe"),
+					},
+					{
+						ProbePropertiesFormat: &mgmtnetwork.ProbePropertiesFormat{
+							Protocol:          mgmtnetwork.ProbeProtocolTCP,
+							Port:              to.Int32Ptr(22),
+							IntervalInSeconds: to.Int32Ptr(5),
+							NumberOfProbes:    to.Int32Ptr(2),
+						},
+						Name: to.StringPtr("ssh"),
+					},
+				},
+			},
+			Name:     to.StringPtr(name),
+			Type:     to.StringPtr("Microsoft.Network/loadBalancers"),
+			Location: &installConfig.Config.Azure.Region,
+		},
+		APIVersion: azureclient.APIVersion("Microsoft.Network"),
+	}
+}
+
+// networkPublicLoadBalancer returns the load balancer named after the
+// InfraID, which exposes the API server if it is public, and gives the nodes
+// outbound connectivity if the cluster doesn't use user defined routing
+func (m *manager) networkPublicLoadBalancer(installConfig *installconfig.InstallConfig) *arm.Resource {
+	name := m.oc.Properties.InfraID
+
+	lb := &mgmtnetwork.LoadBalancer{
+		Sku: &mgmtnetwork.LoadBalancerSku{
+			Name: mgmtnetwork.LoadBalancerSkuNameStandard,
+		},
+		LoadBalancerPropertiesFormat: &mgmtnetwork.LoadBalancerPropertiesFormat{
+			FrontendIPConfigurations: &[]mgmtnetwork.FrontendIPConfiguration{},
+			BackendAddressPools: &[]mgmtnetwork.BackendAddressPool{
+				{
+					Name: &m.oc.Properties.InfraID,
+				},
+			},
+			LoadBalancingRules: &[]mgmtnetwork.LoadBalancingRule{},
+			Probes:             &[]mgmtnetwork.Probe{},
+		},
+		Name:     to.StringPtr(name),
+		Type:     to.StringPtr("Microsoft.Network/loadBalancers"),
+		Location: &installConfig.Config.Azure.Region,
+	}
+
+	frontendName, publicIPAddressName := "outbound-lb-ip-v4", m.oc.Properties.InfraID+"-default-v4"
+	if m.oc.Properties.APIServerProfile.Visibility == api.VisibilityPublic {
+		frontendName, publicIPAddressName = "public-lb-ip-v4", m.oc.Properties.InfraID+"-pip-v4"
+
+		*lb.LoadBalancingRules = append(*lb.LoadBalancingRules, mgmtnetwork.LoadBalancingRule{
+			LoadBalancingRulePropertiesFormat: &mgmtnetwork.LoadBalancingRulePropertiesFormat{
+				FrontendIPConfiguration: &mgmtnetwork.SubResource{
+					ID: loadBalancerSubResourceID("frontendIPConfigurations", name, frontendName),
+				},
+				BackendAddressPool: &mgmtnetwork.SubResource{
+					ID: loadBalancerSubResourceID("backendAddressPools", name, m.oc.Properties.InfraID),
+				},
+				Probe: &mgmtnetwork.SubResource{
+					ID: loadBalancerSubResourceID("probes", name, "api-internal-probe"),
+				},
+				Protocol:             mgmtnetwork.TransportProtocolTCP,
+				LoadDistribution:     mgmtnetwork.LoadDistributionDefault,
+				FrontendPort:         to.Int32Ptr(6443),
+				BackendPort:          to.Int32Ptr(6443),
+				IdleTimeoutInMinutes: to.Int32Ptr(30),
+				DisableOutboundSnat:  to.BoolPtr(true),
+			},
+			Name: to.StringPtr("api-internal-v4"),
+		})
+
+		*lb.Probes = append(*lb.Probes, mgmtnetwork.Probe{
+			ProbePropertiesFormat: &mgmtnetwork.ProbePropertiesFormat{
+				Protocol:          mgmtnetwork.ProbeProtocolHTTPS,
+				Port:              to.Int32Ptr(6443),
+				IntervalInSeconds: to.Int32Ptr(5),
+				NumberOfProbes:    to.Int32Ptr(2),
+				RequestPath:       to.StringPtr("/readyz"),
+			},
+			Name: to.StringPtr("api-internal-probe"),
+		})
+	}
+
+	*lb.FrontendIPConfigurations = append(*lb.FrontendIPConfigurations, mgmtnetwork.FrontendIPConfiguration{
+		FrontendIPConfigurationPropertiesFormat: &mgmtnetwork.FrontendIPConfigurationPropertiesFormat{
+			PublicIPAddress: &mgmtnetwork.PublicIPAddress{
+				ID: expression.FormatPtr(expression.ResourceID("Microsoft.Network/publicIPAddresses", expression.Literal(publicIPAddressName))),
+			},
+		},
+		Name: to.StringPtr(frontendName),
+	})
+
+	if m.oc.Properties.NetworkProfile.OutboundType == api.OutboundTypeLoadbalancer {
+		lb.OutboundRules = &[]mgmtnetwork.OutboundRule{
+			{
+				OutboundRulePropertiesFormat: &mgmtnetwork.OutboundRulePropertiesFormat{
+					FrontendIPConfigurations: &[]mgmtnetwork.SubResource{
+						{
+							ID: loadBalancerSubResourceID("frontendIPConfigurations", name, frontendName),
+						},
+					},
+					BackendAddressPool: &mgmtnetwork.SubResource{
+						ID: loadBalancerSubResourceID("backendAddressPools", name, m.oc.Properties.InfraID),
+					},
+					Protocol:             mgmtnetwork.LoadBalancerOutboundRuleProtocolAll,
+					IdleTimeoutInMinutes: to.Int32Ptr(30),
+				},
+				Name: to.StringPtr("outbound-rule-v4"),
+			},
+		}
+	}
+
+	return &arm.Resource{
+		Resource:   lb,
+		APIVersion: azureclient.APIVersion("Microsoft.Network"),
+		DependsOn: []string{
+			"Microsoft.Network/publicIPAddresses/" + publicIPAddressName,
+		},
+	}
+}
+
+// networkPrivateLinkService returns the private link service in front of the
+// internal load balancer.  Private endpoints from the RP subscription are
+// approved automatically, and so are those from the cluster subscription, in
+// which the wrapper creates its own private endpoint.
+func (m *manager) networkPrivateLinkService(installConfig *installconfig.InstallConfig) (*arm.Resource, error) {
+	r, err := azure.ParseResourceID(m.oc.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	subscriptions := []string{m.env.SubscriptionID()}
+	if !strings.EqualFold(r.SubscriptionID, m.env.SubscriptionID()) {
+		subscriptions = append(subscriptions, r.SubscriptionID)
+	}
+
+	return &arm.Resource{
+		Resource: &mgmtnetwork.PrivateLinkService{
+			PrivateLinkServiceProperties: &mgmtnetwork.PrivateLinkServiceProperties{
+				LoadBalancerFrontendIPConfigurations: &[]mgmtnetwork.FrontendIPConfiguration{
+					{
+						ID: loadBalancerSubResourceID("frontendIPConfigurations", m.oc.Properties.InfraID+"-internal", "internal-lb-ip-v4"),
+					},
+				},
+				IPConfigurations: &[]mgmtnetwork.PrivateLinkServiceIPConfiguration{
+					{
+						PrivateLinkServiceIPConfigurationProperties: &mgmtnetwork.PrivateLinkServiceIPConfigurationProperties{
+							Subnet: &mgmtnetwork.Subnet{
+								ID: &m.oc.Properties.MasterProfile.SubnetID,
+							},
+						},
+						Name: to.StringPtr(m.oc.Properties.InfraID + "-pls-nic"),
+					},
+				},
+				Visibility: &mgmtnetwork.PrivateLinkServicePropertiesVisibility{
+					Subscriptions: &subscriptions,
+				},
+				AutoApproval: &mgmtnetwork.PrivateLinkServicePropertiesAutoApproval{
+					Subscriptions: &subscriptions,
+				},
+			},
+			Name:     to.StringPtr(m.oc.Properties.InfraID + "-pls"),
+			Type:     to.StringPtr("Microsoft.Network/privateLinkServices"),
+			Location: &installConfig.Config.Azure.Region,
+		},
+		APIVersion: azureclient.APIVersion("Microsoft.Network"),
+		DependsOn: []string{
+			"Microsoft.Network/loadBalancers/" + m.oc.Properties.InfraID + "-internal",
+		},
+	}, nil
+}
+
+// networkPrivateEndpoint returns a private endpoint on the master subnet
+// connected to the private link service, through which the wrapper reaches the
+// API server when the RP has not set up its own
+func (m *manager) networkPrivateEndpoint(installConfig *installconfig.InstallConfig) *arm.Resource {
+	return &arm.Resource{
+		Resource: &mgmtnetwork.PrivateEndpoint{
+			PrivateEndpointProperties: &mgmtnetwork.PrivateEndpointProperties{
+				Subnet: &mgmtnetwork.Subnet{
+					ID: &m.oc.Properties.MasterProfile.SubnetID,
+				},
+				PrivateLinkServiceConnections: &[]mgmtnetwork.PrivateLinkServiceConnection{
+					{
+						PrivateLinkServiceConnectionProperties: &mgmtnetwork.PrivateLinkServiceConnectionProperties{
+							PrivateLinkServiceID: expression.FormatPtr(expression.ResourceID("Microsoft.Network/privateLinkServices", expression.Literal(m.oc.Properties.InfraID+"-pls"))),
+						},
+						Name: to.StringPtr(m.oc.Properties.InfraID + "-pe"),
+					},
+				},
+			},
+			Name:     to.StringPtr(m.oc.Properties.InfraID + "-pe"),
+			Type:     to.StringPtr("Microsoft.Network/privateEndpoints"),
+			Location: &installConfig.Config.Azure.Region,
+		},
+		APIVersion: azureclient.APIVersion("Microsoft.Network"),
+		DependsOn: []string{
+			"Microsoft.Network/privateLinkServices/" + m.oc.Properties.InfraID + "-pls",
+		},
+	}
+}
+
+// resourceKey returns the lower cased type/name of r
+func resourceKey(r *arm.Resource) (string, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+
+	var res struct {
+		Name string `json:"name,omitempty"`
+		Type string `json:"type,omitempty"`
+	}
+	err = json.Unmarshal(b, &res)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.ToLower(res.Type + "/" + res.Name), nil
+}
+
+// infrastructureStage returns the stage creating the network infrastructure
+// missing from the cluster resource group, or nil if it all exists, e.g.
+// because the RP created it.  Existing resources are left untouched, so that
+// the wrapper can also run standalone without clobbering the RP's
+// configuration.
+func (m *manager) infrastructureStage(ctx context.Context, resourceGroup string, installConfig *installconfig.InstallConfig) (*resourceStage, error) {
+	existing, err := m.resources.ListByResourceGroup(ctx, resourceGroup, "", "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	found := map[string]struct{}{}
+	for _, r := range existing {
+		found[strings.ToLower(to.String(r.Type)+"/"+to.String(r.Name))] = struct{}{}
+	}
+
+	resources, err := m.infrastructureResources(installConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []*arm.Resource
+	var missingKeys []string
+	isMissing := map[string]struct{}{}
+	for _, r := range resources {
+		key, err := resourceKey(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, found := found[key]; !found {
+			missing = append(missing, r)
+			missingKeys = append(missingKeys, key)
+			isMissing[key] = struct{}{}
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil, nil
+	}
+
+	// resources which exist already are not part of the template, so must
+	// not be depended on
+	for _, r := range missing {
+		var dependsOn []string
+		for _, d := range r.DependsOn {
+			if _, found := isMissing[strings.ToLower(d)]; found {
+				dependsOn = append(dependsOn, d)
+			}
+		}
+		r.DependsOn = dependsOn
+	}
+
+	m.log.Printf("network infrastructure is missing: %s", strings.Join(missingKeys, ", "))
+
+	return newResourceStage("resources-infrastructure", missing...), nil
+}
+
+// attachNetworkSecurityGroups attaches the cluster NSGs to the cluster subnets
+// which have none, as the RP would have done before running the installer
+func (m *manager) attachNetworkSecurityGroups(ctx context.Context) error {
+	seen := map[string]struct{}{}
+	for _, subnetID := range m.clusterSubnetIDs() {
+		if _, found := seen[strings.ToLower(subnetID)]; found {
+			continue
+		}
+		seen[strings.ToLower(subnetID)] = struct{}{}
+
+		s, err := m.subnet.Get(ctx, subnetID)
+		if err != nil {
+			return err
+		}
+
+		if s.SubnetPropertiesFormat == nil || s.NetworkSecurityGroup != nil {
+			continue
+		}
+
+		nsgID, err := subnet.NetworkSecurityGroupID(m.oc, subnetID)
+		if err != nil {
+			return err
+		}
+
+		m.log.Printf("attaching network security group %s to subnet %s", nsgID, subnetID)
+		s.NetworkSecurityGroup = &mgmtnetwork.SecurityGroup{ID: &nsgID}
+
+		err = m.subnet.CreateOrUpdate(ctx, subnetID, s)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setAPIServerPrivateEndpointIP points the wrapper at the private endpoint of
+// infrastructureStage if the RP has not provided its own
+func (m *manager) setAPIServerPrivateEndpointIP(ctx context.Context, resourceGroup string) error {
+	if m.oc.Properties.NetworkProfile.APIServerPrivateEndpointIP != "" {
+		return nil
+	}
+
+	pe, err := m.privateEndpoints.Get(ctx, resourceGroup, m.oc.Properties.InfraID+"-pe", "networkInterfaces")
+	if azureerrors.IsNotFoundError(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if pe.PrivateEndpointProperties == nil || pe.NetworkInterfaces == nil {
+		return nil
+	}
+
+	for _, nic := range *pe.NetworkInterfaces {
+		if nic.InterfacePropertiesFormat == nil || nic.IPConfigurations == nil {
+			continue
+		}
+
+		for _, ipc := range *nic.IPConfigurations {
+			if ipc.InterfaceIPConfigurationPropertiesFormat != nil && ipc.PrivateIPAddress != nil {
+				m.log.Printf("using private endpoint %s", to.String(pe.ID))
+				m.oc.Properties.NetworkProfile.APIServerPrivateEndpointIP = *ipc.PrivateIPAddress
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,375 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	mgmtnetwork "github.com/Azure/azure-sdk-for-go/services/network/mgmt/2020-08-01/network"
+	mgmtfeatures "github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-07-01/features"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/golang/mock/gomock"
+	"github.com/openshift/installer/pkg/asset/installconfig"
+	"github.com/openshift/installer/pkg/types"
+	azuretypes "github.com/openshift/installer/pkg/types/azure"
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/arm"
+	mock_features "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/azureclient/mgmt/features"
+	mock_network "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/azureclient/mgmt/network"
+	mock_env "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/env"
+	mock_subnet "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/subnet"
+)
+
+func TestInfrastructureStage(t *testing.T) {
+	ctx := context.Background()
+
+	rgID := "/subscriptions/subscriptionId/resourceGroups/clusterResourceGroup"
+	vnetID := "/subscriptions/subscriptionId/resourceGroups/vnetResourceGroup/providers/Microsoft.Network/virtualNetworks/vnet"
+
+	installConfig := &installconfig.InstallConfig{
+		AssetBase: installconfig.AssetBase{
+			Config: &types.InstallConfig{
+				ControlPlane: &types.MachinePool{
+					Replicas: to.Int64Ptr(3),
+				},
+				Platform: types.Platform{
+					Azure: &azuretypes.Platform{
+						Region: "eastus",
+					},
+				},
+			},
+		},
+	}
+
+	resource := func(resourceType, name string) mgmtfeatures.GenericResourceExpanded {
+		return mgmtfeatures.GenericResourceExpanded{
+			Type: to.StringPtr(resourceType),
+			Name: to.StringPtr(name),
+		}
+	}
+
+	allResources := []mgmtfeatures.GenericResourceExpanded{
+		resource("Microsoft.Network/networkSecurityGroups", "infra-nsg"),
+		resource("Microsoft.Network/loadBalancers", "infra-internal"),
+		resource("Microsoft.Network/privateLinkServices", "infra-pls"),
+		resource("Microsoft.Network/privateEndpoints", "infra-pe"),
+		resource("Microsoft.Network/publicIPAddresses", "infra-pip-v4"),
+		resource("Microsoft.Network/loadBalancers", "infra"),
+	}
+
+	for _, tt := range []struct {
+		name         string
+		visibility   api.Visibility
+		outboundType api.OutboundType
+		existing     []mgmtfeatures.GenericResourceExpanded
+		wantKeys     []string
+	}{
+		{
+			name:         "nothing exists, public",
+			visibility:   api.VisibilityPublic,
+			outboundType: api.OutboundTypeLoadbalancer,
+			wantKeys: []string{
+				"microsoft.network/networksecuritygroups/infra-nsg",
+				"microsoft.network/loadbalancers/infra-internal",
+				"microsoft.network/privatelinkservices/infra-pls",
+				"microsoft.network/privateendpoints/infra-pe",
+				"microsoft.network/publicipaddresses/infra-pip-v4",
+				"microsoft.network/loadbalancers/infra",
+			},
+		},
+		{
+			name:         "nothing exists, private with load balancer outbound",
+			visibility:   api.VisibilityPrivate,
+			outboundType: api.OutboundTypeLoadbalancer,
+			wantKeys: []string{
+				"microsoft.network/networksecuritygroups/infra-nsg",
+				"microsoft.network/loadbalancers/infra-internal",
+				"microsoft.network/privatelinkservices/infra-pls",
+				"microsoft.network/privateendpoints/infra-pe",
+				"microsoft.network/publicipaddresses/infra-default-v4",
+				"microsoft.network/loadbalancers/infra",
+			},
+		},
+		{
+			name:         "nothing exists, private with user defined routing",
+			visibility:   api.VisibilityPrivate,
+			outboundType: api.OutboundTypeUserDefinedRouting,
+			wantKeys: []string{
+				"microsoft.network/networksecuritygroups/infra-nsg",
+				"microsoft.network/loadbalancers/infra-internal",
+				"microsoft.network/privatelinkservices/infra-pls",
+				"microsoft.network/privateendpoints/infra-pe",
+			},
+		},
+		{
+			name:         "partially created by the RP",
+			visibility:   api.VisibilityPublic,
+			outboundType: api.OutboundTypeLoadbalancer,
+			existing:     allResources[:2],
+			wantKeys: []string{
+				"microsoft.network/privatelinkservices/infra-pls",
+				"microsoft.network/privateendpoints/infra-pe",
+				"microsoft.network/publicipaddresses/infra-pip-v4",
+				"microsoft.network/loadbalancers/infra",
+			},
+		},
+		{
+			name:         "created by the RP",
+			visibility:   api.VisibilityPublic,
+			outboundType: api.OutboundTypeLoadbalancer,
+			existing:     allResources,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			resources := mock_features.NewMockResourcesClient(controller)
+			resources.EXPECT().ListByResourceGroup(ctx, "clusterResourceGroup", "", "", nil).
+				Return(tt.existing, nil)
+
+			_env := mock_env.NewMockInterface(controller)
+			_env.EXPECT().SubscriptionID().Return("rpSubscriptionId").AnyTimes()
+
+			m := &manager{
+				log:       logrus.NewEntry(logrus.StandardLogger()),
+				env:       _env,
+				resources: resources,
+				oc: &api.OpenShiftCluster{
+					ID: "/subscriptions/subscriptionId/resourceGroups/resourceGroup/providers/Microsoft.RedHatOpenShift/openShiftClusters/cluster",
+					Properties: api.OpenShiftClusterProperties{
+						ArchitectureVersion: api.ArchitectureVersionV2,
+						InfraID:             "infra",
+						ClusterProfile: api.ClusterProfile{
+							ResourceGroupID: rgID,
+						},
+						APIServerProfile: api.APIServerProfile{
+							Visibility: tt.visibility,
+						},
+						NetworkProfile: api.NetworkProfile{
+							OutboundType: tt.outboundType,
+						},
+						MasterProfile: api.MasterProfile{
+							SubnetID: vnetID + "/subnets/master",
+						},
+						WorkerProfiles: []api.WorkerProfile{
+							{
+								SubnetID: vnetID + "/subnets/worker",
+							},
+						},
+					},
+				},
+			}
+
+			s, err := m.infrastructureStage(ctx, "clusterResourceGroup", installConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.wantKeys == nil {
+				if s != nil {
+					t.Errorf("got stage %s", s.name)
+				}
+				return
+			}
+
+			var keys []string
+			for _, r := range s.template.Resources {
+				key, err := resourceKey(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				keys = append(keys, key)
+			}
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("got resources %v", keys)
+			}
+
+			// the wrapper's private endpoint is in the cluster subscription
+			for _, r := range s.template.Resources {
+				pls, ok := r.Resource.(*mgmtnetwork.PrivateLinkService)
+				if !ok {
+					continue
+				}
+
+				wantSubscriptions := []string{"rpSubscriptionId", "subscriptionId"}
+				if !reflect.DeepEqual(*pls.Visibility.Subscriptions, wantSubscriptions) ||
+					!reflect.DeepEqual(*pls.AutoApproval.Subscriptions, wantSubscriptions) {
+					t.Errorf("got private link service subscriptions %v, %v", *pls.Visibility.Subscriptions, *pls.AutoApproval.Subscriptions)
+				}
+			}
+
+			// dependencies on existing resources must have been dropped
+			err = arm.Validate(s.template)
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestAttachNetworkSecurityGroups(t *testing.T) {
+	ctx := context.Background()
+
+	rgID := "/subscriptions/subscriptionId/resourceGroups/clusterResourceGroup"
+	vnetID := "/subscriptions/subscriptionId/resourceGroups/vnetResourceGroup/providers/Microsoft.Network/virtualNetworks/vnet"
+	nsgID := rgID + "/providers/Microsoft.Network/networkSecurityGroups/infra-nsg"
+
+	for _, tt := range []struct {
+		name  string
+		mocks func(*mock_subnet.MockManager)
+	}{
+		{
+			name: "attached by the RP",
+			mocks: func(subnets *mock_subnet.MockManager) {
+				for _, name := range []string{"master", "worker"} {
+					subnets.EXPECT().Get(ctx, vnetID+"/subnets/"+name).Return(&mgmtnetwork.Subnet{
+						SubnetPropertiesFormat: &mgmtnetwork.SubnetPropertiesFormat{
+							NetworkSecurityGroup: &mgmtnetwork.SecurityGroup{ID: to.StringPtr(nsgID)},
+						},
+					}, nil)
+				}
+			},
+		},
+		{
+			name: "standalone install",
+			mocks: func(subnets *mock_subnet.MockManager) {
+				for _, name := range []string{"master", "worker"} {
+					subnets.EXPECT().Get(ctx, vnetID+"/subnets/"+name).Return(&mgmtnetwork.Subnet{
+						SubnetPropertiesFormat: &mgmtnetwork.SubnetPropertiesFormat{},
+					}, nil)
+					subnets.EXPECT().CreateOrUpdate(ctx, vnetID+"/subnets/"+name, &mgmtnetwork.Subnet{
+						SubnetPropertiesFormat: &mgmtnetwork.SubnetPropertiesFormat{
+							NetworkSecurityGroup: &mgmtnetwork.SecurityGroup{ID: to.StringPtr(nsgID)},
+						},
+					}).Return(nil)
+				}
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			subnets := mock_subnet.NewMockManager(controller)
+			tt.mocks(subnets)
+
+			m := &manager{
+				log:    logrus.NewEntry(logrus.StandardLogger()),
+				subnet: subnets,
+				oc: &api.OpenShiftCluster{
+					Properties: api.OpenShiftClusterProperties{
+						ArchitectureVersion: api.ArchitectureVersionV2,
+						InfraID:             "infra",
+						ClusterProfile: api.ClusterProfile{
+							ResourceGroupID: rgID,
+						},
+						MasterProfile: api.MasterProfile{
+							SubnetID: vnetID + "/subnets/master",
+						},
+						WorkerProfiles: []api.WorkerProfile{
+							{
+								SubnetID: vnetID + "/subnets/worker",
+							},
+							{
+								SubnetID: vnetID + "/subnets/worker",
+							},
+						},
+					},
+				},
+			}
+
+			err := m.attachNetworkSecurityGroups(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestSetAPIServerPrivateEndpointIP(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		name   string
+		ip     string
+		mocks  func(*mock_network.MockPrivateEndpointsClient)
+		wantIP string
+	}{
+		{
+			name:   "set by the RP",
+			ip:     "10.0.0.1",
+			wantIP: "10.0.0.1",
+		},
+		{
+			name: "no private endpoint",
+			mocks: func(privateEndpoints *mock_network.MockPrivateEndpointsClient) {
+				privateEndpoints.EXPECT().Get(ctx, "clusterResourceGroup", "infra-pe", "networkInterfaces").
+					Return(mgmtnetwork.PrivateEndpoint{}, autorest.DetailedError{StatusCode: http.StatusNotFound})
+			},
+		},
+		{
+			name: "private endpoint",
+			mocks: func(privateEndpoints *mock_network.MockPrivateEndpointsClient) {
+				privateEndpoints.EXPECT().Get(ctx, "clusterResourceGroup", "infra-pe", "networkInterfaces").
+					Return(mgmtnetwork.PrivateEndpoint{
+						PrivateEndpointProperties: &mgmtnetwork.PrivateEndpointProperties{
+							NetworkInterfaces: &[]mgmtnetwork.Interface{
+								{
+									InterfacePropertiesFormat: &mgmtnetwork.InterfacePropertiesFormat{
+										IPConfigurations: &[]mgmtnetwork.InterfaceIPConfiguration{
+											{
+												InterfaceIPConfigurationPropertiesFormat: &mgmtnetwork.InterfaceIPConfigurationPropertiesFormat{
+													PrivateIPAddress: to.StringPtr("10.0.0.2"),
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					}, nil)
+			},
+			wantIP: "10.0.0.2",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			privateEndpoints := mock_network.NewMockPrivateEndpointsClient(controller)
+			if tt.mocks != nil {
+				tt.mocks(privateEndpoints)
+			}
+
+			m := &manager{
+				log:              logrus.NewEntry(logrus.StandardLogger()),
+				privateEndpoints: privateEndpoints,
+				oc: &api.OpenShiftCluster{
+					Properties: api.OpenShiftClusterProperties{
+						InfraID: "infra",
+						NetworkProfile: api.NetworkProfile{
+							APIServerPrivateEndpointIP: tt.ip,
+						},
+					},
+				},
+			}
+
+			err := m.setAPIServerPrivateEndpointIP(ctx, "clusterResourceGroup")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if m.oc.Properties.NetworkProfile.APIServerPrivateEndpointIP != tt.wantIP {
+				t.Error(m.oc.Properties.NetworkProfile.APIServerPrivateEndpointIP)
+			}
+		})
+	}
+}
@@ -4,31 +4,33 @@ package installer
 // Licensed under the Apache License 2.0.
 
 import (
-	"encoding/base64"
-	"fmt"
-
 	mgmtcompute "github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2020-06-01/compute"
 	mgmtnetwork "github.com/Azure/azure-sdk-for-go/services/network/mgmt/2020-08-01/network"
 	"github.com/Azure/go-autorest/autorest/to"
-	"github.com/openshift/installer/pkg/asset/ignition/machine"
 	"github.com/openshift/installer/pkg/asset/installconfig"
 	azuretypes "github.com/openshift/installer/pkg/types/azure"
 
 	"github.com/openshift/installer-aro-wrapper/pkg/api"
 	"github.com/openshift/installer-aro-wrapper/pkg/util/arm"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/arm/expression"
 	"github.com/openshift/installer-aro-wrapper/pkg/util/azureclient"
 )
 
+// masterNICName returns the name of the copyIndex()th master NIC
+func masterNICName(infraID string) expression.Expression {
+	return expression.Concat(expression.Literal(infraID+"-master"), expression.CopyIndex(0), expression.Literal("-nic"))
+}
+
 func (m *manager) networkBootstrapNIC(installConfig *installconfig.InstallConfig) *arm.Resource {
 	// Private clusters without Public IPs will not have valid external load balancers
 	lbBackendAddressPool := &[]mgmtnetwork.BackendAddressPool{
 		{
-			ID: to.StringPtr(fmt.Sprintf("[resourceId('Microsoft.Network/loadBalancers/backendAddressPools', '%s-internal', '%[1]s')]", m.oc.Properties.InfraID)),
+			ID: expression.FormatPtr(expression.ResourceID("Microsoft.Network/loadBalancers/backendAddressPools", expression.Literal(m.oc.Properties.InfraID+"-internal"), expression.Literal(m.oc.Properties.InfraID))),
 		},
 	}
 	if m.oc.Properties.NetworkProfile.OutboundType == api.OutboundTypeLoadbalancer {
 		*lbBackendAddressPool = append(*lbBackendAddressPool, mgmtnetwork.BackendAddressPool{
-			ID: to.StringPtr(fmt.Sprintf("[resourceId('Microsoft.Network/loadBalancers/backendAddressPools', '%s', '%[1]s')]", m.oc.Properties.InfraID)),
+			ID: expression.FormatPtr(expression.ResourceID("Microsoft.Network/loadBalancers/backendAddressPools", expression.Literal(m.oc.Properties.InfraID), expression.Literal(m.oc.Properties.InfraID))),
 		})
 	}
 	return &arm.Resource{
@@ -58,15 +60,15 @@ func (m *manager) networkMasterNICs(installConfig *installconfig.InstallConfig)
 	// Private clusters without Public IPs not have valid external load balancers
 	lbBackendAddressPool := &[]mgmtnetwork.BackendAddressPool{
 		{
-			ID: to.StringPtr(fmt.Sprintf("[resourceId('Microsoft.Network/loadBalancers/backendAddressPools', '%s-internal', '%[1]s')]", m.oc.Properties.InfraID)),
+			ID: expression.FormatPtr(expression.ResourceID("Microsoft.Network/loadBalancers/backendAddressPools", expression.Literal(m.oc.Properties.InfraID+"-internal"), expression.Literal(m.oc.Properties.InfraID))),
 		},
 		{
-			ID: to.StringPtr(fmt.Sprintf("[resourceId('Microsoft.Network/loadBalancers/backendAddressPools', '%s-internal', concat('ssh-', copyIndex()))]", m.oc.Properties.InfraID)),
+			ID: expression.FormatPtr(expression.ResourceID("Microsoft.Network/loadBalancers/backendAddressPools", expression.Literal(m.oc.Properties.InfraID+"-internal"), expression.Concat(expression.Literal("ssh-"), expression.CopyIndex(0)))),
 		},
 	}
 	if m.oc.Properties.NetworkProfile.OutboundType == api.OutboundTypeLoadbalancer {
 		*lbBackendAddressPool = append(*lbBackendAddressPool, mgmtnetwork.BackendAddressPool{
-			ID: to.StringPtr(fmt.Sprintf("[resourceId('Microsoft.Network/loadBalancers/backendAddressPools', '%s', '%[1]s')]", m.oc.Properties.InfraID)),
+			ID: expression.FormatPtr(expression.ResourceID("Microsoft.Network/loadBalancers/backendAddressPools", expression.Literal(m.oc.Properties.InfraID), expression.Literal(m.oc.Properties.InfraID))),
 		})
 	}
 	return &arm.Resource{
@@ -85,7 +87,7 @@ func (m *manager) networkMasterNICs(installConfig *installconfig.InstallConfig)
 					},
 				},
 			},
-			Name:     to.StringPtr(fmt.Sprintf("[concat('%s-master', copyIndex(), '-nic')]", m.oc.Properties.InfraID)),
+			Name:     expression.FormatPtr(masterNICName(m.oc.Properties.InfraID)),
 			Type:     to.StringPtr("Microsoft.Network/networkInterfaces"),
 			Location: &installConfig.Config.Azure.Region,
 		},
@@ -97,14 +99,7 @@ func (m *manager) networkMasterNICs(installConfig *installconfig.InstallConfig)
 	}
 }
 
-func (m *manager) computeBootstrapVM(installConfig *installconfig.InstallConfig) *arm.Resource {
-	var customData string
-	if m.oc.Properties.NetworkProfile.GatewayPrivateEndpointIP != "" {
-		customData = `[base64(concat('{"ignition":{"version":"3.2.0","proxy":{"httpsProxy":"http://` + m.oc.Properties.NetworkProfile.GatewayPrivateEndpointIP + `"},"config":{"replace":{"source":"https://cluster` + m.oc.Properties.StorageSuffix + `.blob.` + m.env.Environment().StorageEndpointSuffix + `/ignition/bootstrap.ign?', listAccountSas(resourceId('Microsoft.Storage/storageAccounts', 'cluster` + m.oc.Properties.StorageSuffix + `'), '2019-04-01', parameters('sas')).accountSasToken, '"}}}}'))]`
-	} else {
-		customData = `[base64(concat('{"ignition":{"version":"3.2.0","config":{"replace":{"source":"https://cluster` + m.oc.Properties.StorageSuffix + `.blob.` + m.env.Environment().StorageEndpointSuffix + `/ignition/bootstrap.ign?', listAccountSas(resourceId('Microsoft.Storage/storageAccounts', 'cluster` + m.oc.Properties.StorageSuffix + `'), '2019-04-01', parameters('sas')).accountSasToken, '"}}}}'))]`
-	}
-
+func (m *manager) computeBootstrapVM(installConfig *installconfig.InstallConfig, customData string) *arm.Resource {
 	vm := &mgmtcompute.VirtualMachine{
 		VirtualMachineProperties: &mgmtcompute.VirtualMachineProperties{
 			HardwareProfile: &mgmtcompute.HardwareProfile{
@@ -139,16 +134,11 @@ func (m *manager) computeBootstrapVM(installConfig *installconfig.InstallConfig)
 			NetworkProfile: &mgmtcompute.NetworkProfile{
 				NetworkInterfaces: &[]mgmtcompute.NetworkInterfaceReference{
 					{
-						ID: to.StringPtr("[resourceId('Microsoft.Network/networkInterfaces', '" + m.oc.Properties.InfraID + "-bootstrap-nic')]"),
+						ID: expression.FormatPtr(expression.ResourceID("Microsoft.Network/networkInterfaces", expression.Literal(m.oc.Properties.InfraID+"-bootstrap-nic"))),
 					},
 				},
 			},
-			DiagnosticsProfile: &mgmtcompute.DiagnosticsProfile{
-				BootDiagnostics: &mgmtcompute.BootDiagnostics{
-					Enabled:    to.BoolPtr(true),
-					StorageURI: to.StringPtr("https://cluster" + m.oc.Properties.StorageSuffix + ".blob." + m.env.Environment().StorageEndpointSuffix + "/"),
-				},
-			},
+			DiagnosticsProfile: m.diagnosticsProfile(),
 		},
 		Name:     to.StringPtr(m.oc.Properties.InfraID + "-bootstrap"),
 		Type:     to.StringPtr("Microsoft.Compute/virtualMachines"),
@@ -170,13 +160,10 @@ func (m *manager) computeBootstrapVM(installConfig *installconfig.InstallConfig)
 	return &arm.Resource{
 		Resource:   vm,
 		APIVersion: azureclient.APIVersion("Microsoft.Compute"),
-		DependsOn: []string{
-			"Microsoft.Network/networkInterfaces/" + m.oc.Properties.InfraID + "-bootstrap-nic",
-		},
 	}
 }
 
-func (m *manager) computeMasterVMs(installConfig *installconfig.InstallConfig, zones *[]string, machineMaster *machine.Master) *arm.Resource {
+func (m *manager) computeMasterVMs(installConfig *installconfig.InstallConfig, zones *[]string, customData string) *arm.Resource {
 	vm := &mgmtcompute.VirtualMachine{
 		VirtualMachineProperties: &mgmtcompute.VirtualMachineProperties{
 			HardwareProfile: &mgmtcompute.HardwareProfile{
@@ -190,7 +177,7 @@ func (m *manager) computeMasterVMs(installConfig *installconfig.InstallConfig, z
 					Version:   &installConfig.Config.ControlPlane.Platform.Azure.OSImage.Version,
 				},
 				OsDisk: &mgmtcompute.OSDisk{
-					Name:         to.StringPtr("[concat('" + m.oc.Properties.InfraID + "-master-', copyIndex(), '_OSDisk')]"),
+					Name:         expression.FormatPtr(expression.Concat(expression.Literal(m.oc.Properties.InfraID+"-master-"), expression.CopyIndex(0), expression.Literal("_OSDisk"))),
 					Caching:      mgmtcompute.CachingTypesReadOnly,
 					CreateOption: mgmtcompute.DiskCreateOptionTypesFromImage,
 					DiskSizeGB:   &installConfig.Config.ControlPlane.Platform.Azure.OSDisk.DiskSizeGB,
@@ -200,10 +187,10 @@ func (m *manager) computeMasterVMs(installConfig *installconfig.InstallConfig, z
 				},
 			},
 			OsProfile: &mgmtcompute.OSProfile{
-				ComputerName:  to.StringPtr("[concat('" + m.oc.Properties.InfraID + "-master-', copyIndex())]"),
+				ComputerName:  expression.FormatPtr(expression.Concat(expression.Literal(m.oc.Properties.InfraID+"-master-"), expression.CopyIndex(0))),
 				AdminUsername: to.StringPtr("core"),
 				AdminPassword: to.StringPtr("NotActuallyApplied!"),
-				CustomData:    to.StringPtr(base64.StdEncoding.EncodeToString(machineMaster.File.Data)),
+				CustomData:    &customData,
 				LinuxConfiguration: &mgmtcompute.LinuxConfiguration{
 					DisablePasswordAuthentication: to.BoolPtr(false),
 				},
@@ -211,19 +198,14 @@ func (m *manager) computeMasterVMs(installConfig *installconfig.InstallConfig, z
 			NetworkProfile: &mgmtcompute.NetworkProfile{
 				NetworkInterfaces: &[]mgmtcompute.NetworkInterfaceReference{
 					{
-						ID: to.StringPtr("[resourceId('Microsoft.Network/networkInterfaces', concat('" + m.oc.Properties.InfraID + "-master', copyIndex(), '-nic'))]"),
+						ID: expression.FormatPtr(expression.ResourceID("Microsoft.Network/networkInterfaces", masterNICName(m.oc.Properties.InfraID))),
 					},
 				},
 			},
-			DiagnosticsProfile: &mgmtcompute.DiagnosticsProfile{
-				BootDiagnostics: &mgmtcompute.BootDiagnostics{
-					Enabled:    to.BoolPtr(true),
-					StorageURI: to.StringPtr("https://cluster" + m.oc.Properties.StorageSuffix + ".blob." + m.env.Environment().StorageEndpointSuffix + "/"),
-				},
-			},
+			DiagnosticsProfile: m.diagnosticsProfile(),
 		},
 		Zones:    zones,
-		Name:     to.StringPtr("[concat('" + m.oc.Properties.InfraID + "-master-', copyIndex())]"),
+		Name:     expression.FormatPtr(expression.Concat(expression.Literal(m.oc.Properties.InfraID+"-master-"), expression.CopyIndex(0))),
 		Type:     to.StringPtr("Microsoft.Compute/virtualMachines"),
 		Location: &installConfig.Config.Azure.Region,
 	}
@@ -248,8 +230,5 @@ func (m *manager) computeMasterVMs(installConfig *installconfig.InstallConfig, z
 			Name:  "computecopy",
 			Count: int(*installConfig.Config.ControlPlane.Replicas),
 		},
-		DependsOn: []string{
-			"[concat('Microsoft.Network/networkInterfaces/" + m.oc.Properties.InfraID + "-master', copyIndex(), '-nic')]",
-		},
 	}
 }
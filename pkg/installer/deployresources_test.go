@@ -4,13 +4,33 @@ package installer
 // Licensed under the Apache License 2.0.
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	mgmtstorage "github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2019-06-01/storage"
+	azstorage "github.com/Azure/azure-sdk-for-go/storage"
+	"github.com/Azure/go-autorest/autorest/azure"
 	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/golang/mock/gomock"
+	"github.com/openshift/installer/pkg/asset"
+	"github.com/openshift/installer/pkg/asset/ignition/machine"
 	"github.com/openshift/installer/pkg/asset/installconfig"
 	"github.com/openshift/installer/pkg/types"
 	azuretypes "github.com/openshift/installer/pkg/types/azure"
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+	"github.com/openshift/installer-aro-wrapper/pkg/cluster/graph"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/arm"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/azureclient"
+	mock_env "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/env"
+	mock_storage "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/storage"
 )
 
 func TestZones(t *testing.T) {
@@ -96,3 +116,229 @@ func TestZones(t *testing.T) {
 		})
 	}
 }
+
+func TestResourceTemplateSAS(t *testing.T) {
+	ctx := context.Background()
+
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	_env := mock_env.NewMockInterface(controller)
+	_env.EXPECT().Environment().Return(&azureclient.PublicCloud).AnyTimes()
+	_env.EXPECT().FeatureIsSet(gomock.Any()).Return(false).AnyTimes()
+
+	m := &manager{
+		env:              _env,
+		now:              func() time.Time { return now },
+		bootstrapTimeout: 30 * time.Minute,
+		oc: &api.OpenShiftCluster{
+			Properties: api.OpenShiftClusterProperties{
+				InfraID:       "infra",
+				StorageSuffix: "abcdef",
+				Install: &api.Install{
+					Now: now.Add(-time.Hour),
+				},
+				MasterProfile: api.MasterProfile{
+					SubnetID: "/subscriptions/subscriptionId/resourceGroups/vnetResourceGroup/providers/Microsoft.Network/virtualNetworks/vnet/subnets/master",
+				},
+			},
+		},
+	}
+
+	installConfig := &installconfig.InstallConfig{
+		AssetBase: installconfig.AssetBase{
+			Config: &types.InstallConfig{
+				ControlPlane: &types.MachinePool{
+					Platform: types.MachinePoolPlatform{
+						Azure: &azuretypes.MachinePool{
+							Zones: []string{""},
+						},
+					},
+					Replicas: to.Int64Ptr(3),
+				},
+				Platform: types.Platform{
+					Azure: &azuretypes.Platform{
+						Region: "eastus",
+					},
+				},
+			},
+		},
+	}
+
+	machineWorker := &machine.Worker{
+		File: &asset.File{
+			Data: []byte("{}"),
+		},
+	}
+
+	for _, tt := range []struct {
+		name         string
+		inlineMaster *machine.Master
+	}{
+		{
+			name: "master pointer",
+		},
+		{
+			name: "inline master from an older graph",
+			inlineMaster: &machine.Master{
+				File: &asset.File{
+					Data: []byte("{}"),
+				},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			stages, err := m.resourceStages(ctx, installConfig, tt.inlineMaster, machineWorker, &graph.IgnitionHashes{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names [][]string
+			for _, concurrent := range stages {
+				var n []string
+				for _, s := range concurrent {
+					n = append(n, s.name)
+				}
+				names = append(names, n)
+			}
+			if !reflect.DeepEqual(names, [][]string{{"resources-network"}, {"resources-bootstrap", "resources-masters"}}) {
+				t.Errorf("got stages %v", names)
+			}
+
+			for _, concurrent := range stages {
+				for _, s := range concurrent {
+					template, parameters := s.template, s.parameters
+
+					err = arm.Validate(template)
+					if err != nil {
+						t.Error(err)
+					}
+
+					b, err := json.Marshal(template)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if strings.Contains(string(b), "listAccountSas") {
+						t.Error("template uses an account SAS")
+					}
+
+					if len(parameters) != len(template.Parameters) {
+						t.Errorf("got %d parameters, want %d", len(parameters), len(template.Parameters))
+					}
+
+					for name := range template.Parameters {
+						if !strings.Contains(string(b), "parameters('"+name+"')") {
+							t.Errorf("parameter %s is unused", name)
+						}
+
+						p, ok := parameters[name].(map[string]interface{})["value"].(map[string]interface{})
+						if !ok {
+							t.Fatalf("parameter %s is not set", name)
+						}
+
+						// the VMs must only be able to read their own ignition config,
+						// and in particular must never be able to list or write the aro
+						// container, which holds the graph
+						if !strings.HasPrefix(p["canonicalizedResource"].(string), "/blob/clusterabcdef/ignition/") {
+							t.Errorf("parameter %s grants access to %s", name, p["canonicalizedResource"])
+						}
+						if p["signedResource"] != "b" {
+							t.Errorf("parameter %s is scoped to %s, not a blob", name, p["signedResource"])
+						}
+						if p["signedPermission"] != "r" {
+							t.Errorf("parameter %s grants %s", name, p["signedPermission"])
+						}
+						if p["signedExpiry"] != "2026-01-01T00:30:00Z" {
+							t.Errorf("parameter %s expires at %s", name, p["signedExpiry"])
+						}
+					}
+				}
+			}
+
+			masters := stages[1][1]
+			if tt.inlineMaster != nil && len(masters.parameters) != 0 {
+				t.Error("inline masters are given a SAS")
+			}
+			if tt.inlineMaster == nil && len(masters.parameters) != 1 {
+				t.Error("master pointers are not given a SAS")
+			}
+		})
+	}
+}
+
+func TestPersistedIgnition(t *testing.T) {
+	ctx := context.Background()
+
+	machineMaster := &machine.Master{
+		File: &asset.File{
+			Data: []byte("{}"),
+		},
+	}
+
+	for _, tt := range []struct {
+		name             string
+		hashes           *graph.IgnitionHashes
+		status           int
+		wantInlineMaster bool
+		wantHashes       *graph.IgnitionHashes
+	}{
+		{
+			name:       "hashes recorded by Save",
+			hashes:     &graph.IgnitionHashes{Bootstrap: "sha512-bootstrap", Master: "sha512-master"},
+			wantHashes: &graph.IgnitionHashes{Bootstrap: "sha512-bootstrap", Master: "sha512-master"},
+		},
+		{
+			name:       "older graph with master.ign",
+			status:     http.StatusOK,
+			wantHashes: &graph.IgnitionHashes{},
+		},
+		{
+			name:             "older graph without master.ign",
+			status:           http.StatusNotFound,
+			wantInlineMaster: true,
+			wantHashes:       &graph.IgnitionHashes{},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			pg := graph.PersistedGraph{}
+			if tt.hashes != nil {
+				err := pg.Set(tt.hashes)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			storage := mock_storage.NewMockManager(controller)
+			if tt.status != 0 {
+				c := azstorage.NewAccountSASClient("clusterabcdef", url.Values{"sv": []string{"2019-02-02"}}, azure.PublicCloud)
+				c.HTTPClient = &http.Client{Transport: blobStatus(tt.status)}
+				blobService := c.GetBlobService()
+
+				storage.EXPECT().Blob(ctx, "resourceGroup", "clusterabcdef", "ignition", "master.ign", mgmtstorage.Permissions("r")).
+					Return(blobService.GetContainerReference("ignition").GetBlobReference("master.ign"), nil)
+			}
+
+			m := &manager{
+				log:     logrus.NewEntry(logrus.StandardLogger()),
+				storage: storage,
+			}
+
+			inlineMaster, hashes, err := m.persistedIgnition(ctx, "resourceGroup", "clusterabcdef", pg, machineMaster)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if (inlineMaster != nil) != tt.wantInlineMaster {
+				t.Errorf("got inline master %v", inlineMaster)
+			}
+			if !reflect.DeepEqual(hashes, tt.wantHashes) {
+				t.Errorf("got hashes %#v", hashes)
+			}
+		})
+	}
+}
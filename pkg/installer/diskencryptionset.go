@@ -0,0 +1,282 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	mgmtauthorization "github.com/Azure/azure-sdk-for-go/services/preview/authorization/mgmt/2018-09-01-preview/authorization"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/to"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+	"github.com/openshift/installer-aro-wrapper/pkg/env"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/arm"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/azureclient"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/azureerrors"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/permissions"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/rbac"
+)
+
+var (
+	// keyVaultKeyDataActions are the data actions a disk encryption set
+	// identity needs on its key when the key vault uses RBAC authorization
+	keyVaultKeyDataActions = []string{
+		"Microsoft.KeyVault/vaults/keys/wrap/action",
+		"Microsoft.KeyVault/vaults/keys/unwrap/action",
+	}
+
+	// keyVaultKeyPermissions are the key permissions a disk encryption set
+	// identity needs when the key vault uses access policies
+	keyVaultKeyPermissions = []string{
+		"wrapKey",
+		"unwrapKey",
+	}
+)
+
+// keyVaultProperties holds the parts of a key vault's properties needed to
+// work out whether an identity can use its keys
+type keyVaultProperties struct {
+	EnableRbacAuthorization bool `json:"enableRbacAuthorization,omitempty"`
+	AccessPolicies          []struct {
+		ObjectID    string `json:"objectId,omitempty"`
+		Permissions struct {
+			Keys []string `json:"keys,omitempty"`
+		} `json:"permissions,omitempty"`
+	} `json:"accessPolicies,omitempty"`
+}
+
+// linkedDiskEncryptionSet is a customer disk encryption set the cluster's
+// disks will be encrypted with
+type linkedDiskEncryptionSet struct {
+	id   string
+	path string
+}
+
+// linkedDiskEncryptionSets returns the unique master and worker disk
+// encryption sets of the cluster
+func (m *manager) linkedDiskEncryptionSets() []linkedDiskEncryptionSet {
+	var sets []linkedDiskEncryptionSet
+	seen := map[string]bool{}
+
+	add := func(id, path string) {
+		if id == "" || seen[strings.ToLower(id)] {
+			return
+		}
+		seen[strings.ToLower(id)] = true
+		sets = append(sets, linkedDiskEncryptionSet{id: id, path: path})
+	}
+
+	add(m.oc.Properties.MasterProfile.DiskEncryptionSetID, "properties.masterProfile.diskEncryptionSetId")
+	for i, wp := range m.oc.Properties.WorkerProfiles {
+		add(wp.DiskEncryptionSetID, fmt.Sprintf("properties.workerProfiles[%d].diskEncryptionSetId", i))
+	}
+
+	return sets
+}
+
+// validateDiskEncryptionSets checks that each disk encryption set exists, is
+// usable in the cluster region and can use its Key Vault key
+func (m *manager) validateDiskEncryptionSets(ctx context.Context) error {
+	sets := m.linkedDiskEncryptionSets()
+	if len(sets) == 0 {
+		return nil
+	}
+
+	m.log.Print("validating disk encryption sets")
+
+	var roleDefinitions map[string][]mgmtauthorization.Permission
+	var details []api.CloudErrorBody
+
+	for _, set := range sets {
+		invalid := func(format string, a ...interface{}) {
+			details = append(details, api.CloudErrorBody{
+				Code:    api.CloudErrorCodeInvalidLinkedDiskEncryptionSet,
+				Target:  set.path,
+				Message: fmt.Sprintf("The provided disk encryption set '%s' is invalid: %s.", set.id, fmt.Sprintf(format, a...)),
+			})
+		}
+
+		r, err := azure.ParseResourceID(set.id)
+		if err != nil {
+			return err
+		}
+
+		des, err := m.diskEncryptionSets.Get(ctx, r.ResourceGroup, r.ResourceName)
+		if azureerrors.IsNotFoundError(err) {
+			invalid("could not be found")
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if !strings.EqualFold(to.String(des.Location), m.oc.Location) {
+			invalid("must be located in %s", m.oc.Location)
+		}
+
+		if des.EncryptionSetProperties == nil || !strings.EqualFold(to.String(des.ProvisioningState), "Succeeded") {
+			invalid("must be in the Succeeded provisioning state")
+			continue
+		}
+
+		if des.Identity == nil || des.Identity.PrincipalID == nil {
+			invalid("must have a managed identity")
+			continue
+		}
+
+		if des.ActiveKey == nil || des.ActiveKey.SourceVault == nil || des.ActiveKey.SourceVault.ID == nil {
+			invalid("must reference a Key Vault key")
+			continue
+		}
+
+		vault, err := m.keyVaultProperties(ctx, *des.ActiveKey.SourceVault.ID)
+		if err != nil {
+			return err
+		}
+
+		var missing []string
+		if vault.EnableRbacAuthorization {
+			if roleDefinitions == nil {
+				roleDefinitions, err = m.listRoleDefinitions(ctx)
+				if err != nil {
+					return err
+				}
+			}
+
+			missing, err = m.missingKeyDataActions(ctx, *des.ActiveKey.SourceVault.ID, *des.Identity.PrincipalID, roleDefinitions)
+			if err != nil {
+				return err
+			}
+		} else {
+			missing = missingKeyPermissions(vault, *des.Identity.PrincipalID)
+		}
+
+		if len(missing) > 0 {
+			invalid("its identity must be able to wrap and unwrap keys in key vault '%s', but is missing %s", *des.ActiveKey.SourceVault.ID, strings.Join(missing, ", "))
+		}
+	}
+
+	if len(details) == 0 {
+		return nil
+	}
+
+	cloudErr := api.NewCloudError(http.StatusBadRequest, api.CloudErrorCodeInvalidLinkedDiskEncryptionSet, "", "The provided disk encryption sets are invalid.")
+	cloudErr.Details = details
+
+	return cloudErr
+}
+
+// keyVaultProperties returns the authorization properties of the key vault
+func (m *manager) keyVaultProperties(ctx context.Context, vaultID string) (*keyVaultProperties, error) {
+	vault, err := m.resources.GetByID(ctx, vaultID, azureclient.APIVersion("Microsoft.KeyVault"))
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(vault.Properties)
+	if err != nil {
+		return nil, err
+	}
+
+	var properties *keyVaultProperties
+	err = json.Unmarshal(b, &properties)
+	if err != nil {
+		return nil, err
+	}
+	if properties == nil {
+		properties = &keyVaultProperties{}
+	}
+
+	return properties, nil
+}
+
+// missingKeyDataActions returns the key data actions the roles assigned to
+// principalID on an RBAC key vault don't allow
+func (m *manager) missingKeyDataActions(ctx context.Context, vaultID, principalID string, roleDefinitions map[string][]mgmtauthorization.Permission) ([]string, error) {
+	ps, err := m.assignedPermissions(ctx, vaultID, principalID, roleDefinitions)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, dataAction := range keyVaultKeyDataActions {
+		ok, err := permissions.CanDoDataAction(ps, dataAction)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			missing = append(missing, dataAction)
+		}
+	}
+
+	return missing, nil
+}
+
+// missingKeyPermissions returns the key permissions the access policies of a
+// key vault don't grant principalID
+func missingKeyPermissions(vault *keyVaultProperties, principalID string) []string {
+	granted := map[string]bool{}
+	for _, policy := range vault.AccessPolicies {
+		if !strings.EqualFold(policy.ObjectID, principalID) {
+			continue
+		}
+
+		for _, key := range policy.Permissions.Keys {
+			granted[strings.ToLower(key)] = true
+		}
+	}
+
+	var missing []string
+	for _, permission := range keyVaultKeyPermissions {
+		if !granted[strings.ToLower(permission)] && !granted["all"] {
+			missing = append(missing, permission)
+		}
+	}
+
+	return missing
+}
+
+// clusterPrincipalIDs returns the object IDs of the identities the cluster
+// runs as
+func (m *manager) clusterPrincipalIDs() []string {
+	var principalIDs []string
+
+	if m.oc.UsesWorkloadIdentity() {
+		for _, identity := range m.oc.Properties.PlatformWorkloadIdentityProfile.PlatformWorkloadIdentities {
+			principalIDs = append(principalIDs, identity.ObjectID)
+		}
+	} else if m.oc.Properties.ServicePrincipalProfile != nil && m.oc.Properties.ServicePrincipalProfile.SPObjectID != "" {
+		principalIDs = append(principalIDs, m.oc.Properties.ServicePrincipalProfile.SPObjectID)
+	}
+
+	return principalIDs
+}
+
+// diskEncryptionSetRoleAssignments returns resources granting the cluster
+// identities which need it Reader on the disk encryption sets, if
+// FeatureEnableDiskEncryptionSetRoleAssignment is set.
+func (m *manager) diskEncryptionSetRoleAssignments() ([]*arm.Resource, error) {
+	if !m.env.FeatureIsSet(env.FeatureEnableDiskEncryptionSetRoleAssignment) {
+		return nil, nil
+	}
+
+	var resources []*arm.Resource
+	for _, set := range m.linkedDiskEncryptionSets() {
+		r, err := m.roleAssignmentDeployment("diskencryptionset-", set.id, rbac.RoleReader, diskEncryptionSetActions)
+		if err != nil {
+			return nil, err
+		}
+
+		if r != nil {
+			resources = append(resources, r)
+		}
+	}
+
+	return resources, nil
+}
@@ -0,0 +1,248 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	mgmtcompute "github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2020-06-01/compute"
+	mgmtauthorization "github.com/Azure/azure-sdk-for-go/services/preview/authorization/mgmt/2018-09-01-preview/authorization"
+	mgmtfeatures "github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-07-01/features"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+	"github.com/openshift/installer-aro-wrapper/pkg/env"
+	mock_authorization "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/azureclient/mgmt/authorization"
+	mock_compute "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/azureclient/mgmt/compute"
+	mock_features "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/azureclient/mgmt/features"
+	mock_env "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/env"
+)
+
+func TestValidateDiskEncryptionSets(t *testing.T) {
+	ctx := context.Background()
+
+	desID := "/subscriptions/subscriptionId/resourceGroups/desResourceGroup/providers/Microsoft.Compute/diskEncryptionSets/des"
+	vaultID := "/subscriptions/subscriptionId/resourceGroups/desResourceGroup/providers/Microsoft.KeyVault/vaults/vault"
+	roleDefinitionID := "/subscriptions/subscriptionId/providers/Microsoft.Authorization/roleDefinitions/crypto"
+
+	des := func(location, provisioningState string) mgmtcompute.DiskEncryptionSet {
+		return mgmtcompute.DiskEncryptionSet{
+			Location: to.StringPtr(location),
+			Identity: &mgmtcompute.EncryptionSetIdentity{
+				PrincipalID: to.StringPtr("desPrincipalId"),
+			},
+			EncryptionSetProperties: &mgmtcompute.EncryptionSetProperties{
+				ProvisioningState: to.StringPtr(provisioningState),
+				ActiveKey: &mgmtcompute.KeyVaultAndKeyReference{
+					SourceVault: &mgmtcompute.SourceVault{ID: to.StringPtr(vaultID)},
+					KeyURL:      to.StringPtr("https://vault.vault.azure.net/keys/key/version"),
+				},
+			},
+		}
+	}
+
+	accessPolicyVault := func(keys ...string) mgmtfeatures.GenericResource {
+		return mgmtfeatures.GenericResource{
+			Properties: map[string]interface{}{
+				"accessPolicies": []interface{}{
+					map[string]interface{}{
+						"objectId": "desPrincipalId",
+						"permissions": map[string]interface{}{
+							"keys": keys,
+						},
+					},
+				},
+			},
+		}
+	}
+
+	for _, tt := range []struct {
+		name    string
+		mocks   func(*mock_compute.MockDiskEncryptionSetsClient, *mock_features.MockResourcesClient, *mock_authorization.MockRoleAssignmentsClient, *mock_authorization.MockRoleDefinitionsClient)
+		wantErr string
+	}{
+		{
+			name: "valid with access policies",
+			mocks: func(dess *mock_compute.MockDiskEncryptionSetsClient, resources *mock_features.MockResourcesClient, ra *mock_authorization.MockRoleAssignmentsClient, rd *mock_authorization.MockRoleDefinitionsClient) {
+				dess.EXPECT().Get(ctx, "desResourceGroup", "des").Return(des("eastus", "Succeeded"), nil)
+				resources.EXPECT().GetByID(ctx, vaultID, "2019-09-01").Return(accessPolicyVault("get", "wrapKey", "unwrapKey"), nil)
+			},
+		},
+		{
+			name: "valid with RBAC",
+			mocks: func(dess *mock_compute.MockDiskEncryptionSetsClient, resources *mock_features.MockResourcesClient, ra *mock_authorization.MockRoleAssignmentsClient, rd *mock_authorization.MockRoleDefinitionsClient) {
+				dess.EXPECT().Get(ctx, "desResourceGroup", "des").Return(des("eastus", "Succeeded"), nil)
+				resources.EXPECT().GetByID(ctx, vaultID, "2019-09-01").Return(mgmtfeatures.GenericResource{
+					Properties: map[string]interface{}{
+						"enableRbacAuthorization": true,
+					},
+				}, nil)
+				rd.EXPECT().List(ctx, "/subscriptions/subscriptionId", "").Return([]mgmtauthorization.RoleDefinition{
+					{
+						ID: to.StringPtr(roleDefinitionID),
+						RoleDefinitionProperties: &mgmtauthorization.RoleDefinitionProperties{
+							Permissions: &[]mgmtauthorization.Permission{
+								{DataActions: &[]string{"Microsoft.KeyVault/vaults/keys/*"}},
+							},
+						},
+					},
+				}, nil)
+				ra.EXPECT().ListForResource(ctx, "desResourceGroup", "Microsoft.KeyVault", "", "vaults", "vault", "assignedTo('desPrincipalId')").Return([]mgmtauthorization.RoleAssignment{
+					{
+						RoleAssignmentPropertiesWithScope: &mgmtauthorization.RoleAssignmentPropertiesWithScope{
+							RoleDefinitionID: to.StringPtr(roleDefinitionID),
+						},
+					},
+				}, nil)
+			},
+		},
+		{
+			name: "not found",
+			mocks: func(dess *mock_compute.MockDiskEncryptionSetsClient, resources *mock_features.MockResourcesClient, ra *mock_authorization.MockRoleAssignmentsClient, rd *mock_authorization.MockRoleDefinitionsClient) {
+				dess.EXPECT().Get(ctx, "desResourceGroup", "des").Return(mgmtcompute.DiskEncryptionSet{}, autorest.DetailedError{StatusCode: http.StatusNotFound})
+			},
+			wantErr: "400: InvalidLinkedDiskEncryptionSet: : The provided disk encryption sets are invalid. Details: " +
+				"InvalidLinkedDiskEncryptionSet: properties.masterProfile.diskEncryptionSetId: The provided disk encryption set '" + desID + "' is invalid: could not be found.",
+		},
+		{
+			name: "wrong region and missing key permissions",
+			mocks: func(dess *mock_compute.MockDiskEncryptionSetsClient, resources *mock_features.MockResourcesClient, ra *mock_authorization.MockRoleAssignmentsClient, rd *mock_authorization.MockRoleDefinitionsClient) {
+				dess.EXPECT().Get(ctx, "desResourceGroup", "des").Return(des("westus", "Succeeded"), nil)
+				resources.EXPECT().GetByID(ctx, vaultID, "2019-09-01").Return(accessPolicyVault("get", "wrapKey"), nil)
+			},
+			wantErr: "400: InvalidLinkedDiskEncryptionSet: : The provided disk encryption sets are invalid. Details: " +
+				"InvalidLinkedDiskEncryptionSet: properties.masterProfile.diskEncryptionSetId: The provided disk encryption set '" + desID + "' is invalid: must be located in eastus., " +
+				"InvalidLinkedDiskEncryptionSet: properties.masterProfile.diskEncryptionSetId: The provided disk encryption set '" + desID + "' is invalid: its identity must be able to wrap and unwrap keys in key vault '" + vaultID + "', but is missing unwrapKey.",
+		},
+		{
+			name: "not succeeded",
+			mocks: func(dess *mock_compute.MockDiskEncryptionSetsClient, resources *mock_features.MockResourcesClient, ra *mock_authorization.MockRoleAssignmentsClient, rd *mock_authorization.MockRoleDefinitionsClient) {
+				dess.EXPECT().Get(ctx, "desResourceGroup", "des").Return(des("eastus", "Failed"), nil)
+			},
+			wantErr: "400: InvalidLinkedDiskEncryptionSet: : The provided disk encryption sets are invalid. Details: " +
+				"InvalidLinkedDiskEncryptionSet: properties.masterProfile.diskEncryptionSetId: The provided disk encryption set '" + desID + "' is invalid: must be in the Succeeded provisioning state.",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			dess := mock_compute.NewMockDiskEncryptionSetsClient(controller)
+			resources := mock_features.NewMockResourcesClient(controller)
+			ra := mock_authorization.NewMockRoleAssignmentsClient(controller)
+			rd := mock_authorization.NewMockRoleDefinitionsClient(controller)
+			tt.mocks(dess, resources, ra, rd)
+
+			m := &manager{
+				log:                logrus.NewEntry(logrus.StandardLogger()),
+				diskEncryptionSets: dess,
+				resources:          resources,
+				roleAssignments:    ra,
+				roleDefinitions:    rd,
+				oc: &api.OpenShiftCluster{
+					ID:       "/subscriptions/subscriptionId/resourceGroups/resourceGroup/providers/Microsoft.RedHatOpenShift/openShiftClusters/cluster",
+					Location: "eastus",
+					Properties: api.OpenShiftClusterProperties{
+						MasterProfile: api.MasterProfile{
+							DiskEncryptionSetID: desID,
+						},
+						WorkerProfiles: []api.WorkerProfile{
+							{
+								DiskEncryptionSetID: desID,
+							},
+						},
+					},
+				},
+			}
+
+			err := m.validateDiskEncryptionSets(ctx)
+			if err != nil && err.Error() != tt.wantErr ||
+				err == nil && tt.wantErr != "" {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestDiskEncryptionSetRoleAssignments(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	_env := mock_env.NewMockInterface(controller)
+	_env.EXPECT().FeatureIsSet(env.FeatureEnableDiskEncryptionSetRoleAssignment).Return(true)
+
+	m := &manager{
+		env: _env,
+		oc: &api.OpenShiftCluster{
+			Properties: api.OpenShiftClusterProperties{
+				ServicePrincipalProfile: &api.ServicePrincipalProfile{
+					SPObjectID: "spObjectId",
+				},
+				MasterProfile: api.MasterProfile{
+					DiskEncryptionSetID: "/subscriptions/subscriptionId/resourceGroups/desResourceGroup/providers/Microsoft.Compute/diskEncryptionSets/des",
+				},
+			},
+		},
+	}
+
+	resources, err := m.diskEncryptionSetRoleAssignments()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(resources)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []interface{}
+	err = json.Unmarshal(b, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want []interface{}
+	err = json.Unmarshal([]byte(`[{
+		"name": "[concat('diskencryptionset-', uniqueString(resourceGroup().id, '/subscriptions/subscriptionid/resourcegroups/desresourcegroup/providers/microsoft.compute/diskencryptionsets/des'))]",
+		"type": "Microsoft.Resources/deployments",
+		"apiVersion": "2019-07-01",
+		"subscriptionId": "subscriptionId",
+		"resourceGroup": "desResourceGroup",
+		"properties": {
+			"expressionEvaluationOptions": {
+				"scope": "inner"
+			},
+			"mode": "Incremental",
+			"template": {
+				"$schema": "https://schema.management.azure.com/schemas/2015-01-01/deploymentTemplate.json#",
+				"contentVersion": "1.0.0.0",
+				"resources": [{
+					"name": "[concat('des', '/Microsoft.Authorization/', guid(resourceId('Microsoft.Compute/diskEncryptionSets', 'des'), 'spObjectId', 'acdd72a7-3385-48ef-bd42-f606fba81ae7'))]",
+					"type": "Microsoft.Compute/diskEncryptionSets/providers/roleAssignments",
+					"apiVersion": "2018-09-01-preview",
+					"properties": {
+						"scope": "[resourceId('Microsoft.Compute/diskEncryptionSets', 'des')]",
+						"roleDefinitionId": "[subscriptionResourceId('Microsoft.Authorization/roleDefinitions', 'acdd72a7-3385-48ef-bd42-f606fba81ae7')]",
+						"principalId": "['spObjectId']",
+						"principalType": "ServicePrincipal"
+					}
+				}]
+			}
+		}
+	}]`), &want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %s", string(b))
+	}
+}
@@ -34,6 +34,9 @@ import (
 	"github.com/openshift/installer-aro-wrapper/pkg/util/subnet"
 )
 
+// masterCount is the number of control plane replicas of ARO clusters
+const masterCount = 3
+
 func (m *manager) generateInstallConfig(ctx context.Context) (*installconfig.InstallConfig, *releaseimage.Image, error) {
 	resourceGroup := stringutils.LastTokenByte(m.oc.Properties.ClusterProfile.ResourceGroupID, '/')
 
@@ -84,21 +87,21 @@ func (m *manager) generateInstallConfig(ctx context.Context) (*installconfig.Ins
 		domain += "." + m.env.Domain()
 	}
 
-	masterSKU, err := m.env.VMSku(string(m.oc.Properties.MasterProfile.VMSize))
+	masterSKU, err := m.vmSku(m.oc.Properties.MasterProfile.VMSize)
 	if err != nil {
 		return nil, nil, errors.WithStack(err)
 	}
-	masterZones := computeskus.Zones(masterSKU)
+	masterZones := computeskus.AvailableZones(masterSKU, m.oc.Location)
 	if len(masterZones) == 0 {
 		masterZones = []string{""}
 	}
 	masterVMNetworkingType := determineVMNetworkingType(masterSKU)
 
-	workerSKU, err := m.env.VMSku(string(m.oc.Properties.WorkerProfiles[0].VMSize))
+	workerSKU, err := m.vmSku(m.oc.Properties.WorkerProfiles[0].VMSize)
 	if err != nil {
 		return nil, nil, errors.WithStack(err)
 	}
-	workerZones := computeskus.Zones(workerSKU)
+	workerZones := computeskus.AvailableZones(workerSKU, m.oc.Location)
 	if len(workerZones) == 0 {
 		workerZones = []string{""}
 	}
@@ -184,7 +187,7 @@ func (m *manager) generateInstallConfig(ctx context.Context) (*installconfig.Ins
 				},
 				ControlPlane: &types.MachinePool{
 					Name:     "master",
-					Replicas: to.Int64Ptr(3),
+					Replicas: to.Int64Ptr(masterCount),
 					Platform: types.MachinePoolPlatform{
 						Azure: &azuretypes.MachinePool{
 							Zones:            masterZones,
@@ -302,6 +305,11 @@ func (m *manager) generateInstallConfig(ctx context.Context) (*installconfig.Ins
 		return nil, nil, fmt.Errorf("no release image in 'OPENSHIFT_INSTALL_RELEASE_IMAGE_OVERRIDE'")
 	}
 
+	err = m.verifyReleaseImage(ctx, releaseImageOverride, pullSecret)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	image := &releaseimage.Image{
 		PullSpec: releaseImageOverride,
 	}
@@ -0,0 +1,145 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	mgmtstorage "github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2019-06-01/storage"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/coreos/ignition/v2/config/v3_2/types"
+	"github.com/openshift/installer/pkg/asset/ignition"
+	"github.com/vincent-petithory/dataurl"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/util/arm/expression"
+)
+
+const (
+	// maxCustomDataSize is the maximum size of the (decoded) customData of an
+	// Azure VM
+	maxCustomDataSize = 65535
+
+	// sasTokenPlaceholder is substituted with the ARM expression evaluating
+	// to the SAS token when the pointer config is turned into customData
+	sasTokenPlaceholder = "__SAS_TOKEN__"
+
+	// resourceDeploymentTimeout bounds how long deploying the resources
+	// template takes.  The VMs must be able to fetch their ignition configs
+	// until it and bootstrapping have completed.
+	resourceDeploymentTimeout = time.Hour
+
+	// maxSASTokenLength is a generous upper bound for the length of the SAS
+	// token, which is only known when the template is deployed
+	maxSASTokenLength = 1024
+)
+
+// ignitionBlobURL returns the URL of the named blob in the ignition container
+// of the cluster storage account.
+func (m *manager) ignitionBlobURL(blob string) string {
+	return "https://cluster" + m.oc.Properties.StorageSuffix + ".blob." + m.env.Environment().StorageEndpointSuffix + "/ignition/" + blob
+}
+
+// ignitionBlobExists returns true if the named blob exists in the ignition
+// container of the cluster storage account
+func (m *manager) ignitionBlobExists(ctx context.Context, resourceGroup, account, blob string) (bool, error) {
+	b, err := m.storage.Blob(ctx, resourceGroup, account, "ignition", blob, mgmtstorage.Permissions("r"))
+	if err != nil {
+		return false, err
+	}
+
+	return b.Exists()
+}
+
+// ignitionPointerCustomData returns an ARM expression evaluating to the
+// customData of a VM which fetches its full ignition config from the named
+// blob.  If hash is set, ignition verifies the fetched config against it.  If
+// caBundle is set, it is trusted in addition to the system CAs.  Only the SAS
+// token is left to be evaluated by ARM.
+func (m *manager) ignitionPointerCustomData(blob, hash, caBundle string) (string, error) {
+	config := types.Config{
+		Ignition: types.Ignition{
+			Version: types.MaxVersion.String(),
+			Config: types.IgnitionConfig{
+				Replace: types.Resource{
+					Source: to.StringPtr(m.ignitionBlobURL(blob) + "?" + sasTokenPlaceholder),
+				},
+			},
+		},
+	}
+
+	if hash != "" {
+		config.Ignition.Config.Replace.Verification.Hash = &hash
+	}
+
+	if caBundle != "" {
+		config.Ignition.Security.TLS.CertificateAuthorities = []types.Resource{
+			{
+				Source: to.StringPtr(dataurl.EncodeBytes([]byte(caBundle))),
+			},
+		}
+	}
+
+	if m.oc.Properties.NetworkProfile.GatewayPrivateEndpointIP != "" {
+		config.Ignition.Proxy.HTTPSProxy = to.StringPtr("http://" + m.oc.Properties.NetworkProfile.GatewayPrivateEndpointIP)
+	}
+
+	b, err := ignition.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+
+	err = checkCustomDataSize(blob+" pointer", len(b)-len(sasTokenPlaceholder)+maxSASTokenLength)
+	if err != nil {
+		return "", err
+	}
+
+	parts := strings.SplitN(string(b), sasTokenPlaceholder, 2)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("%s pointer does not contain a SAS token placeholder", blob)
+	}
+
+	return expression.Format(expression.Base64(expression.Concat(expression.Literal(parts[0]), m.sasTokenExpression(blob), expression.Literal(parts[1])))), nil
+}
+
+// ignitionSASParameter returns the name of the template parameter holding
+// the service SAS parameters for the named blob, e.g. bootstrapIgnSas.
+func ignitionSASParameter(blob string) string {
+	return strings.TrimSuffix(blob, ".ign") + "IgnSas"
+}
+
+// ignitionSASParameters returns the parameters of a service SAS which grants
+// read access to the named blob of the ignition container, and nothing else.
+// It expires once bootstrapping of the install would have timed out.  The SAS
+// token ends up in the VMs' customData, which can't be changed on existing
+// VMs, so its parameters are derived from the install start time alone: a
+// redeploy must render the same customData.
+func (m *manager) ignitionSASParameters(blob string) map[string]interface{} {
+	return map[string]interface{}{
+		"canonicalizedResource": "/blob/cluster" + m.oc.Properties.StorageSuffix + "/ignition/" + blob,
+		"signedResource":        "b",
+		"signedPermission":      "r",
+		"signedStart":           m.oc.Properties.Install.Now.Format(time.RFC3339),
+		"signedExpiry":          m.oc.Properties.Install.Now.Add(resourceDeploymentTimeout + m.bootstrapTimeout).Format(time.RFC3339),
+		"signedProtocol":        "https",
+	}
+}
+
+// sasTokenExpression returns an ARM expression evaluating to the service SAS
+// token for the named blob of the ignition container.
+func (m *manager) sasTokenExpression(blob string) expression.Expression {
+	return expression.ListServiceSas(expression.ResourceID("Microsoft.Storage/storageAccounts", expression.Literal("cluster"+m.oc.Properties.StorageSuffix)), "2019-04-01", expression.Parameters(ignitionSASParameter(blob)))
+}
+
+// checkCustomDataSize returns an error if a customData payload of the given
+// size would be rejected by Azure.
+func checkCustomDataSize(name string, size int) error {
+	if size > maxCustomDataSize {
+		return fmt.Errorf("%s ignition config is %d bytes, which exceeds the Azure customData limit of %d bytes", name, size, maxCustomDataSize)
+	}
+
+	return nil
+}
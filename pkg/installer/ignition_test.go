@@ -0,0 +1,241 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	mgmtstorage "github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2019-06-01/storage"
+	azstorage "github.com/Azure/azure-sdk-for-go/storage"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/golang/mock/gomock"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/arm/expression"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/azureclient"
+	mock_env "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/env"
+	mock_storage "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/storage"
+)
+
+func TestIgnitionPointerCustomData(t *testing.T) {
+	const sas = `listServiceSas(resourceId('Microsoft.Storage/storageAccounts', 'clusterabcdef'), '2019-04-01', parameters('masterIgnSas')).serviceSasToken`
+	const hash = `sha512-a4abd4448c49562d828115d13a1fccea927f52b4d5459297f8b43e42da89238bc13626e43dcb38ddb082488927ec904fb42057443983e88585179d50551afe62`
+
+	for _, tt := range []struct {
+		name                     string
+		hash                     string
+		caBundle                 string
+		gatewayPrivateEndpointIP string
+		want                     string
+	}{
+		{
+			name: "pointer without verification",
+			want: `[base64(concat('{"ignition":{"config":{"replace":{"source":"https://clusterabcdef.blob.core.windows.net/ignition/master.ign?', ` + sas + `, '"}},"version":"3.2.0"}}'))]`,
+		},
+		{
+			name: "pointer",
+			hash: hash,
+			want: `[base64(concat('{"ignition":{"config":{"replace":{"source":"https://clusterabcdef.blob.core.windows.net/ignition/master.ign?', ` + sas + `, '","verification":{"hash":"` + hash + `"}}},"version":"3.2.0"}}'))]`,
+		},
+		{
+			name:                     "pointer with gateway proxy",
+			hash:                     hash,
+			gatewayPrivateEndpointIP: "10.0.0.4",
+			want:                     `[base64(concat('{"ignition":{"config":{"replace":{"source":"https://clusterabcdef.blob.core.windows.net/ignition/master.ign?', ` + sas + `, '","verification":{"hash":"` + hash + `"}}},"proxy":{"httpsProxy":"http://10.0.0.4"},"version":"3.2.0"}}'))]`,
+		},
+		{
+			name:     "pointer with CA bundle",
+			hash:     hash,
+			caBundle: "ca",
+			want:     `[base64(concat('{"ignition":{"config":{"replace":{"source":"https://clusterabcdef.blob.core.windows.net/ignition/master.ign?', ` + sas + `, '","verification":{"hash":"` + hash + `"}}},"security":{"tls":{"certificateAuthorities":[{"source":"data:text/plain;charset=utf-8;base64,Y2E="}]}},"version":"3.2.0"}}'))]`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			_env := mock_env.NewMockInterface(controller)
+			_env.EXPECT().Environment().Return(&azureclient.PublicCloud).AnyTimes()
+
+			m := &manager{
+				env: _env,
+				oc: &api.OpenShiftCluster{
+					Properties: api.OpenShiftClusterProperties{
+						StorageSuffix: "abcdef",
+						NetworkProfile: api.NetworkProfile{
+							GatewayPrivateEndpointIP: tt.gatewayPrivateEndpointIP,
+						},
+					},
+				},
+			}
+
+			got, err := m.ignitionPointerCustomData("master.ign", tt.hash, tt.caBundle)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckCustomDataSize(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		size    int
+		wantErr string
+	}{
+		{
+			name: "within limit",
+			size: maxCustomDataSize,
+		},
+		{
+			name:    "exceeds limit",
+			size:    maxCustomDataSize + 1,
+			wantErr: "worker ignition config is 65536 bytes, which exceeds the Azure customData limit of 65535 bytes",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkCustomDataSize("worker", tt.size)
+			if err != nil && err.Error() != tt.wantErr ||
+				err == nil && tt.wantErr != "" {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestIgnitionPointerCustomDataEvaluates(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	_env := mock_env.NewMockInterface(controller)
+	_env.EXPECT().Environment().Return(&azureclient.PublicCloud).AnyTimes()
+
+	m := &manager{
+		env: _env,
+		oc: &api.OpenShiftCluster{
+			Properties: api.OpenShiftClusterProperties{
+				StorageSuffix: "abcdef",
+			},
+		},
+	}
+
+	customData, err := m.ignitionPointerCustomData("master.ign", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := expression.EvaluateString(customData, &expression.Env{
+		Parameters: map[string]interface{}{
+			"masterIgnSas": map[string]interface{}{},
+		},
+		SASToken: "sv=2019-04-01&sig=it's",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := base64.StdEncoding.DecodeString(got.(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"ignition":{"config":{"replace":{"source":"https://clusterabcdef.blob.core.windows.net/ignition/master.ign?sv=2019-04-01&sig=it's"}},"version":"3.2.0"}}`
+	if string(b) != want {
+		t.Errorf("got %s", string(b))
+	}
+}
+
+// blobStatus answers every blob request with a fixed status code
+type blobStatus int
+
+func (s blobStatus) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{StatusCode: int(s), Body: io.NopCloser(&bytes.Buffer{}), Header: http.Header{}, Request: req}, nil
+}
+
+func TestIgnitionBlobExists(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		name   string
+		status int
+		want   bool
+	}{
+		{
+			name:   "uploaded",
+			status: http.StatusOK,
+			want:   true,
+		},
+		{
+			name:   "not uploaded by older graphs",
+			status: http.StatusNotFound,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			c := azstorage.NewAccountSASClient("clusterabcdef", url.Values{"sv": []string{"2019-02-02"}}, azure.PublicCloud)
+			c.HTTPClient = &http.Client{Transport: blobStatus(tt.status)}
+			blobService := c.GetBlobService()
+
+			storage := mock_storage.NewMockManager(controller)
+			storage.EXPECT().Blob(ctx, "resourceGroup", "clusterabcdef", "ignition", "master.ign", mgmtstorage.Permissions("r")).
+				Return(blobService.GetContainerReference("ignition").GetBlobReference("master.ign"), nil)
+
+			m := &manager{
+				storage: storage,
+			}
+
+			got, err := m.ignitionBlobExists(ctx, "resourceGroup", "clusterabcdef", "master.ign")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Error(got)
+			}
+		})
+	}
+}
+
+func TestIgnitionSASParametersAreStable(t *testing.T) {
+	installed := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	parameters := func(now time.Time) map[string]interface{} {
+		m := &manager{
+			now:              func() time.Time { return now },
+			bootstrapTimeout: 30 * time.Minute,
+			oc: &api.OpenShiftCluster{
+				Properties: api.OpenShiftClusterProperties{
+					StorageSuffix: "abcdef",
+					Install: &api.Install{
+						Now: installed,
+					},
+				},
+			},
+		}
+		return m.ignitionSASParameters("master.ign")
+	}
+
+	// a retried deployment must render the same customData
+	first := parameters(installed.Add(time.Minute))
+	retry := parameters(installed.Add(time.Hour))
+	if !reflect.DeepEqual(first, retry) {
+		t.Errorf("got %v, then %v", first, retry)
+	}
+
+	if first["signedExpiry"] != "2026-01-01T01:30:00Z" {
+		t.Errorf("expires at %s", first["signedExpiry"])
+	}
+}
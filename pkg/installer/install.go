@@ -14,6 +14,7 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 
+	"github.com/openshift/installer-aro-wrapper/pkg/api/validate"
 	"github.com/openshift/installer-aro-wrapper/pkg/cluster/graph"
 	"github.com/openshift/installer-aro-wrapper/pkg/util/restconfig"
 	"github.com/openshift/installer-aro-wrapper/pkg/util/steps"
@@ -28,6 +29,14 @@ func (m *manager) Manifests(ctx context.Context) (graph.Graph, error) {
 	)
 
 	s := []steps.Step{
+		steps.Action(m.validateOpenShiftCluster),
+		steps.AuthorizationRetryingAction(m.fpAuthorizer, m.validateVMSkus),
+		steps.Action(m.validateQuota),
+		steps.AuthorizationRetryingAction(m.fpAuthorizer, m.validatePermissions),
+		steps.AuthorizationRetryingAction(m.fpAuthorizer, m.validateVnet),
+		steps.AuthorizationRetryingAction(m.fpAuthorizer, m.validateRouteTables),
+		steps.AuthorizationRetryingAction(m.fpAuthorizer, m.validateDiskEncryptionSets),
+		steps.Action(m.validateResourceGroup),
 		steps.Action(func(ctx context.Context) error {
 			var err error
 			installConfig, image, err = m.generateInstallConfig(ctx)
@@ -51,15 +60,40 @@ func (m *manager) Manifests(ctx context.Context) (graph.Graph, error) {
 
 func (m *manager) Install(ctx context.Context) error {
 	s := []steps.Step{
+		steps.Action(m.validateOpenShiftCluster),
+		steps.AuthorizationRetryingAction(m.fpAuthorizer, m.validateVMSkus),
+		steps.Action(m.validateQuota),
+		steps.AuthorizationRetryingAction(m.fpAuthorizer, m.validatePermissions),
+		steps.AuthorizationRetryingAction(m.fpAuthorizer, m.validateVnet),
+		steps.AuthorizationRetryingAction(m.fpAuthorizer, m.validateRouteTables),
+		steps.AuthorizationRetryingAction(m.fpAuthorizer, m.validateDiskEncryptionSets),
+		steps.Action(m.validateResourceGroup),
+		steps.Action(m.validateDenyAssignments),
 		steps.AuthorizationRetryingAction(m.fpAuthorizer, m.deployResourceTemplate),
 		steps.Action(m.initializeKubernetesClients),
-		steps.Condition(m.bootstrapConfigMapReady, 30*time.Minute, true),
 	}
 
 	err := steps.Run(ctx, m.log, 10*time.Second, s)
+	if err != nil {
+		return err
+	}
+
+	err = steps.Run(ctx, m.log, 10*time.Second, []steps.Step{
+		steps.Condition(m.bootstrapConfigMapReady, m.bootstrapTimeout, true),
+	})
+	if err != nil {
+		m.gatherBootDiagnostics(ctx)
+	}
+
 	return err
 }
 
+// validateOpenShiftCluster statically validates the cluster document before
+// anything consumes it
+func (m *manager) validateOpenShiftCluster(ctx context.Context) error {
+	return validate.OpenShiftCluster(m.oc)
+}
+
 // initializeKubernetesClients initializes clients using the Installer-generated
 // kubeconfig.
 func (m *manager) initializeKubernetesClients(ctx context.Context) error {
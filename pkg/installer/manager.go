@@ -5,20 +5,54 @@ package installer
 
 import (
 	"context"
+	"fmt"
+	"net"
+	"os"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	mgmtcompute "github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2020-06-01/compute"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/jongio/azidext/go/azidext"
 	"github.com/sirupsen/logrus"
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/openshift/installer-aro-wrapper/pkg/api"
 	"github.com/openshift/installer-aro-wrapper/pkg/cluster/graph"
 	"github.com/openshift/installer-aro-wrapper/pkg/env"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/azureclient/mgmt/authorization"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/azureclient/mgmt/compute"
 	"github.com/openshift/installer-aro-wrapper/pkg/util/azureclient/mgmt/features"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/azureclient/mgmt/network"
 	"github.com/openshift/installer-aro-wrapper/pkg/util/refreshable"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/storage"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/subnet"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/version"
 )
 
+// defaultBootstrapTimeout is how long Install waits for bootstrapping to
+// complete unless ARO_BOOTSTRAP_TIMEOUT is set
+const defaultBootstrapTimeout = 30 * time.Minute
+
 type manager struct {
 	log *logrus.Entry
 	env env.Interface
+	now func() time.Time
+
+	// lookupIP resolves the endpoints the cluster needs to reach
+	lookupIP func(ctx context.Context, network, host string) ([]net.IP, error)
+
+	// releaseImageVersion reads the OpenShift version of a release image from
+	// its registry
+	releaseImageVersion func(ctx context.Context, pullSpec, pullSecret string) (*version.Version, error)
+
+	// bootstrapTimeout is how long Install waits for bootstrapping to
+	// complete.  The SAS tokens handed to the VMs expire accordingly.
+	bootstrapTimeout time.Duration
+
+	// assetsDir is the local directory given with --dir.  Boot diagnostics
+	// gathered after a failed bootstrap are written here.
+	assetsDir string
 
 	// clusterUUID is the UUID of the OpenShiftClusterDocument that contained
 	// this OpenShiftCluster. It should be used where a unique ID for this
@@ -28,9 +62,33 @@ type manager struct {
 	sub          *api.Subscription
 	fpAuthorizer refreshable.Authorizer
 
-	deployments features.DeploymentsClient
+	computeUsage         compute.UsageClient
+	denyAssignments      authorization.DenyAssignmentClient
+	deploymentOperations features.DeploymentOperationsClient
+	deployments          features.DeploymentsClient
+	diskEncryptionSets   compute.DiskEncryptionSetsClient
+	networkUsage         network.UsageClient
+	privateEndpoints     network.PrivateEndpointsClient
+	resourceSkus         compute.ResourceSkusClient
+	resources            features.ResourcesClient
+	roleAssignments      authorization.RoleAssignmentsClient
+	roleDefinitions      authorization.RoleDefinitionsClient
+	routeTables          network.RouteTablesClient
+	virtualMachines      compute.VirtualMachinesClient
+	virtualNetworks      network.VirtualNetworksClient
+
+	// spPermissions lists the permissions of the cluster service principal.
+	// It is nil for workload identity clusters.
+	spPermissions authorization.PermissionsClient
+
+	subnet subnet.Manager
+
+	// vmSkus are the VM SKUs available to the customer subscription in the
+	// cluster location, once validateVMSkus has run
+	vmSkus map[string]*mgmtcompute.ResourceSku
 
-	graph graph.Manager
+	graph   graph.Manager
+	storage storage.Manager
 
 	kubernetescli kubernetes.Interface
 }
@@ -40,15 +98,60 @@ type Interface interface {
 	Manifests(ctx context.Context) (graph.Graph, error)
 }
 
-func NewInstaller(log *logrus.Entry, _env env.Interface, clusterUUID string, oc *api.OpenShiftCluster, subscription *api.Subscription, fpAuthorizer refreshable.Authorizer, deployments features.DeploymentsClient, g graph.Manager) Interface {
-	return &manager{
-		log:          log,
-		env:          _env,
-		clusterUUID:  clusterUUID,
-		oc:           oc,
-		sub:          subscription,
-		fpAuthorizer: fpAuthorizer,
-		deployments:  deployments,
-		graph:        g,
+func NewInstaller(log *logrus.Entry, _env env.Interface, assetsDir string, clusterUUID string, oc *api.OpenShiftCluster, subscription *api.Subscription, fpAuthorizer refreshable.Authorizer, storage storage.Manager, g graph.Manager) (Interface, error) {
+	r, err := azure.ParseResourceID(oc.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	bootstrapTimeout := defaultBootstrapTimeout
+	if value, found := os.LookupEnv("ARO_BOOTSTRAP_TIMEOUT"); found {
+		bootstrapTimeout, err = time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ARO_BOOTSTRAP_TIMEOUT: %w", err)
+		}
+	}
+
+	var spPermissions authorization.PermissionsClient
+	if spp := oc.Properties.ServicePrincipalProfile; spp != nil {
+		credential, err := azidentity.NewClientSecretCredential(subscription.Properties.TenantID, spp.ClientID, string(spp.ClientSecret), _env.Environment().ClientSecretCredentialOptions())
+		if err != nil {
+			return nil, err
+		}
+
+		spAuthorizer := azidext.NewTokenCredentialAdapter(credential, []string{_env.Environment().ResourceManagerScope})
+		spPermissions = authorization.NewPermissionsClient(_env.Environment(), r.SubscriptionID, spAuthorizer)
 	}
+
+	return &manager{
+		log:                  log,
+		env:                  _env,
+		now:                  time.Now,
+		lookupIP:             net.DefaultResolver.LookupIP,
+		releaseImageVersion:  readReleaseImageVersion,
+		bootstrapTimeout:     bootstrapTimeout,
+		assetsDir:            assetsDir,
+		clusterUUID:          clusterUUID,
+		oc:                   oc,
+		sub:                  subscription,
+		fpAuthorizer:         fpAuthorizer,
+		computeUsage:         compute.NewUsageClient(_env.Environment(), r.SubscriptionID, fpAuthorizer),
+		denyAssignments:      authorization.NewDenyAssignmentsClient(_env.Environment(), r.SubscriptionID, fpAuthorizer),
+		deploymentOperations: features.NewDeploymentOperationsClient(_env.Environment(), r.SubscriptionID, fpAuthorizer),
+		deployments:          features.NewDeploymentsClient(_env.Environment(), r.SubscriptionID, fpAuthorizer),
+		diskEncryptionSets:   compute.NewDiskEncryptionSetsClient(_env.Environment(), r.SubscriptionID, fpAuthorizer),
+		networkUsage:         network.NewUsageClient(_env.Environment(), r.SubscriptionID, fpAuthorizer),
+		privateEndpoints:     network.NewPrivateEndpointsClient(_env.Environment(), r.SubscriptionID, fpAuthorizer),
+		resourceSkus:         compute.NewResourceSkusClient(_env.Environment(), r.SubscriptionID, fpAuthorizer),
+		resources:            features.NewResourcesClient(_env.Environment(), r.SubscriptionID, fpAuthorizer),
+		roleAssignments:      authorization.NewRoleAssignmentsClient(_env.Environment(), r.SubscriptionID, fpAuthorizer),
+		roleDefinitions:      authorization.NewRoleDefinitionsClient(_env.Environment(), r.SubscriptionID, fpAuthorizer),
+		routeTables:          network.NewRouteTablesClient(_env.Environment(), r.SubscriptionID, fpAuthorizer),
+		virtualMachines:      compute.NewVirtualMachinesClient(_env.Environment(), r.SubscriptionID, fpAuthorizer),
+		virtualNetworks:      network.NewVirtualNetworksClient(_env.Environment(), r.SubscriptionID, fpAuthorizer),
+		spPermissions:        spPermissions,
+		subnet:               subnet.NewManager(_env.Environment(), r.SubscriptionID, fpAuthorizer),
+		graph:                g,
+		storage:              storage,
+	}, nil
 }
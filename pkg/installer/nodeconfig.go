@@ -0,0 +1,150 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/coreos/ignition/v2/config/v3_2/types"
+	"github.com/openshift/installer/pkg/asset/ignition"
+	"github.com/openshift/installer/pkg/asset/ignition/bootstrap"
+	mcv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+	"github.com/openshift/installer-aro-wrapper/pkg/cluster/graph"
+)
+
+const (
+	chronyConfPath = "/etc/chrony.conf"
+	sysctlConfPath = "/etc/sysctl.d/99-aro-nodeconfig.conf"
+)
+
+// chronyConf returns a chrony.conf using the given servers in place of the
+// default pool.  The Hyper-V PTP clock of the default Azure chrony.conf is
+// kept as a reference clock alongside them.
+func chronyConf(servers []string) string {
+	var sb strings.Builder
+
+	for _, server := range servers {
+		fmt.Fprintf(&sb, "server %s iburst\n", server)
+	}
+
+	sb.WriteString(`refclock PHC /dev/ptp_hyperv poll 3 dpoll -2 offset 0 stratum 2
+driftfile /var/lib/chrony/drift
+makestep 1.0 3
+rtcsync
+logdir /var/log/chrony
+`)
+
+	return sb.String()
+}
+
+// nodeConfigProfile returns the profile of nc for the given role
+func nodeConfigProfile(nc *api.NodeConfig, role string) *api.NodeConfigProfile {
+	if role == "worker" {
+		return &nc.Worker
+	}
+	return &nc.Master
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// sysctlConf returns a sysctl.d file setting the given sysctls, sorted by key
+func sysctlConf(sysctls map[string]string) string {
+	var sb strings.Builder
+	for _, key := range sortedKeys(sysctls) {
+		fmt.Fprintf(&sb, "%s = %s\n", key, sysctls[key])
+	}
+
+	return sb.String()
+}
+
+// newNodeConfigMachineConfig returns the MachineConfig applying the node
+// config profile to the given role, or nil if the profile is empty.
+func newNodeConfigMachineConfig(role string, p *api.NodeConfigProfile) (*mcv1.MachineConfig, error) {
+	if len(p.ChronyServers) == 0 && len(p.KernelArguments) == 0 && len(p.Sysctls) == 0 {
+		return nil, nil
+	}
+
+	config := types.Config{
+		Ignition: types.Ignition{
+			Version: types.MaxVersion.String(),
+		},
+	}
+
+	if len(p.ChronyServers) > 0 {
+		config.Storage.Files = append(config.Storage.Files, ignition.FileFromString(chronyConfPath, "root", 0644, chronyConf(p.ChronyServers)))
+	}
+
+	if len(p.Sysctls) > 0 {
+		config.Storage.Files = append(config.Storage.Files, ignition.FileFromString(sysctlConfPath, "root", 0644, sysctlConf(p.Sysctls)))
+	}
+
+	rawExt, err := ignition.ConvertToRawExtension(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mcv1.MachineConfig{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: mcv1.SchemeGroupVersion.String(),
+			Kind:       "MachineConfig",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: fmt.Sprintf("99-%s-aro-nodeconfig", role),
+			Labels: map[string]string{
+				"machineconfiguration.openshift.io/role": role,
+			},
+		},
+		Spec: mcv1.MachineConfigSpec{
+			Config:          rawExt,
+			KernelArguments: p.KernelArguments,
+		},
+	}, nil
+}
+
+// applyNodeConfig adds the MachineConfig manifests implementing the cluster's
+// node config to the bootstrap node's Ignition config:
+//
+// /opt/openshift/openshift/99_openshift-machineconfig_99-master-aro-nodeconfig.yaml
+// /opt/openshift/openshift/99_openshift-machineconfig_99-worker-aro-nodeconfig.yaml
+func (m *manager) applyNodeConfig(g graph.Graph) error {
+	bootstrap := g.Get(&bootstrap.Bootstrap{}).(*bootstrap.Bootstrap)
+
+	for _, role := range []string{"master", "worker"} {
+		mc, err := newNodeConfigMachineConfig(role, nodeConfigProfile(m.oc.Properties.NodeConfig, role))
+		if err != nil {
+			return err
+		}
+		if mc == nil {
+			continue
+		}
+
+		ignitionFile, err := machineConfigIgnitionFile(mc, role)
+		if err != nil {
+			return err
+		}
+		bootstrap.Config.Storage.Files = append(bootstrap.Config.Storage.Files, ignitionFile)
+	}
+
+	data, err := ignition.Marshal(bootstrap.Config)
+	if err != nil {
+		return errors.Wrap(err, "failed to Marshal Ignition config")
+	}
+	bootstrap.File.Data = data
+
+	return nil
+}
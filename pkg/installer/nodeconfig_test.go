@@ -0,0 +1,70 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/v3_2/types"
+	"github.com/vincent-petithory/dataurl"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+)
+
+func TestNewNodeConfigMachineConfig(t *testing.T) {
+	mc, err := newNodeConfigMachineConfig("worker", &api.NodeConfigProfile{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mc != nil {
+		t.Error("expected no MachineConfig for an empty profile")
+	}
+
+	mc, err = newNodeConfigMachineConfig("master", &api.NodeConfigProfile{
+		ChronyServers:   []string{"ntp.example.com"},
+		KernelArguments: []string{"fips=1"},
+		Sysctls: map[string]string{
+			"vm.swappiness":               "10",
+			"net.ipv4.tcp_keepalive_time": "600",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mc.Name != "99-master-aro-nodeconfig" {
+		t.Error(mc.Name)
+	}
+	if mc.Labels["machineconfiguration.openshift.io/role"] != "master" {
+		t.Error(mc.Labels)
+	}
+	if !reflect.DeepEqual(mc.Spec.KernelArguments, []string{"fips=1"}) {
+		t.Error(mc.Spec.KernelArguments)
+	}
+
+	var config types.Config
+	err = json.Unmarshal(mc.Spec.Config.Raw, &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{}
+	for _, f := range config.Storage.Files {
+		du, err := dataurl.DecodeString(*f.Contents.Source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Path] = string(du.Data)
+	}
+
+	want := map[string]string{
+		chronyConfPath: "server ntp.example.com iburst\nrefclock PHC /dev/ptp_hyperv poll 3 dpoll -2 offset 0 stratum 2\ndriftfile /var/lib/chrony/drift\nmakestep 1.0 3\nrtcsync\nlogdir /var/log/chrony\n",
+		sysctlConfPath: "net.ipv4.tcp_keepalive_time = 600\nvm.swappiness = 10\n",
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("got %q, want %q", files, want)
+	}
+}
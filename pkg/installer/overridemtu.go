@@ -63,8 +63,13 @@ func newMTUMachineConfigIgnitionFile(role string) (types.File, error) {
 		},
 	}
 
-	configs := []*mcv1.MachineConfig{mtuMachineConfig}
-	manifests, err := machineconfig.Manifests(configs, role, "/opt/openshift/openshift")
+	return machineConfigIgnitionFile(mtuMachineConfig, role)
+}
+
+// machineConfigIgnitionFile returns the bootstrap ignition file holding the
+// manifest of the given MachineConfig, so that it is applied from first boot.
+func machineConfigIgnitionFile(mc *mcv1.MachineConfig, role string) (types.File, error) {
+	manifests, err := machineconfig.Manifests([]*mcv1.MachineConfig{mc}, role, "/opt/openshift/openshift")
 	if err != nil {
 		return types.File{}, err
 	}
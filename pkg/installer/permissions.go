@@ -0,0 +1,354 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	mgmtauthorization "github.com/Azure/azure-sdk-for-go/services/preview/authorization/mgmt/2018-09-01-preview/authorization"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+	"github.com/openshift/installer-aro-wrapper/pkg/env"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/permissions"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/subnet"
+)
+
+// requiredPermission is a set of actions the cluster identities need on a
+// resource
+type requiredPermission struct {
+	resourceID string
+	actions    []string
+}
+
+var (
+	virtualNetworkActions = []string{
+		"Microsoft.Network/virtualNetworks/join/action",
+		"Microsoft.Network/virtualNetworks/read",
+		"Microsoft.Network/virtualNetworks/subnets/join/action",
+		"Microsoft.Network/virtualNetworks/subnets/read",
+	}
+
+	routeTableActions = []string{
+		"Microsoft.Network/routeTables/join/action",
+		"Microsoft.Network/routeTables/read",
+	}
+
+	networkSecurityGroupActions = []string{
+		"Microsoft.Network/networkSecurityGroups/join/action",
+		"Microsoft.Network/networkSecurityGroups/read",
+	}
+
+	diskEncryptionSetActions = []string{
+		"Microsoft.Compute/diskEncryptionSets/read",
+	}
+
+	// platformWorkloadIdentityActions are the actions each platform workload
+	// identity needs on the customer resources, keyed by operator name,
+	// following the RP's per-operator role definitions.  Operators not listed
+	// here don't need access to the customer resources.
+	platformWorkloadIdentityActions = map[string][]string{
+		"AzureFilesStorageOperator": concatActions(virtualNetworkActions, networkSecurityGroupActions),
+		"CloudControllerManager":    concatActions(virtualNetworkActions, networkSecurityGroupActions),
+		"ClusterIngressOperator":    virtualNetworkActions,
+		"ImageRegistryOperator":     virtualNetworkActions,
+		"MachineApiOperator":        concatActions(virtualNetworkActions, routeTableActions, networkSecurityGroupActions, diskEncryptionSetActions),
+		"NetworkOperator":           concatActions(virtualNetworkActions, routeTableActions, networkSecurityGroupActions),
+		"ServiceOperator":           concatActions(virtualNetworkActions, routeTableActions, networkSecurityGroupActions),
+		"StorageOperator":           concatActions(virtualNetworkActions, diskEncryptionSetActions),
+	}
+)
+
+func concatActions(actionLists ...[]string) []string {
+	var actions []string
+	for _, l := range actionLists {
+		actions = append(actions, l...)
+	}
+	return actions
+}
+
+// actionsFor returns the actions of rp the platform workload identity of the
+// given operator needs
+func (rp *requiredPermission) actionsFor(operatorName string) []string {
+	var actions []string
+	for _, action := range rp.actions {
+		for _, a := range platformWorkloadIdentityActions[operatorName] {
+			if a == action {
+				actions = append(actions, action)
+				break
+			}
+		}
+	}
+	return actions
+}
+
+// requiredPermissions returns the actions the cluster identities need on the
+// customer resources the cluster is linked to: the VNet and its subnets, the
+// subnets' route tables and NSGs, and the disk encryption sets.
+func (m *manager) requiredPermissions(ctx context.Context) ([]requiredPermission, error) {
+	vnetID, _, err := subnet.Split(m.oc.Properties.MasterProfile.SubnetID)
+	if err != nil {
+		return nil, err
+	}
+
+	var rps []requiredPermission
+	seen := map[string]bool{}
+
+	add := func(resourceID string, actions []string) {
+		if resourceID == "" || seen[strings.ToLower(resourceID)] {
+			return
+		}
+		seen[strings.ToLower(resourceID)] = true
+		rps = append(rps, requiredPermission{resourceID: resourceID, actions: actions})
+	}
+
+	// the resources template grants the network roles itself if asked to
+	networkRoleAssigned := m.env.FeatureIsSet(env.FeatureEnableNetworkRoleAssignment)
+	if !networkRoleAssigned {
+		add(vnetID, virtualNetworkActions)
+	}
+
+	subnetIDs := []string{m.oc.Properties.MasterProfile.SubnetID}
+	for _, wp := range m.oc.Properties.WorkerProfiles {
+		subnetIDs = append(subnetIDs, wp.SubnetID)
+	}
+
+	for _, subnetID := range subnetIDs {
+		if seen[strings.ToLower(subnetID)] {
+			continue
+		}
+		seen[strings.ToLower(subnetID)] = true
+
+		s, err := m.subnet.Get(ctx, subnetID)
+		if err != nil {
+			return nil, err
+		}
+
+		if s.SubnetPropertiesFormat == nil {
+			continue
+		}
+
+		if !networkRoleAssigned && s.RouteTable != nil && s.RouteTable.ID != nil {
+			add(*s.RouteTable.ID, routeTableActions)
+		}
+
+		if s.NetworkSecurityGroup != nil && s.NetworkSecurityGroup.ID != nil {
+			add(*s.NetworkSecurityGroup.ID, networkSecurityGroupActions)
+		}
+	}
+
+	// the resources template grants read access to the disk encryption sets
+	// itself if asked to
+	if !m.env.FeatureIsSet(env.FeatureEnableDiskEncryptionSetRoleAssignment) {
+		add(m.oc.Properties.MasterProfile.DiskEncryptionSetID, diskEncryptionSetActions)
+		for _, wp := range m.oc.Properties.WorkerProfiles {
+			add(wp.DiskEncryptionSetID, diskEncryptionSetActions)
+		}
+	}
+
+	return rps, nil
+}
+
+// principalPermissions are the permissions one of the cluster identities has
+// on a resource, and the actions it needs there
+type principalPermissions struct {
+	description string
+	actions     []string
+	permissions []mgmtauthorization.Permission
+}
+
+// identityPermissions returns the permissions each of the cluster identities
+// has on the resource of rp.  For service principal clusters these are listed
+// using the service principal's own token, and the service principal needs
+// all the actions of rp.  The platform workload identities can't authenticate
+// here, so their role assignments on the resource are resolved instead, and
+// each only needs the actions of its operator.
+func (m *manager) identityPermissions(ctx context.Context, rp *requiredPermission, roleDefinitions map[string][]mgmtauthorization.Permission) ([]principalPermissions, error) {
+	r, err := azure.ParseResourceID(rp.resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !m.oc.UsesWorkloadIdentity() {
+		ps, err := m.spPermissions.ListForResource(ctx, r.ResourceGroup, r.Provider, "", r.ResourceType, r.ResourceName)
+		if err != nil {
+			return nil, err
+		}
+
+		return []principalPermissions{{description: "cluster service principal", actions: rp.actions, permissions: ps}}, nil
+	}
+
+	var pps []principalPermissions
+	for _, identity := range m.oc.Properties.PlatformWorkloadIdentityProfile.PlatformWorkloadIdentities {
+		actions := rp.actionsFor(identity.OperatorName)
+		if len(actions) == 0 {
+			continue
+		}
+
+		ps, err := m.assignedPermissions(ctx, rp.resourceID, identity.ObjectID, roleDefinitions)
+		if err != nil {
+			return nil, err
+		}
+
+		pps = append(pps, principalPermissions{
+			description: fmt.Sprintf("platform workload identity '%s'", identity.OperatorName),
+			actions:     actions,
+			permissions: ps,
+		})
+	}
+
+	return pps, nil
+}
+
+// assignedPermissions returns the permissions of the roles assigned to
+// principalID on the given resource, directly or inherited from a parent
+// scope
+func (m *manager) assignedPermissions(ctx context.Context, resourceID, principalID string, roleDefinitions map[string][]mgmtauthorization.Permission) ([]mgmtauthorization.Permission, error) {
+	r, err := azure.ParseResourceID(resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	assignments, err := m.roleAssignments.ListForResource(ctx, r.ResourceGroup, r.Provider, "", r.ResourceType, r.ResourceName, fmt.Sprintf("assignedTo('%s')", principalID))
+	if err != nil {
+		return nil, err
+	}
+
+	var ps []mgmtauthorization.Permission
+	for _, assignment := range assignments {
+		if assignment.RoleAssignmentPropertiesWithScope == nil || assignment.RoleDefinitionID == nil {
+			continue
+		}
+
+		ps = append(ps, roleDefinitions[strings.ToLower(*assignment.RoleDefinitionID)]...)
+	}
+
+	return ps, nil
+}
+
+// listRoleDefinitions returns the permissions of the role definitions
+// available in the cluster subscription, keyed by lower-cased role definition
+// ID
+func (m *manager) listRoleDefinitions(ctx context.Context) (map[string][]mgmtauthorization.Permission, error) {
+	r, err := azure.ParseResourceID(m.oc.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	definitions, err := m.roleDefinitions.List(ctx, "/subscriptions/"+r.SubscriptionID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	roleDefinitions := map[string][]mgmtauthorization.Permission{}
+	for _, definition := range definitions {
+		if definition.ID == nil || definition.RoleDefinitionProperties == nil || definition.Permissions == nil {
+			continue
+		}
+
+		roleDefinitions[strings.ToLower(*definition.ID)] = *definition.Permissions
+	}
+
+	return roleDefinitions, nil
+}
+
+// missingPermissions returns an error body for each action one of the cluster
+// identities lacks on a resource it needs it on
+func (m *manager) missingPermissions(ctx context.Context) ([]api.CloudErrorBody, error) {
+	rps, err := m.requiredPermissions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// only workload identity clusters resolve role assignments
+	var roleDefinitions map[string][]mgmtauthorization.Permission
+	if m.oc.UsesWorkloadIdentity() {
+		roleDefinitions, err = m.listRoleDefinitions(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var missing []api.CloudErrorBody
+	for i := range rps {
+		rp := &rps[i]
+
+		pps, err := m.identityPermissions(ctx, rp, roleDefinitions)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pp := range pps {
+			for _, action := range pp.actions {
+				ok, err := permissions.CanDoAction(pp.permissions, action)
+				if err != nil {
+					return nil, err
+				}
+
+				if !ok {
+					missing = append(missing, api.CloudErrorBody{
+						Code:    api.CloudErrorCodeInvalidServicePrincipalPermissions,
+						Target:  rp.resourceID,
+						Message: fmt.Sprintf("The %s does not have permission to perform action '%s' on resource '%s'.", pp.description, action, rp.resourceID),
+					})
+				}
+			}
+		}
+	}
+
+	return missing, nil
+}
+
+// validatePermissions checks that the cluster identities have the actions
+// they need on the customer resources.  Role assignments can take several
+// minutes to propagate, so missing actions are only reported once they have
+// still been missing after permissionsTimeout.
+func (m *manager) validatePermissions(ctx context.Context) error {
+	if m.spPermissions == nil && !m.oc.UsesWorkloadIdentity() {
+		return nil
+	}
+
+	m.log.Print("validating permissions")
+
+	return m.pollPermissions(ctx, 30*time.Second, permissionsTimeout)
+}
+
+// ARM has a 5 minute cache around role assignment creation, so wait one minute
+// longer
+const permissionsTimeout = 6 * time.Minute
+
+func (m *manager) pollPermissions(ctx context.Context, interval, timeout time.Duration) error {
+	var missing []api.CloudErrorBody
+
+	err := wait.PollUntilContextTimeout(ctx, interval, timeout, true, func(_ context.Context) (bool, error) {
+		var err error
+		missing, err = m.missingPermissions(ctx)
+		if err != nil {
+			return false, err
+		}
+
+		if len(missing) > 0 {
+			m.log.Printf("%d required actions are missing, waiting for role assignments to propagate", len(missing))
+		}
+
+		return len(missing) == 0, nil
+	})
+	if len(missing) > 0 {
+		message := "The cluster service principal does not have the permissions needed to create the cluster."
+		if m.oc.UsesWorkloadIdentity() {
+			message = "The platform workload identities do not have the permissions needed to create the cluster."
+		}
+
+		cloudErr := api.NewCloudError(http.StatusBadRequest, api.CloudErrorCodeInvalidServicePrincipalPermissions, "", message)
+		cloudErr.Details = missing
+		return cloudErr
+	}
+
+	return err
+}
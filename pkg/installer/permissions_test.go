@@ -0,0 +1,253 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	mgmtnetwork "github.com/Azure/azure-sdk-for-go/services/network/mgmt/2020-08-01/network"
+	mgmtauthorization "github.com/Azure/azure-sdk-for-go/services/preview/authorization/mgmt/2018-09-01-preview/authorization"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+	"github.com/openshift/installer-aro-wrapper/pkg/env"
+	mock_authorization "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/azureclient/mgmt/authorization"
+	mock_env "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/env"
+	mock_subnet "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/subnet"
+)
+
+func TestPollPermissions(t *testing.T) {
+	ctx := context.Background()
+
+	vnetID := "/subscriptions/subscriptionId/resourceGroups/vnetResourceGroup/providers/Microsoft.Network/virtualNetworks/vnet"
+	masterSubnetID := vnetID + "/subnets/master"
+	workerSubnetID := vnetID + "/subnets/worker"
+	rtID := "/subscriptions/subscriptionId/resourceGroups/vnetResourceGroup/providers/Microsoft.Network/routeTables/rt"
+	desID := "/subscriptions/subscriptionId/resourceGroups/desResourceGroup/providers/Microsoft.Compute/diskEncryptionSets/des"
+	roleDefinitionID := "/subscriptions/subscriptionId/providers/Microsoft.Authorization/roleDefinitions/network"
+	vnetRoleDefinitionID := "/subscriptions/subscriptionId/providers/Microsoft.Authorization/roleDefinitions/vnet"
+
+	all := []mgmtauthorization.Permission{
+		{Actions: &[]string{"*"}},
+	}
+
+	mockSubnets := func(s *mock_subnet.MockManager) {
+		s.EXPECT().Get(ctx, masterSubnetID).Return(&mgmtnetwork.Subnet{
+			SubnetPropertiesFormat: &mgmtnetwork.SubnetPropertiesFormat{
+				RouteTable: &mgmtnetwork.RouteTable{ID: to.StringPtr(rtID)},
+			},
+		}, nil)
+		s.EXPECT().Get(ctx, workerSubnetID).Return(&mgmtnetwork.Subnet{
+			SubnetPropertiesFormat: &mgmtnetwork.SubnetPropertiesFormat{
+				RouteTable: &mgmtnetwork.RouteTable{ID: to.StringPtr(rtID)},
+			},
+		}, nil)
+	}
+
+	mockRoleDefinitions := func(rd *mock_authorization.MockRoleDefinitionsClient) {
+		rd.EXPECT().List(ctx, "/subscriptions/subscriptionId", "").Return([]mgmtauthorization.RoleDefinition{
+			{
+				ID: to.StringPtr(roleDefinitionID),
+				RoleDefinitionProperties: &mgmtauthorization.RoleDefinitionProperties{
+					Permissions: &[]mgmtauthorization.Permission{
+						{Actions: &[]string{"Microsoft.Network/*", "Microsoft.Compute/diskEncryptionSets/read"}},
+					},
+				},
+			},
+			{
+				ID: to.StringPtr(vnetRoleDefinitionID),
+				RoleDefinitionProperties: &mgmtauthorization.RoleDefinitionProperties{
+					Permissions: &[]mgmtauthorization.Permission{
+						{Actions: &[]string{"Microsoft.Network/virtualNetworks/*"}},
+					},
+				},
+			},
+		}, nil)
+	}
+
+	assigned := []mgmtauthorization.RoleAssignment{
+		{
+			RoleAssignmentPropertiesWithScope: &mgmtauthorization.RoleAssignmentPropertiesWithScope{
+				RoleDefinitionID: to.StringPtr(roleDefinitionID),
+			},
+		},
+	}
+
+	vnetAssigned := []mgmtauthorization.RoleAssignment{
+		{
+			RoleAssignmentPropertiesWithScope: &mgmtauthorization.RoleAssignmentPropertiesWithScope{
+				RoleDefinitionID: to.StringPtr(vnetRoleDefinitionID),
+			},
+		},
+	}
+
+	for _, tt := range []struct {
+		name             string
+		workloadIdentity bool
+		identities       []api.PlatformWorkloadIdentity
+		desRoleAssigned  bool
+		netRoleAssigned  bool
+		mocks            func(*mock_subnet.MockManager, *mock_authorization.MockPermissionsClient, *mock_authorization.MockRoleAssignmentsClient, *mock_authorization.MockRoleDefinitionsClient)
+		wantErr          string
+	}{
+		{
+			name: "service principal has all permissions",
+			mocks: func(s *mock_subnet.MockManager, p *mock_authorization.MockPermissionsClient, ra *mock_authorization.MockRoleAssignmentsClient, rd *mock_authorization.MockRoleDefinitionsClient) {
+				mockSubnets(s)
+				p.EXPECT().ListForResource(ctx, "vnetResourceGroup", "Microsoft.Network", "", "virtualNetworks", "vnet").Return(all, nil)
+				p.EXPECT().ListForResource(ctx, "vnetResourceGroup", "Microsoft.Network", "", "routeTables", "rt").Return(all, nil)
+				p.EXPECT().ListForResource(ctx, "desResourceGroup", "Microsoft.Compute", "", "diskEncryptionSets", "des").Return(all, nil)
+			},
+		},
+		{
+			name: "service principal is missing permissions",
+			mocks: func(s *mock_subnet.MockManager, p *mock_authorization.MockPermissionsClient, ra *mock_authorization.MockRoleAssignmentsClient, rd *mock_authorization.MockRoleDefinitionsClient) {
+				mockSubnets(s)
+				p.EXPECT().ListForResource(ctx, "vnetResourceGroup", "Microsoft.Network", "", "virtualNetworks", "vnet").Return([]mgmtauthorization.Permission{
+					{
+						Actions:    &[]string{"Microsoft.Network/virtualNetworks/*"},
+						NotActions: &[]string{"Microsoft.Network/virtualNetworks/subnets/join/action"},
+					},
+				}, nil)
+				p.EXPECT().ListForResource(ctx, "vnetResourceGroup", "Microsoft.Network", "", "routeTables", "rt").Return(nil, nil)
+				p.EXPECT().ListForResource(ctx, "desResourceGroup", "Microsoft.Compute", "", "diskEncryptionSets", "des").Return(all, nil)
+			},
+			wantErr: "400: InvalidServicePrincipalPermissions: : The cluster service principal does not have the permissions needed to create the cluster. Details: " +
+				"InvalidServicePrincipalPermissions: " + vnetID + ": The cluster service principal does not have permission to perform action 'Microsoft.Network/virtualNetworks/subnets/join/action' on resource '" + vnetID + "'., " +
+				"InvalidServicePrincipalPermissions: " + rtID + ": The cluster service principal does not have permission to perform action 'Microsoft.Network/routeTables/join/action' on resource '" + rtID + "'., " +
+				"InvalidServicePrincipalPermissions: " + rtID + ": The cluster service principal does not have permission to perform action 'Microsoft.Network/routeTables/read' on resource '" + rtID + "'.",
+		},
+		{
+			name:             "workload identities have all permissions",
+			workloadIdentity: true,
+			mocks: func(s *mock_subnet.MockManager, p *mock_authorization.MockPermissionsClient, ra *mock_authorization.MockRoleAssignmentsClient, rd *mock_authorization.MockRoleDefinitionsClient) {
+				mockSubnets(s)
+				mockRoleDefinitions(rd)
+				// the cloud controller manager only needs access to the vnet
+				ra.EXPECT().ListForResource(ctx, "vnetResourceGroup", "Microsoft.Network", "", "virtualNetworks", "vnet", "assignedTo('cloudControllerManagerObjectId')").Return(assigned, nil)
+				ra.EXPECT().ListForResource(ctx, gomock.Any(), gomock.Any(), "", gomock.Any(), gomock.Any(), "assignedTo('machineApiOperatorObjectId')").Return(assigned, nil).Times(3)
+			},
+		},
+		{
+			name:             "workload identity without route table actions its operator doesn't need",
+			workloadIdentity: true,
+			identities: []api.PlatformWorkloadIdentity{
+				{OperatorName: "ImageRegistryOperator", ObjectID: "imageRegistryOperatorObjectId"},
+				{OperatorName: "AzureFilesStorageOperator", ObjectID: "azureFilesStorageOperatorObjectId"},
+			},
+			mocks: func(s *mock_subnet.MockManager, p *mock_authorization.MockPermissionsClient, ra *mock_authorization.MockRoleAssignmentsClient, rd *mock_authorization.MockRoleDefinitionsClient) {
+				mockSubnets(s)
+				mockRoleDefinitions(rd)
+				for _, objectID := range []string{"imageRegistryOperatorObjectId", "azureFilesStorageOperatorObjectId"} {
+					ra.EXPECT().ListForResource(ctx, "vnetResourceGroup", "Microsoft.Network", "", "virtualNetworks", "vnet", "assignedTo('"+objectID+"')").Return(vnetAssigned, nil)
+				}
+			},
+		},
+		{
+			name:             "one workload identity is missing permissions",
+			workloadIdentity: true,
+			mocks: func(s *mock_subnet.MockManager, p *mock_authorization.MockPermissionsClient, ra *mock_authorization.MockRoleAssignmentsClient, rd *mock_authorization.MockRoleDefinitionsClient) {
+				mockSubnets(s)
+				mockRoleDefinitions(rd)
+				ra.EXPECT().ListForResource(ctx, "vnetResourceGroup", "Microsoft.Network", "", "virtualNetworks", "vnet", "assignedTo('cloudControllerManagerObjectId')").Return(assigned, nil)
+				ra.EXPECT().ListForResource(ctx, "vnetResourceGroup", "Microsoft.Network", "", "routeTables", "rt", "assignedTo('machineApiOperatorObjectId')").Return(nil, nil)
+				ra.EXPECT().ListForResource(ctx, gomock.Any(), gomock.Any(), "", gomock.Any(), gomock.Any(), "assignedTo('machineApiOperatorObjectId')").Return(assigned, nil).Times(2)
+			},
+			wantErr: "400: InvalidServicePrincipalPermissions: : The platform workload identities do not have the permissions needed to create the cluster. Details: " +
+				"InvalidServicePrincipalPermissions: " + rtID + ": The platform workload identity 'MachineApiOperator' does not have permission to perform action 'Microsoft.Network/routeTables/join/action' on resource '" + rtID + "'., " +
+				"InvalidServicePrincipalPermissions: " + rtID + ": The platform workload identity 'MachineApiOperator' does not have permission to perform action 'Microsoft.Network/routeTables/read' on resource '" + rtID + "'.",
+		},
+		{
+			name:            "disk encryption set read is granted by the template",
+			desRoleAssigned: true,
+			mocks: func(s *mock_subnet.MockManager, p *mock_authorization.MockPermissionsClient, ra *mock_authorization.MockRoleAssignmentsClient, rd *mock_authorization.MockRoleDefinitionsClient) {
+				mockSubnets(s)
+				p.EXPECT().ListForResource(ctx, "vnetResourceGroup", "Microsoft.Network", "", "virtualNetworks", "vnet").Return(all, nil)
+				p.EXPECT().ListForResource(ctx, "vnetResourceGroup", "Microsoft.Network", "", "routeTables", "rt").Return(all, nil)
+			},
+		},
+		{
+			name:            "network access is granted by the template",
+			netRoleAssigned: true,
+			mocks: func(s *mock_subnet.MockManager, p *mock_authorization.MockPermissionsClient, ra *mock_authorization.MockRoleAssignmentsClient, rd *mock_authorization.MockRoleDefinitionsClient) {
+				mockSubnets(s)
+				p.EXPECT().ListForResource(ctx, "desResourceGroup", "Microsoft.Compute", "", "diskEncryptionSets", "des").Return(all, nil)
+			},
+		},
+		{
+			name: "getting subnet fails",
+			mocks: func(s *mock_subnet.MockManager, p *mock_authorization.MockPermissionsClient, ra *mock_authorization.MockRoleAssignmentsClient, rd *mock_authorization.MockRoleDefinitionsClient) {
+				s.EXPECT().Get(ctx, masterSubnetID).Return(nil, errors.New("oh no"))
+			},
+			wantErr: "oh no",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			s := mock_subnet.NewMockManager(controller)
+			p := mock_authorization.NewMockPermissionsClient(controller)
+			ra := mock_authorization.NewMockRoleAssignmentsClient(controller)
+			rd := mock_authorization.NewMockRoleDefinitionsClient(controller)
+			tt.mocks(s, p, ra, rd)
+
+			_env := mock_env.NewMockInterface(controller)
+			_env.EXPECT().FeatureIsSet(env.FeatureEnableDiskEncryptionSetRoleAssignment).Return(tt.desRoleAssigned).AnyTimes()
+			_env.EXPECT().FeatureIsSet(env.FeatureEnableNetworkRoleAssignment).Return(tt.netRoleAssigned).AnyTimes()
+
+			oc := &api.OpenShiftCluster{
+				ID: "/subscriptions/subscriptionId/resourceGroups/resourceGroup/providers/Microsoft.RedHatOpenShift/openShiftClusters/cluster",
+				Properties: api.OpenShiftClusterProperties{
+					MasterProfile: api.MasterProfile{
+						SubnetID:            masterSubnetID,
+						DiskEncryptionSetID: desID,
+					},
+					WorkerProfiles: []api.WorkerProfile{
+						{
+							SubnetID:            workerSubnetID,
+							DiskEncryptionSetID: desID,
+						},
+					},
+				},
+			}
+			if tt.workloadIdentity {
+				identities := tt.identities
+				if identities == nil {
+					identities = []api.PlatformWorkloadIdentity{
+						{OperatorName: "CloudControllerManager", ObjectID: "cloudControllerManagerObjectId"},
+						{OperatorName: "MachineApiOperator", ObjectID: "machineApiOperatorObjectId"},
+					}
+				}
+				oc.Properties.PlatformWorkloadIdentityProfile = &api.PlatformWorkloadIdentityProfile{
+					PlatformWorkloadIdentities: identities,
+				}
+			} else {
+				oc.Properties.ServicePrincipalProfile = &api.ServicePrincipalProfile{}
+			}
+
+			m := &manager{
+				log:             logrus.NewEntry(logrus.StandardLogger()),
+				env:             _env,
+				oc:              oc,
+				subnet:          s,
+				spPermissions:   p,
+				roleAssignments: ra,
+				roleDefinitions: rd,
+			}
+
+			// a zero timeout makes a single attempt
+			err := m.pollPermissions(ctx, time.Millisecond, 0)
+			if err != nil && err.Error() != tt.wantErr ||
+				err == nil && tt.wantErr != "" {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest/to"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/azureerrors"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/computeskus"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/stringutils"
+)
+
+// bootstrapAndMasterCount is the number of VMs deployed from the resources
+// template: the control plane replicas of the install config, plus the
+// bootstrap VM
+const bootstrapAndMasterCount = 1 + masterCount
+
+// addRequiredComputeResources adds the compute usages consumed by count VMs of
+// the given size to requiredResources
+func (m *manager) addRequiredComputeResources(requiredResources map[string]int64, vmSize api.VMSize, count int) error {
+	sku, err := m.vmSku(vmSize)
+	if err != nil {
+		return err
+	}
+
+	vCPUs, err := strconv.ParseInt(computeskus.GetCapability(sku, "vCPUs"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid vCPUs capability for vm size %q: %w", vmSize, err)
+	}
+
+	if sku.Family == nil {
+		return fmt.Errorf("family not found for vm size %q", vmSize)
+	}
+
+	requiredResources["virtualMachines"] += int64(count)
+	requiredResources["PremiumDiskCount"] += int64(count)
+	requiredResources[*sku.Family] += vCPUs * int64(count)
+	requiredResources["cores"] += vCPUs * int64(count)
+
+	return nil
+}
+
+// requiredResources returns the compute and network usages the cluster needs,
+// keyed by usage name
+func (m *manager) requiredResources() (compute map[string]int64, network map[string]int64, err error) {
+	compute = map[string]int64{}
+
+	// the bootstrap VM uses the master VM size
+	err = m.addRequiredComputeResources(compute, m.oc.Properties.MasterProfile.VMSize, bootstrapAndMasterCount)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	vms := bootstrapAndMasterCount
+	for _, w := range m.oc.Properties.WorkerProfiles {
+		err = m.addRequiredComputeResources(compute, w.VMSize, w.Count)
+		if err != nil {
+			return nil, nil, err
+		}
+		vms += w.Count
+	}
+
+	network = map[string]int64{
+		// one NIC per VM
+		"NetworkInterfaces": int64(vms),
+	}
+
+	// the public IPs of the public load balancer, see infrastructureResources
+	if publicIPAddresses := len(m.publicIPAddressNames()); publicIPAddresses > 0 {
+		network["PublicIPAddresses"] = int64(publicIPAddresses)
+	}
+
+	return compute, network, nil
+}
+
+// existingResources returns the usages consumed by the resources already in
+// the cluster resource group, e.g. the public IPs created by the RP, or the
+// VMs of an install being retried.  They are part of the current usage of the
+// subscription, so must not be requested again.
+func (m *manager) existingResources(ctx context.Context) (compute map[string]int64, network map[string]int64, err error) {
+	resourceGroup := stringutils.LastTokenByte(m.oc.Properties.ClusterProfile.ResourceGroupID, '/')
+
+	compute = map[string]int64{}
+	network = map[string]int64{}
+
+	resources, err := m.resources.ListByResourceGroup(ctx, resourceGroup, "", "", nil)
+	if azureerrors.ResourceGroupNotFound(err) {
+		return compute, network, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, r := range resources {
+		switch strings.ToLower(to.String(r.Type)) {
+		case "microsoft.network/networkinterfaces":
+			network["NetworkInterfaces"]++
+		case "microsoft.network/publicipaddresses":
+			network["PublicIPAddresses"]++
+		}
+	}
+
+	vms, err := m.virtualMachines.List(ctx, resourceGroup)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, vm := range vms {
+		if vm.VirtualMachineProperties == nil || vm.HardwareProfile == nil {
+			continue
+		}
+
+		// only discount the usage of VMs we know the size of: a hand-created
+		// VM or a retired size must not fail the install
+		err = m.addRequiredComputeResources(compute, api.VMSize(vm.HardwareProfile.VMSize), 1)
+		if err != nil {
+			m.log.Warnf("not discounting vm %s from the required quota: %v", to.String(vm.Name), err)
+		}
+	}
+
+	return compute, network, nil
+}
+
+// validateQuota checks the compute and network usages of the subscription in
+// the cluster location against the resources the cluster still needs, so that
+// quota exhaustion is reported before anything is deployed
+func (m *manager) validateQuota(ctx context.Context) error {
+	m.log.Print("validating quota")
+
+	requiredCompute, requiredNetwork, err := m.requiredResources()
+	if err != nil {
+		return err
+	}
+
+	existingCompute, existingNetwork, err := m.existingResources(ctx)
+	if err != nil {
+		return err
+	}
+
+	for name, count := range existingCompute {
+		requiredCompute[name] -= count
+	}
+	for name, count := range existingNetwork {
+		requiredNetwork[name] -= count
+	}
+
+	var shortfalls []api.CloudErrorBody
+
+	computeUsages, err := m.computeUsage.List(ctx, m.oc.Location)
+	if err != nil {
+		return err
+	}
+
+	for _, usage := range computeUsages {
+		if usage.Name == nil || usage.Name.Value == nil || usage.Limit == nil || usage.CurrentValue == nil {
+			continue
+		}
+
+		shortfalls = appendShortfall(shortfalls, *usage.Name.Value, *usage.Limit, int64(*usage.CurrentValue), requiredCompute)
+	}
+
+	networkUsages, err := m.networkUsage.List(ctx, m.oc.Location)
+	if err != nil {
+		return err
+	}
+
+	for _, usage := range networkUsages {
+		if usage.Name == nil || usage.Name.Value == nil || usage.Limit == nil || usage.CurrentValue == nil {
+			continue
+		}
+
+		shortfalls = appendShortfall(shortfalls, *usage.Name.Value, *usage.Limit, *usage.CurrentValue, requiredNetwork)
+	}
+
+	if len(shortfalls) == 0 {
+		return nil
+	}
+
+	sort.Slice(shortfalls, func(i, j int) bool { return shortfalls[i].Target < shortfalls[j].Target })
+
+	cloudErr := api.NewCloudError(http.StatusBadRequest, api.CloudErrorCodeResourceQuotaExceeded, "", "Resource quota of the subscription in location %s is insufficient to create the cluster.", m.oc.Location)
+	cloudErr.Details = shortfalls
+
+	return cloudErr
+}
+
+func appendShortfall(shortfalls []api.CloudErrorBody, name string, limit, current int64, requiredResources map[string]int64) []api.CloudErrorBody {
+	required, found := requiredResources[name]
+	if !found || required <= 0 || required <= limit-current {
+		return shortfalls
+	}
+
+	return append(shortfalls, api.CloudErrorBody{
+		Code:    api.CloudErrorCodeResourceQuotaExceeded,
+		Target:  name,
+		Message: fmt.Sprintf("Resource quota of %s exceeded. Maximum allowed: %d, Current in use: %d, Additional requested: %d.", name, limit, current, required),
+	})
+}
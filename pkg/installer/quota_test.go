@@ -0,0 +1,273 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	mgmtcompute "github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2020-06-01/compute"
+	mgmtnetwork "github.com/Azure/azure-sdk-for-go/services/network/mgmt/2020-08-01/network"
+	mgmtfeatures "github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-07-01/features"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+	mock_compute "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/azureclient/mgmt/compute"
+	mock_features "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/azureclient/mgmt/features"
+	mock_network "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/azureclient/mgmt/network"
+	mock_env "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/env"
+)
+
+func TestValidateQuota(t *testing.T) {
+	ctx := context.Background()
+
+	computeUsage := func(name string, current int32, limit int64) mgmtcompute.Usage {
+		return mgmtcompute.Usage{
+			Name:         &mgmtcompute.UsageName{Value: to.StringPtr(name)},
+			CurrentValue: to.Int32Ptr(current),
+			Limit:        to.Int64Ptr(limit),
+		}
+	}
+
+	networkUsage := func(name string, current int64, limit int64) mgmtnetwork.Usage {
+		return mgmtnetwork.Usage{
+			Name:         &mgmtnetwork.UsageName{Value: to.StringPtr(name)},
+			CurrentValue: to.Int64Ptr(current),
+			Limit:        to.Int64Ptr(limit),
+		}
+	}
+
+	sku := func(family, vCPUs string) *mgmtcompute.ResourceSku {
+		return &mgmtcompute.ResourceSku{
+			Family: to.StringPtr(family),
+			Capabilities: &[]mgmtcompute.ResourceSkuCapabilities{
+				{Name: to.StringPtr("vCPUs"), Value: to.StringPtr(vCPUs)},
+			},
+		}
+	}
+
+	vm := func(name string, vmSize mgmtcompute.VirtualMachineSizeTypes) mgmtcompute.VirtualMachine {
+		return mgmtcompute.VirtualMachine{
+			Name: to.StringPtr(name),
+			VirtualMachineProperties: &mgmtcompute.VirtualMachineProperties{
+				HardwareProfile: &mgmtcompute.HardwareProfile{
+					VMSize: vmSize,
+				},
+			},
+		}
+	}
+
+	resource := func(resourceType, name string) mgmtfeatures.GenericResourceExpanded {
+		return mgmtfeatures.GenericResourceExpanded{
+			Type: to.StringPtr(resourceType),
+			Name: to.StringPtr(name),
+		}
+	}
+
+	// nothing is deployed in the cluster resource group yet
+	newCluster := func(resources *mock_features.MockResourcesClient, vms *mock_compute.MockVirtualMachinesClient) {
+		resources.EXPECT().ListByResourceGroup(ctx, "clusterResourceGroup", "", "", nil).Return(nil, nil)
+		vms.EXPECT().List(ctx, "clusterResourceGroup").Return(nil, nil)
+	}
+	for _, tt := range []struct {
+		name         string
+		visibility   api.Visibility
+		outboundType api.OutboundType
+		existing     func(*mock_features.MockResourcesClient, *mock_compute.MockVirtualMachinesClient)
+		mocks        func(*mock_compute.MockUsageClient, *mock_network.MockUsageClient)
+		wantErr      string
+	}{
+		{
+			name:       "sufficient quota",
+			visibility: api.VisibilityPublic,
+			existing:   newCluster,
+			mocks: func(cu *mock_compute.MockUsageClient, nu *mock_network.MockUsageClient) {
+				cu.EXPECT().List(ctx, "eastus").Return([]mgmtcompute.Usage{
+					// 4 x 8 master vCPUs + 3 x 4 worker vCPUs
+					computeUsage("cores", 56, 100),
+					computeUsage("standardDSv3Family", 56, 100),
+					computeUsage("virtualMachines", 0, 7),
+					computeUsage("PremiumDiskCount", 0, 7),
+					computeUsage("unrelated", 100, 100),
+				}, nil)
+				nu.EXPECT().List(ctx, "eastus").Return([]mgmtnetwork.Usage{
+					networkUsage("NetworkInterfaces", 0, 7),
+					networkUsage("PublicIPAddresses", 8, 10),
+				}, nil)
+			},
+		},
+		{
+			name:       "insufficient quota",
+			visibility: api.VisibilityPublic,
+			existing:   newCluster,
+			mocks: func(cu *mock_compute.MockUsageClient, nu *mock_network.MockUsageClient) {
+				cu.EXPECT().List(ctx, "eastus").Return([]mgmtcompute.Usage{
+					computeUsage("cores", 57, 100),
+					computeUsage("standardDSv3Family", 0, 100),
+				}, nil)
+				nu.EXPECT().List(ctx, "eastus").Return([]mgmtnetwork.Usage{
+					networkUsage("NetworkInterfaces", 0, 7),
+					networkUsage("PublicIPAddresses", 10, 10),
+				}, nil)
+			},
+			wantErr: "400: ResourceQuotaExceeded: : Resource quota of the subscription in location eastus is insufficient to create the cluster. Details: ResourceQuotaExceeded: PublicIPAddresses: Resource quota of PublicIPAddresses exceeded. Maximum allowed: 10, Current in use: 10, Additional requested: 1., ResourceQuotaExceeded: cores: Resource quota of cores exceeded. Maximum allowed: 100, Current in use: 57, Additional requested: 44.",
+		},
+		{
+			name:         "no public IPs needed for private user defined routing",
+			visibility:   api.VisibilityPrivate,
+			outboundType: api.OutboundTypeUserDefinedRouting,
+			existing: func(resources *mock_features.MockResourcesClient, vms *mock_compute.MockVirtualMachinesClient) {
+				resources.EXPECT().ListByResourceGroup(ctx, "clusterResourceGroup", "", "", nil).Return(nil, nil)
+				vms.EXPECT().List(ctx, "clusterResourceGroup").Return(nil, nil)
+			},
+			mocks: func(cu *mock_compute.MockUsageClient, nu *mock_network.MockUsageClient) {
+				cu.EXPECT().List(ctx, "eastus").Return(nil, nil)
+				nu.EXPECT().List(ctx, "eastus").Return([]mgmtnetwork.Usage{
+					networkUsage("PublicIPAddresses", 10, 10),
+				}, nil)
+			},
+		},
+		{
+			name:       "retried install",
+			visibility: api.VisibilityPublic,
+			existing: func(resources *mock_features.MockResourcesClient, vms *mock_compute.MockVirtualMachinesClient) {
+				resources.EXPECT().ListByResourceGroup(ctx, "clusterResourceGroup", "", "", nil).Return([]mgmtfeatures.GenericResourceExpanded{
+					resource("Microsoft.Network/publicIPAddresses", "infra-pip-v4"),
+					resource("Microsoft.Network/publicIPAddresses", "infra-default-v4"),
+					resource("Microsoft.Network/networkInterfaces", "infra-bootstrap-nic"),
+					resource("Microsoft.Network/networkInterfaces", "infra-master0-nic"),
+					resource("Microsoft.Network/networkInterfaces", "infra-master1-nic"),
+					resource("Microsoft.Network/networkInterfaces", "infra-master2-nic"),
+				}, nil)
+				vms.EXPECT().List(ctx, "clusterResourceGroup").Return([]mgmtcompute.VirtualMachine{
+					vm("infra-bootstrap", mgmtcompute.VirtualMachineSizeTypesStandardD8sV3),
+					vm("infra-master-0", mgmtcompute.VirtualMachineSizeTypesStandardD8sV3),
+					vm("infra-master-1", mgmtcompute.VirtualMachineSizeTypesStandardD8sV3),
+					vm("infra-master-2", mgmtcompute.VirtualMachineSizeTypesStandardD8sV3),
+				}, nil)
+			},
+			mocks: func(cu *mock_compute.MockUsageClient, nu *mock_network.MockUsageClient) {
+				// the existing VMs already count in the usage: only the 3 x 4
+				// worker vCPUs are still needed
+				cu.EXPECT().List(ctx, "eastus").Return([]mgmtcompute.Usage{
+					computeUsage("cores", 88, 100),
+					computeUsage("standardDSv3Family", 88, 100),
+					computeUsage("virtualMachines", 4, 7),
+				}, nil)
+				nu.EXPECT().List(ctx, "eastus").Return([]mgmtnetwork.Usage{
+					networkUsage("NetworkInterfaces", 4, 7),
+					networkUsage("PublicIPAddresses", 10, 10),
+				}, nil)
+			},
+		},
+		{
+			name:       "existing vm of unknown size",
+			visibility: api.VisibilityPublic,
+			existing: func(resources *mock_features.MockResourcesClient, vms *mock_compute.MockVirtualMachinesClient) {
+				resources.EXPECT().ListByResourceGroup(ctx, "clusterResourceGroup", "", "", nil).Return([]mgmtfeatures.GenericResourceExpanded{
+					resource("Microsoft.Network/networkInterfaces", "handcrafted-nic"),
+				}, nil)
+				vms.EXPECT().List(ctx, "clusterResourceGroup").Return([]mgmtcompute.VirtualMachine{
+					vm("handcrafted", "Standard_Retired"),
+				}, nil)
+			},
+			mocks: func(cu *mock_compute.MockUsageClient, nu *mock_network.MockUsageClient) {
+				// the VM of unknown size is not discounted
+				cu.EXPECT().List(ctx, "eastus").Return([]mgmtcompute.Usage{
+					computeUsage("cores", 57, 100),
+				}, nil)
+				nu.EXPECT().List(ctx, "eastus").Return(nil, nil)
+			},
+			wantErr: "400: ResourceQuotaExceeded: : Resource quota of the subscription in location eastus is insufficient to create the cluster. Details: ResourceQuotaExceeded: cores: Resource quota of cores exceeded. Maximum allowed: 100, Current in use: 57, Additional requested: 44.",
+		},
+		{
+			name:       "resource group not created yet",
+			visibility: api.VisibilityPublic,
+			existing: func(resources *mock_features.MockResourcesClient, vms *mock_compute.MockVirtualMachinesClient) {
+				resources.EXPECT().ListByResourceGroup(ctx, "clusterResourceGroup", "", "", nil).Return(nil, autorest.DetailedError{
+					Original: &azure.ServiceError{
+						Code: "ResourceGroupNotFound",
+					},
+				})
+			},
+			mocks: func(cu *mock_compute.MockUsageClient, nu *mock_network.MockUsageClient) {
+				cu.EXPECT().List(ctx, "eastus").Return(nil, nil)
+				nu.EXPECT().List(ctx, "eastus").Return([]mgmtnetwork.Usage{
+					networkUsage("PublicIPAddresses", 10, 10),
+				}, nil)
+			},
+			wantErr: "400: ResourceQuotaExceeded: : Resource quota of the subscription in location eastus is insufficient to create the cluster. Details: ResourceQuotaExceeded: PublicIPAddresses: Resource quota of PublicIPAddresses exceeded. Maximum allowed: 10, Current in use: 10, Additional requested: 1.",
+		},
+		{
+			name:       "listing usages fails",
+			visibility: api.VisibilityPublic,
+			existing:   newCluster,
+			mocks: func(cu *mock_compute.MockUsageClient, nu *mock_network.MockUsageClient) {
+				cu.EXPECT().List(ctx, "eastus").Return(nil, errors.New("oh no"))
+			},
+			wantErr: "oh no",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			_env := mock_env.NewMockInterface(controller)
+			_env.EXPECT().VMSku("Standard_D8s_v3").Return(sku("standardDSv3Family", "8"), nil).AnyTimes()
+			_env.EXPECT().VMSku("Standard_D4s_v3").Return(sku("standardDSv3Family", "4"), nil).AnyTimes()
+			_env.EXPECT().VMSku("Standard_Retired").Return(nil, errors.New("sku information not found for vm size \"Standard_Retired\"")).AnyTimes()
+
+			resources := mock_features.NewMockResourcesClient(controller)
+			vms := mock_compute.NewMockVirtualMachinesClient(controller)
+			tt.existing(resources, vms)
+
+			cu := mock_compute.NewMockUsageClient(controller)
+			nu := mock_network.NewMockUsageClient(controller)
+			tt.mocks(cu, nu)
+
+			m := &manager{
+				log:             logrus.NewEntry(logrus.StandardLogger()),
+				env:             _env,
+				computeUsage:    cu,
+				networkUsage:    nu,
+				resources:       resources,
+				virtualMachines: vms,
+				oc: &api.OpenShiftCluster{
+					Location: "eastus",
+					Properties: api.OpenShiftClusterProperties{
+						ClusterProfile: api.ClusterProfile{
+							ResourceGroupID: "/subscriptions/subscriptionId/resourceGroups/clusterResourceGroup",
+						},
+						APIServerProfile: api.APIServerProfile{
+							Visibility: tt.visibility,
+						},
+						NetworkProfile: api.NetworkProfile{
+							OutboundType: tt.outboundType,
+						},
+						MasterProfile: api.MasterProfile{
+							VMSize: api.VMSizeStandardD8sV3,
+						},
+						WorkerProfiles: []api.WorkerProfile{
+							{
+								VMSize: api.VMSizeStandardD4sV3,
+								Count:  3,
+							},
+						},
+					},
+				},
+			}
+
+			err := m.validateQuota(ctx)
+			if err != nil && err.Error() != tt.wantErr ||
+				err == nil && tt.wantErr != "" {
+				t.Error(err)
+			}
+		})
+	}
+}
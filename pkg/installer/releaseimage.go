@@ -0,0 +1,63 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/release"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/version"
+)
+
+// verifyReleaseImage checks that the release image is digest-pinned, is one
+// of the supported releases and is of the requested cluster version, so that
+// a typo or an unapproved nightly is rejected before ignition is generated.
+// The supported releases are read from ARO_RELEASE_STREAMS_FILE if set,
+// otherwise version.UpgradeStreams are used.  The version is read from the
+// release image itself.
+//
+// Release signatures are not verified: the image is pinned to the digest of a
+// supported release, and its manifest is checked against that digest when its
+// version is read.
+func (m *manager) verifyReleaseImage(ctx context.Context, pullSpec, pullSecret string) error {
+	m.log.Printf("verifying release image %s", pullSpec)
+
+	streams := version.UpgradeStreams
+	if path := os.Getenv("ARO_RELEASE_STREAMS_FILE"); path != "" {
+		var err error
+		streams, err = version.StreamsFromFile(path)
+		if err != nil {
+			return err
+		}
+	} else {
+		m.log.Print("ARO_RELEASE_STREAMS_FILE is not set, using the built-in release streams")
+	}
+
+	_, err := release.Stream(pullSpec, streams)
+	if err != nil {
+		return api.NewCloudError(http.StatusBadRequest, api.CloudErrorCodeInvalidParameter, "properties.clusterProfile.version", "The release image is not supported: %s.", err)
+	}
+
+	releaseVersion, err := m.releaseImageVersion(ctx, pullSpec, pullSecret)
+	if err != nil {
+		return fmt.Errorf("reading the version of release image %s: %w", pullSpec, err)
+	}
+
+	v, err := version.ParseVersion(m.oc.Properties.ClusterProfile.Version)
+	if err != nil || v.String() != releaseVersion.String() {
+		return api.NewCloudError(http.StatusBadRequest, api.CloudErrorCodeInvalidParameter, "properties.clusterProfile.version", "The requested OpenShift version '%s' does not match the release image version '%s'.", m.oc.Properties.ClusterProfile.Version, releaseVersion)
+	}
+
+	return nil
+}
+
+// readReleaseImageVersion reads the OpenShift version of a release image from
+// its registry
+func readReleaseImageVersion(ctx context.Context, pullSpec, pullSecret string) (*version.Version, error) {
+	return release.Version(ctx, http.DefaultClient, pullSpec, pullSecret)
+}
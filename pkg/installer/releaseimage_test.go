@@ -0,0 +1,117 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/version"
+)
+
+func TestVerifyReleaseImage(t *testing.T) {
+	ctx := context.Background()
+
+	pullSpec := "quay.io/openshift-release-dev/ocp-release@sha256:1111111111111111111111111111111111111111111111111111111111111111"
+
+	streamsFile := filepath.Join(t.TempDir(), "streams.json")
+	err := os.WriteFile(streamsFile, []byte(`[{"version": "4.14.16", "pullSpec": "`+pullSpec+`"}]`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name           string
+		streamsFile    string
+		pullSpec       string
+		version        string
+		releaseVersion *version.Version
+		versionErr     error
+		wantErr        string
+	}{
+		{
+			name:           "valid from the built-in streams",
+			pullSpec:       version.InstallStream.PullSpec,
+			version:        version.InstallStream.Version.String(),
+			releaseVersion: version.InstallStream.Version,
+		},
+		{
+			name:           "valid from the streams file",
+			streamsFile:    streamsFile,
+			pullSpec:       pullSpec,
+			version:        "4.14.16",
+			releaseVersion: version.NewVersion(4, 14, 16),
+		},
+		{
+			name:     "not in the built-in streams",
+			pullSpec: pullSpec,
+			version:  "4.14.16",
+			wantErr:  `400: InvalidParameter: properties.clusterProfile.version: The release image is not supported: release image "` + pullSpec + `" is not in the list of supported releases.`,
+		},
+		{
+			name:        "unsupported release image",
+			streamsFile: streamsFile,
+			pullSpec:    "quay.io/openshift-release-dev/ocp-release-nightly@sha256:1111111111111111111111111111111111111111111111111111111111111111",
+			version:     "4.14.16",
+			wantErr:     `400: InvalidParameter: properties.clusterProfile.version: The release image is not supported: release image "quay.io/openshift-release-dev/ocp-release-nightly@sha256:1111111111111111111111111111111111111111111111111111111111111111" is not in the list of supported releases.`,
+		},
+		{
+			name:           "version mismatch",
+			streamsFile:    streamsFile,
+			pullSpec:       pullSpec,
+			version:        "4.14.15",
+			releaseVersion: version.NewVersion(4, 14, 16),
+			wantErr:        "400: InvalidParameter: properties.clusterProfile.version: The requested OpenShift version '4.14.15' does not match the release image version '4.14.16'.",
+		},
+		{
+			name:           "the version of the release image wins over the streams file",
+			streamsFile:    streamsFile,
+			pullSpec:       pullSpec,
+			version:        "4.14.16",
+			releaseVersion: version.NewVersion(4, 14, 17),
+			wantErr:        "400: InvalidParameter: properties.clusterProfile.version: The requested OpenShift version '4.14.16' does not match the release image version '4.14.17'.",
+		},
+		{
+			name:        "reading the release image version fails",
+			streamsFile: streamsFile,
+			pullSpec:    pullSpec,
+			version:     "4.14.16",
+			versionErr:  errors.New("oh no"),
+			wantErr:     "reading the version of release image " + pullSpec + ": oh no",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ARO_RELEASE_STREAMS_FILE", tt.streamsFile)
+
+			m := &manager{
+				log: logrus.NewEntry(logrus.StandardLogger()),
+				releaseImageVersion: func(ctx context.Context, pullSpec, pullSecret string) (*version.Version, error) {
+					if pullSecret != "pullSecret" {
+						t.Errorf("unexpected pull secret %q", pullSecret)
+					}
+					return tt.releaseVersion, tt.versionErr
+				},
+				oc: &api.OpenShiftCluster{
+					Properties: api.OpenShiftClusterProperties{
+						ClusterProfile: api.ClusterProfile{
+							Version: tt.version,
+						},
+					},
+				},
+			}
+
+			err := m.verifyReleaseImage(ctx, tt.pullSpec, "pullSecret")
+			if err != nil && err.Error() != tt.wantErr ||
+				err == nil && tt.wantErr != "" {
+				t.Error(err)
+			}
+		})
+	}
+}
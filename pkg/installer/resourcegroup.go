@@ -0,0 +1,91 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest/to"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+	"github.com/openshift/installer-aro-wrapper/pkg/env"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/stringutils"
+)
+
+var (
+	// rxInfraResourceName matches the names of the resources the RP and the
+	// installer create in the cluster resource group, capturing the InfraID
+	rxInfraResourceName = regexp.MustCompile(`(?i)^(.+-[a-z0-9]{5})-(bootstrap|bootstrap-nic|bootstrap_OSDisk|master-[0-9]+|master-[0-9]+_OSDisk|master[0-9]+-nic|worker-.+|internal|nsg|pls|pe|pip-v4|default-v4)$`)
+
+	// rxInfraLoadBalancerName matches the name of the public load balancer,
+	// which is the InfraID itself
+	rxInfraLoadBalancerName = regexp.MustCompile(`(?i)^.+-[a-z0-9]{5}$`)
+)
+
+// infraID returns the InfraID a resource in the cluster resource group was
+// created for, if its name looks like that of a cluster resource
+func infraID(resourceType, name string) (string, bool) {
+	if m := rxInfraResourceName.FindStringSubmatch(name); m != nil {
+		return m[1], true
+	}
+
+	if strings.EqualFold(resourceType, "Microsoft.Network/loadBalancers") && rxInfraLoadBalancerName.MatchString(name) {
+		return name, true
+	}
+
+	return "", false
+}
+
+// validateResourceGroup checks that the cluster resource group doesn't hold
+// cluster resources left over from a cluster with a different InfraID.  The
+// resources template is deployed in Incremental mode, so such resources would
+// otherwise be silently mixed into the new cluster.  Setting
+// FeatureAdoptConflictingResources allows the install to continue anyway.
+func (m *manager) validateResourceGroup(ctx context.Context) error {
+	m.log.Print("validating cluster resource group")
+
+	resourceGroup := stringutils.LastTokenByte(m.oc.Properties.ClusterProfile.ResourceGroupID, '/')
+
+	resources, err := m.resources.ListByResourceGroup(ctx, resourceGroup, "", "", nil)
+	if err != nil {
+		return err
+	}
+
+	var conflicting []string
+	for _, resource := range resources {
+		id, ok := infraID(to.String(resource.Type), to.String(resource.Name))
+		if !ok || strings.EqualFold(id, m.oc.Properties.InfraID) {
+			continue
+		}
+
+		conflicting = append(conflicting, to.String(resource.ID))
+	}
+
+	if len(conflicting) == 0 {
+		return nil
+	}
+
+	sort.Strings(conflicting)
+
+	if m.env.FeatureIsSet(env.FeatureAdoptConflictingResources) {
+		m.log.Warnf("adopting %d conflicting resources in resource group %s: %s", len(conflicting), resourceGroup, strings.Join(conflicting, ", "))
+		return nil
+	}
+
+	cloudErr := api.NewCloudError(http.StatusBadRequest, api.CloudErrorCodeConflictingClusterResources, "", "The cluster resource group '%s' contains resources belonging to a different cluster.", resourceGroup)
+	for _, id := range conflicting {
+		cloudErr.Details = append(cloudErr.Details, api.CloudErrorBody{
+			Code:    api.CloudErrorCodeConflictingClusterResources,
+			Target:  id,
+			Message: fmt.Sprintf("The resource '%s' does not belong to cluster infrastructure '%s'.", id, m.oc.Properties.InfraID),
+		})
+	}
+
+	return cloudErr
+}
@@ -0,0 +1,103 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"testing"
+
+	mgmtfeatures "github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-07-01/features"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+	"github.com/openshift/installer-aro-wrapper/pkg/env"
+	mock_features "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/azureclient/mgmt/features"
+	mock_env "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/env"
+)
+
+func TestValidateResourceGroup(t *testing.T) {
+	ctx := context.Background()
+
+	rgID := "/subscriptions/subscriptionId/resourceGroups/clusterResourceGroup"
+
+	resource := func(resourceType, name string) mgmtfeatures.GenericResourceExpanded {
+		return mgmtfeatures.GenericResourceExpanded{
+			ID:   to.StringPtr(rgID + "/providers/" + resourceType + "/" + name),
+			Name: to.StringPtr(name),
+			Type: to.StringPtr(resourceType),
+		}
+	}
+
+	for _, tt := range []struct {
+		name      string
+		resources []mgmtfeatures.GenericResourceExpanded
+		adopt     bool
+		wantErr   string
+	}{
+		{
+			name: "no conflicts",
+			resources: []mgmtfeatures.GenericResourceExpanded{
+				resource("Microsoft.Storage/storageAccounts", "clusterabcdef"),
+				resource("Microsoft.Network/loadBalancers", "cluster-abcde"),
+				resource("Microsoft.Network/loadBalancers", "cluster-abcde-internal"),
+				resource("Microsoft.Network/networkSecurityGroups", "cluster-abcde-nsg"),
+				resource("Microsoft.Compute/virtualMachines", "cluster-abcde-master-0"),
+				resource("Microsoft.Compute/virtualMachines", "my-vm"),
+			},
+		},
+		{
+			name: "conflicts",
+			resources: []mgmtfeatures.GenericResourceExpanded{
+				resource("Microsoft.Network/loadBalancers", "cluster-abcde"),
+				resource("Microsoft.Network/loadBalancers", "cluster-vwxyz"),
+				resource("Microsoft.Compute/virtualMachines", "cluster-vwxyz-master-0"),
+				resource("Microsoft.Compute/disks", "cluster-vwxyz-bootstrap_OSDisk"),
+			},
+			wantErr: "400: ConflictingClusterResources: : The cluster resource group 'clusterResourceGroup' contains resources belonging to a different cluster. Details: " +
+				"ConflictingClusterResources: " + rgID + "/providers/Microsoft.Compute/disks/cluster-vwxyz-bootstrap_OSDisk: The resource '" + rgID + "/providers/Microsoft.Compute/disks/cluster-vwxyz-bootstrap_OSDisk' does not belong to cluster infrastructure 'cluster-abcde'., " +
+				"ConflictingClusterResources: " + rgID + "/providers/Microsoft.Compute/virtualMachines/cluster-vwxyz-master-0: The resource '" + rgID + "/providers/Microsoft.Compute/virtualMachines/cluster-vwxyz-master-0' does not belong to cluster infrastructure 'cluster-abcde'., " +
+				"ConflictingClusterResources: " + rgID + "/providers/Microsoft.Network/loadBalancers/cluster-vwxyz: The resource '" + rgID + "/providers/Microsoft.Network/loadBalancers/cluster-vwxyz' does not belong to cluster infrastructure 'cluster-abcde'.",
+		},
+		{
+			name: "conflicts adopted",
+			resources: []mgmtfeatures.GenericResourceExpanded{
+				resource("Microsoft.Compute/virtualMachines", "cluster-vwxyz-master-0"),
+			},
+			adopt: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			resources := mock_features.NewMockResourcesClient(controller)
+			resources.EXPECT().ListByResourceGroup(ctx, "clusterResourceGroup", "", "", nil).Return(tt.resources, nil)
+
+			_env := mock_env.NewMockInterface(controller)
+			_env.EXPECT().FeatureIsSet(env.FeatureAdoptConflictingResources).Return(tt.adopt).AnyTimes()
+
+			m := &manager{
+				log:       logrus.NewEntry(logrus.StandardLogger()),
+				env:       _env,
+				resources: resources,
+				oc: &api.OpenShiftCluster{
+					Properties: api.OpenShiftClusterProperties{
+						InfraID: "cluster-abcde",
+						ClusterProfile: api.ClusterProfile{
+							ResourceGroupID: rgID,
+						},
+					},
+				},
+			}
+
+			err := m.validateResourceGroup(ctx)
+			if err != nil && err.Error() != tt.wantErr ||
+				err == nil && tt.wantErr != "" {
+				t.Error(err)
+			}
+		})
+	}
+}
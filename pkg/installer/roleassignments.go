@@ -0,0 +1,210 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest/azure"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/env"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/arm"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/arm/expression"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/azureclient"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/rbac"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/subnet"
+)
+
+// roleAssignmentStageName is the name of the stage granting the cluster
+// identities their roles on the linked customer resources
+const roleAssignmentStageName = "resources-roleassignments"
+
+// nestedDeployment is a Microsoft.Resources/deployments resource deploying
+// its template into another resource group, possibly of another subscription
+type nestedDeployment struct {
+	SubscriptionID string                 `json:"subscriptionId,omitempty"`
+	ResourceGroup  string                 `json:"resourceGroup,omitempty"`
+	Properties     map[string]interface{} `json:"properties,omitempty"`
+}
+
+// principalIDsNeeding returns the object IDs of the cluster identities which
+// need any of actions on a customer resource: the service principal, or the
+// platform workload identities whose operators need them.
+func (m *manager) principalIDsNeeding(actions []string) []string {
+	if !m.oc.UsesWorkloadIdentity() {
+		return m.clusterPrincipalIDs()
+	}
+
+	rp := &requiredPermission{actions: actions}
+
+	var principalIDs []string
+	for _, identity := range m.oc.Properties.PlatformWorkloadIdentityProfile.PlatformWorkloadIdentities {
+		if len(rp.actionsFor(identity.OperatorName)) > 0 {
+			principalIDs = append(principalIDs, identity.ObjectID)
+		}
+	}
+
+	return principalIDs
+}
+
+// roleAssignmentDeployment returns a resource granting the cluster identities
+// which need any of actions roleID on resourceID, or nil if there are none.
+// The linked
+// resources normally live outside the cluster resource group, so the role
+// assignments are made by a deployment nested in the resource's resource
+// group, whose name is prefix followed by a hash of the resource ID.  The role
+// assignment names are guids of the resource, principal and role, so
+// redeploying the template doesn't duplicate them.
+func (m *manager) roleAssignmentDeployment(prefix, resourceID, roleID string, actions []string) (*arm.Resource, error) {
+	r, err := azure.ParseResourceID(resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	var roleAssignments []*arm.Resource
+	for _, principalID := range m.principalIDsNeeding(actions) {
+		ra := rbac.ResourceRoleAssignment(roleID, "'"+principalID+"'", r.Provider+"/"+r.ResourceType, "'"+r.ResourceName+"'")
+		// the resource is not part of the nested template
+		ra.DependsOn = nil
+		roleAssignments = append(roleAssignments, ra)
+	}
+
+	if len(roleAssignments) == 0 {
+		return nil, nil
+	}
+
+	template, err := templateMap(&arm.Template{
+		Schema:         "https://schema.management.azure.com/schemas/2015-01-01/deploymentTemplate.json#",
+		ContentVersion: "1.0.0.0",
+		Resources:      roleAssignments,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &arm.Resource{
+		Resource: nestedDeployment{
+			SubscriptionID: r.SubscriptionID,
+			ResourceGroup:  r.ResourceGroup,
+			Properties: map[string]interface{}{
+				// resourceId() in the nested template must resolve in the
+				// resource group it is deployed to, not the cluster's
+				"expressionEvaluationOptions": map[string]interface{}{
+					"scope": "inner",
+				},
+				"mode":     "Incremental",
+				"template": template,
+			},
+		},
+		Name:       expression.Format(expression.Concat(expression.Literal(prefix), expression.UniqueString(expression.ResourceGroupID(), expression.Literal(strings.ToLower(resourceID))))),
+		Type:       "Microsoft.Resources/deployments",
+		APIVersion: azureclient.APIVersion("Microsoft.Resources"),
+	}, nil
+}
+
+// networkRoleAssignments returns resources granting the cluster identities
+// which need them Network Contributor on the VNet and on the subnets' route
+// tables, if
+// FeatureEnableNetworkRoleAssignment is set.
+func (m *manager) networkRoleAssignments(ctx context.Context) ([]*arm.Resource, error) {
+	if !m.env.FeatureIsSet(env.FeatureEnableNetworkRoleAssignment) {
+		return nil, nil
+	}
+
+	vnetID, _, err := subnet.Split(m.oc.Properties.MasterProfile.SubnetID)
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []*arm.Resource
+	add := func(prefix, resourceID string, actions []string) error {
+		r, err := m.roleAssignmentDeployment(prefix, resourceID, rbac.RoleNetworkContributor, actions)
+		if err != nil {
+			return err
+		}
+
+		if r != nil {
+			resources = append(resources, r)
+		}
+		return nil
+	}
+
+	err = add("virtualnetwork-", vnetID, virtualNetworkActions)
+	if err != nil {
+		return nil, err
+	}
+
+	subnetIDs := []string{m.oc.Properties.MasterProfile.SubnetID}
+	for _, wp := range m.oc.Properties.WorkerProfiles {
+		subnetIDs = append(subnetIDs, wp.SubnetID)
+	}
+
+	seen := map[string]bool{}
+	for _, subnetID := range subnetIDs {
+		if seen[strings.ToLower(subnetID)] {
+			continue
+		}
+		seen[strings.ToLower(subnetID)] = true
+
+		s, err := m.subnet.Get(ctx, subnetID)
+		if err != nil {
+			return nil, err
+		}
+
+		if s.SubnetPropertiesFormat == nil || s.RouteTable == nil || s.RouteTable.ID == nil ||
+			seen[strings.ToLower(*s.RouteTable.ID)] {
+			continue
+		}
+		seen[strings.ToLower(*s.RouteTable.ID)] = true
+
+		err = add("routetable-", *s.RouteTable.ID, routeTableActions)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return resources, nil
+}
+
+// roleAssignmentStage returns the stage granting the cluster identities their
+// roles on the linked customer resources, or nil if there is nothing to grant.
+// It doesn't depend on any cluster resource, so it is deployed alongside the
+// network stage.
+func (m *manager) roleAssignmentStage(ctx context.Context) (*resourceStage, error) {
+	networkRoleAssignments, err := m.networkRoleAssignments(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	diskEncryptionSetRoleAssignments, err := m.diskEncryptionSetRoleAssignments()
+	if err != nil {
+		return nil, err
+	}
+
+	resources := append(networkRoleAssignments, diskEncryptionSetRoleAssignments...)
+	if len(resources) == 0 {
+		return nil, nil
+	}
+
+	return newResourceStage(roleAssignmentStageName, resources...), nil
+}
+
+// templateMap returns t as a generic map, so that it can be nested in another
+// template
+func templateMap(t *arm.Template) (map[string]interface{}, error) {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]interface{}
+	err = json.Unmarshal(b, &m)
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
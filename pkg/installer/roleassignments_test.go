@@ -0,0 +1,194 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	mgmtnetwork "github.com/Azure/azure-sdk-for-go/services/network/mgmt/2020-08-01/network"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/golang/mock/gomock"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+	"github.com/openshift/installer-aro-wrapper/pkg/env"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/arm/expression"
+	mock_env "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/env"
+	mock_subnet "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/subnet"
+)
+
+func TestRoleAssignmentStage(t *testing.T) {
+	ctx := context.Background()
+
+	vnetID := "/subscriptions/subscriptionId/resourceGroups/vnetResourceGroup/providers/Microsoft.Network/virtualNetworks/vnet"
+	masterSubnetID := vnetID + "/subnets/master"
+	workerSubnetID := vnetID + "/subnets/worker"
+	rtID := "/subscriptions/rtSubscriptionId/resourceGroups/rtResourceGroup/providers/Microsoft.Network/routeTables/rt"
+	desID := "/subscriptions/subscriptionId/resourceGroups/desResourceGroup/providers/Microsoft.Compute/diskEncryptionSets/des"
+
+	mockSubnets := func(s *mock_subnet.MockManager) {
+		s.EXPECT().Get(ctx, masterSubnetID).Return(&mgmtnetwork.Subnet{
+			SubnetPropertiesFormat: &mgmtnetwork.SubnetPropertiesFormat{
+				RouteTable: &mgmtnetwork.RouteTable{ID: to.StringPtr(rtID)},
+			},
+		}, nil)
+		s.EXPECT().Get(ctx, workerSubnetID).Return(&mgmtnetwork.Subnet{
+			SubnetPropertiesFormat: &mgmtnetwork.SubnetPropertiesFormat{
+				RouteTable: &mgmtnetwork.RouteTable{ID: to.StringPtr(rtID)},
+			},
+		}, nil)
+	}
+
+	type nested struct {
+		subscriptionID  string
+		resourceGroup   string
+		roleAssignments int
+		scope           string
+	}
+
+	for _, tt := range []struct {
+		name             string
+		networkAssigned  bool
+		desAssigned      bool
+		workloadIdentity bool
+		mocks            func(*mock_subnet.MockManager)
+		want             []nested
+		wantErr          string
+	}{
+		{
+			name: "nothing to grant",
+		},
+		{
+			name:            "network roles",
+			networkAssigned: true,
+			mocks:           mockSubnets,
+			want: []nested{
+				{subscriptionID: "subscriptionId", resourceGroup: "vnetResourceGroup", roleAssignments: 1, scope: vnetID},
+				{subscriptionID: "rtSubscriptionId", resourceGroup: "rtResourceGroup", roleAssignments: 1, scope: rtID},
+			},
+		},
+		{
+			name:             "network and disk encryption set roles for workload identities",
+			networkAssigned:  true,
+			desAssigned:      true,
+			workloadIdentity: true,
+			mocks:            mockSubnets,
+			want: []nested{
+				// every operator needs the VNet, but only MachineApiOperator
+				// the route table and MachineApiOperator and StorageOperator
+				// the disk encryption set
+				{subscriptionID: "subscriptionId", resourceGroup: "vnetResourceGroup", roleAssignments: 4, scope: vnetID},
+				{subscriptionID: "rtSubscriptionId", resourceGroup: "rtResourceGroup", roleAssignments: 1, scope: rtID},
+				{subscriptionID: "subscriptionId", resourceGroup: "desResourceGroup", roleAssignments: 2, scope: desID},
+			},
+		},
+		{
+			name:            "getting subnet fails",
+			networkAssigned: true,
+			mocks: func(s *mock_subnet.MockManager) {
+				s.EXPECT().Get(ctx, masterSubnetID).Return(nil, errors.New("oh no"))
+			},
+			wantErr: "oh no",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			s := mock_subnet.NewMockManager(controller)
+			if tt.mocks != nil {
+				tt.mocks(s)
+			}
+
+			_env := mock_env.NewMockInterface(controller)
+			_env.EXPECT().FeatureIsSet(env.FeatureEnableNetworkRoleAssignment).Return(tt.networkAssigned).AnyTimes()
+			_env.EXPECT().FeatureIsSet(env.FeatureEnableDiskEncryptionSetRoleAssignment).Return(tt.desAssigned).AnyTimes()
+
+			oc := &api.OpenShiftCluster{
+				Properties: api.OpenShiftClusterProperties{
+					MasterProfile: api.MasterProfile{
+						SubnetID:            masterSubnetID,
+						DiskEncryptionSetID: desID,
+					},
+					WorkerProfiles: []api.WorkerProfile{
+						{
+							SubnetID:            workerSubnetID,
+							DiskEncryptionSetID: desID,
+						},
+					},
+				},
+			}
+			if tt.workloadIdentity {
+				oc.Properties.PlatformWorkloadIdentityProfile = &api.PlatformWorkloadIdentityProfile{
+					PlatformWorkloadIdentities: []api.PlatformWorkloadIdentity{
+						{OperatorName: "CloudControllerManager", ObjectID: "cloudControllerManagerObjectId"},
+						{OperatorName: "ImageRegistryOperator", ObjectID: "imageRegistryOperatorObjectId"},
+						{OperatorName: "MachineApiOperator", ObjectID: "machineApiOperatorObjectId"},
+						{OperatorName: "StorageOperator", ObjectID: "storageOperatorObjectId"},
+					},
+				}
+			} else {
+				oc.Properties.ServicePrincipalProfile = &api.ServicePrincipalProfile{
+					SPObjectID: "spObjectId",
+				}
+			}
+
+			m := &manager{
+				env:    _env,
+				oc:     oc,
+				subnet: s,
+			}
+
+			stage, err := m.roleAssignmentStage(ctx)
+			if err != nil && err.Error() != tt.wantErr ||
+				err == nil && tt.wantErr != "" {
+				t.Fatal(err)
+			}
+
+			if tt.want == nil {
+				if stage != nil {
+					t.Errorf("got stage %s", stage.name)
+				}
+				return
+			}
+
+			if stage.name != "resources-roleassignments" {
+				t.Error(stage.name)
+			}
+
+			var got []nested
+			for _, r := range stage.template.Resources {
+				nd := r.Resource.(nestedDeployment)
+				resources := nd.Properties["template"].(map[string]interface{})["resources"].([]interface{})
+
+				if !reflect.DeepEqual(nd.Properties["expressionEvaluationOptions"], map[string]interface{}{"scope": "inner"}) {
+					t.Errorf("%s: got expressionEvaluationOptions %v", nd.ResourceGroup, nd.Properties["expressionEvaluationOptions"])
+				}
+
+				// with inner scope, the nested template is evaluated in the
+				// resource group it is deployed to
+				scope, err := expression.EvaluateString(resources[0].(map[string]interface{})["properties"].(map[string]interface{})["scope"].(string), &expression.Env{
+					SubscriptionID:    nd.SubscriptionID,
+					ResourceGroupName: nd.ResourceGroup,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				got = append(got, nested{
+					subscriptionID:  nd.SubscriptionID,
+					resourceGroup:   nd.ResourceGroup,
+					roleAssignments: len(resources),
+					scope:           scope.(string),
+				})
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v", got)
+			}
+		})
+	}
+}
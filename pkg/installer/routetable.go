@@ -0,0 +1,208 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	mgmtnetwork "github.com/Azure/azure-sdk-for-go/services/network/mgmt/2020-08-01/network"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/to"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+)
+
+// requiredServiceTags are the service tags of the endpoints the cluster needs
+// to reach during installation: ARM, storage (ignition and boot diagnostics)
+// and ACR (release images)
+var requiredServiceTags = []string{
+	"AzureResourceManager",
+	"Storage",
+	"AzureContainerRegistry",
+}
+
+// validateRouteTables checks the customer route tables of UserDefinedRouting
+// clusters.  Such clusters get no public IPs, so the route table attached to
+// the cluster subnets is the only way out.
+func (m *manager) validateRouteTables(ctx context.Context) error {
+	if m.oc.Properties.NetworkProfile.OutboundType != api.OutboundTypeUserDefinedRouting {
+		return nil
+	}
+
+	m.log.Print("validating route tables")
+
+	endpoints := m.requiredEndpointAddresses(ctx)
+
+	var details []api.CloudErrorBody
+	validated := map[string]bool{}
+
+	for _, ls := range m.linkedSubnets() {
+		s, err := m.subnet.Get(ctx, ls.id)
+		if err != nil {
+			return err
+		}
+
+		if s.SubnetPropertiesFormat == nil || s.RouteTable == nil || s.RouteTable.ID == nil {
+			details = append(details, api.CloudErrorBody{
+				Code:    api.CloudErrorCodeInvalidLinkedRouteTable,
+				Target:  ls.path,
+				Message: fmt.Sprintf("The provided subnet '%s' is invalid: must have a route table attached when outboundType is %s.", ls.id, api.OutboundTypeUserDefinedRouting),
+			})
+			continue
+		}
+
+		rtID := *s.RouteTable.ID
+		if validated[strings.ToLower(rtID)] {
+			continue
+		}
+		validated[strings.ToLower(rtID)] = true
+
+		r, err := azure.ParseResourceID(rtID)
+		if err != nil {
+			return err
+		}
+
+		rt, err := m.routeTables.Get(ctx, r.ResourceGroup, r.ResourceName, "")
+		if err != nil {
+			return err
+		}
+
+		details = append(details, validateRouteTable(rtID, &rt, endpoints)...)
+	}
+
+	if len(details) == 0 {
+		return nil
+	}
+
+	cloudErr := api.NewCloudError(http.StatusBadRequest, api.CloudErrorCodeInvalidLinkedRouteTable, "", "The route tables attached to the cluster subnets are invalid.")
+	cloudErr.Details = details
+
+	return cloudErr
+}
+
+// requiredEndpointAddresses returns the addresses of the endpoints the cluster
+// needs to reach during installation, keyed by host name: ARM, the cluster
+// storage account and the ARO container registry.  Hosts which can't be
+// resolved are skipped; route tables are then only checked by service tag.
+func (m *manager) requiredEndpointAddresses(ctx context.Context) map[string][]net.IP {
+	var hosts []string
+
+	u, err := url.Parse(m.env.Environment().ResourceManagerEndpoint)
+	if err == nil {
+		hosts = append(hosts, u.Hostname())
+	}
+
+	hosts = append(hosts,
+		"cluster"+m.oc.Properties.StorageSuffix+".blob."+m.env.Environment().StorageEndpointSuffix,
+		m.env.ACRDomain(),
+	)
+
+	endpoints := map[string][]net.IP{}
+	for _, host := range hosts {
+		ips, err := m.lookupIP(ctx, "ip4", host)
+		if err != nil {
+			m.log.Warnf("resolving %s: %v", host, err)
+			continue
+		}
+
+		endpoints[host] = ips
+	}
+
+	return endpoints
+}
+
+// validateRouteTable checks that the route table sends the default route to a
+// virtual appliance or gateway and doesn't drop traffic to the endpoints the
+// cluster needs, whether they are routed by service tag or by address prefix
+func validateRouteTable(rtID string, rt *mgmtnetwork.RouteTable, endpoints map[string][]net.IP) []api.CloudErrorBody {
+	var details []api.CloudErrorBody
+
+	invalid := func(format string, a ...interface{}) {
+		details = append(details, api.CloudErrorBody{
+			Code:    api.CloudErrorCodeInvalidLinkedRouteTable,
+			Target:  rtID,
+			Message: fmt.Sprintf("The provided route table '%s' is invalid: %s.", rtID, fmt.Sprintf(format, a...)),
+		})
+	}
+
+	var routes []mgmtnetwork.Route
+	if rt.RouteTablePropertiesFormat != nil && rt.Routes != nil {
+		routes = *rt.Routes
+	}
+
+	var hasDefaultRoute bool
+	for _, route := range routes {
+		if route.RoutePropertiesFormat == nil {
+			continue
+		}
+
+		addressPrefix := to.String(route.AddressPrefix)
+
+		if addressPrefix == "0.0.0.0/0" &&
+			(route.NextHopType == mgmtnetwork.RouteNextHopTypeVirtualAppliance ||
+				route.NextHopType == mgmtnetwork.RouteNextHopTypeVirtualNetworkGateway) {
+			hasDefaultRoute = true
+		}
+
+		if route.NextHopType != mgmtnetwork.RouteNextHopTypeNone {
+			continue
+		}
+
+		if isRequiredServiceTag(addressPrefix) {
+			invalid("route '%s' must not drop traffic to %s", to.String(route.Name), addressPrefix)
+			continue
+		}
+
+		_, ipnet, err := net.ParseCIDR(addressPrefix)
+		if err != nil {
+			continue
+		}
+
+		for _, host := range sortedHosts(endpoints) {
+			for _, ip := range endpoints[host] {
+				if ipnet.Contains(ip) {
+					invalid("route '%s' must not drop traffic to %s (%s)", to.String(route.Name), host, ip)
+					break
+				}
+			}
+		}
+	}
+
+	if !hasDefaultRoute {
+		invalid("must have a 0.0.0.0/0 route to a virtual appliance or virtual network gateway")
+	}
+
+	return details
+}
+
+// isRequiredServiceTag returns true if the route address prefix is one of
+// requiredServiceTags, or a regional variant of it such as Storage.eastus
+func isRequiredServiceTag(addressPrefix string) bool {
+	tag := strings.SplitN(addressPrefix, ".", 2)[0]
+
+	for _, t := range requiredServiceTags {
+		if strings.EqualFold(tag, t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sortedHosts(endpoints map[string][]net.IP) []string {
+	hosts := make([]string, 0, len(endpoints))
+	for host := range endpoints {
+		hosts = append(hosts, host)
+	}
+
+	sort.Strings(hosts)
+
+	return hosts
+}
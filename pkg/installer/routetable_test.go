@@ -0,0 +1,167 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	mgmtnetwork "github.com/Azure/azure-sdk-for-go/services/network/mgmt/2020-08-01/network"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/azureclient"
+	mock_network "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/azureclient/mgmt/network"
+	mock_env "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/env"
+	mock_subnet "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/subnet"
+)
+
+func TestValidateRouteTables(t *testing.T) {
+	ctx := context.Background()
+
+	vnetID := "/subscriptions/subscriptionId/resourceGroups/vnetResourceGroup/providers/Microsoft.Network/virtualNetworks/vnet"
+	masterSubnetID := vnetID + "/subnets/master"
+	workerSubnetID := vnetID + "/subnets/worker"
+	rtID := "/subscriptions/subscriptionId/resourceGroups/vnetResourceGroup/providers/Microsoft.Network/routeTables/rt"
+
+	subnetWithRouteTable := &mgmtnetwork.Subnet{
+		SubnetPropertiesFormat: &mgmtnetwork.SubnetPropertiesFormat{
+			RouteTable: &mgmtnetwork.RouteTable{ID: to.StringPtr(rtID)},
+		},
+	}
+
+	route := func(name, addressPrefix string, nextHopType mgmtnetwork.RouteNextHopType) mgmtnetwork.Route {
+		return mgmtnetwork.Route{
+			Name: to.StringPtr(name),
+			RoutePropertiesFormat: &mgmtnetwork.RoutePropertiesFormat{
+				AddressPrefix: to.StringPtr(addressPrefix),
+				NextHopType:   nextHopType,
+			},
+		}
+	}
+
+	routeTable := func(routes ...mgmtnetwork.Route) mgmtnetwork.RouteTable {
+		return mgmtnetwork.RouteTable{
+			RouteTablePropertiesFormat: &mgmtnetwork.RouteTablePropertiesFormat{
+				Routes: &routes,
+			},
+		}
+	}
+
+	for _, tt := range []struct {
+		name         string
+		outboundType api.OutboundType
+		mocks        func(*mock_subnet.MockManager, *mock_network.MockRouteTablesClient)
+		wantErr      string
+	}{
+		{
+			name:         "not a user defined routing cluster",
+			outboundType: api.OutboundTypeLoadbalancer,
+		},
+		{
+			name:         "valid",
+			outboundType: api.OutboundTypeUserDefinedRouting,
+			mocks: func(subnets *mock_subnet.MockManager, routeTables *mock_network.MockRouteTablesClient) {
+				subnets.EXPECT().Get(ctx, masterSubnetID).Return(subnetWithRouteTable, nil)
+				subnets.EXPECT().Get(ctx, workerSubnetID).Return(subnetWithRouteTable, nil)
+				routeTables.EXPECT().Get(ctx, "vnetResourceGroup", "rt", "").Return(routeTable(
+					route("default", "0.0.0.0/0", mgmtnetwork.RouteNextHopTypeVirtualAppliance),
+					route("blocked", "10.1.0.0/16", mgmtnetwork.RouteNextHopTypeNone),
+				), nil)
+			},
+		},
+		{
+			name:         "address prefixes drop traffic to required endpoints",
+			outboundType: api.OutboundTypeUserDefinedRouting,
+			mocks: func(subnets *mock_subnet.MockManager, routeTables *mock_network.MockRouteTablesClient) {
+				subnets.EXPECT().Get(ctx, masterSubnetID).Return(subnetWithRouteTable, nil)
+				subnets.EXPECT().Get(ctx, workerSubnetID).Return(subnetWithRouteTable, nil)
+				routeTables.EXPECT().Get(ctx, "vnetResourceGroup", "rt", "").Return(routeTable(
+					route("default", "0.0.0.0/0", mgmtnetwork.RouteNextHopTypeVirtualAppliance),
+					route("storage", "20.60.0.0/16", mgmtnetwork.RouteNextHopTypeNone),
+					route("upper", "128.0.0.0/1", mgmtnetwork.RouteNextHopTypeNone),
+				), nil)
+			},
+			wantErr: "400: InvalidLinkedRouteTable: : The route tables attached to the cluster subnets are invalid. Details: " +
+				"InvalidLinkedRouteTable: " + rtID + ": The provided route table '" + rtID + "' is invalid: route 'storage' must not drop traffic to clustersuffix.blob.core.windows.net (20.60.1.1)., " +
+				"InvalidLinkedRouteTable: " + rtID + ": The provided route table '" + rtID + "' is invalid: route 'upper' must not drop traffic to management.azure.com (192.0.2.1).",
+		},
+		{
+			name:         "invalid",
+			outboundType: api.OutboundTypeUserDefinedRouting,
+			mocks: func(subnets *mock_subnet.MockManager, routeTables *mock_network.MockRouteTablesClient) {
+				subnets.EXPECT().Get(ctx, masterSubnetID).Return(subnetWithRouteTable, nil)
+				subnets.EXPECT().Get(ctx, workerSubnetID).Return(&mgmtnetwork.Subnet{
+					SubnetPropertiesFormat: &mgmtnetwork.SubnetPropertiesFormat{},
+				}, nil)
+				routeTables.EXPECT().Get(ctx, "vnetResourceGroup", "rt", "").Return(routeTable(
+					route("default", "0.0.0.0/0", mgmtnetwork.RouteNextHopTypeInternet),
+					route("storage", "Storage.eastus", mgmtnetwork.RouteNextHopTypeNone),
+				), nil)
+			},
+			wantErr: "400: InvalidLinkedRouteTable: : The route tables attached to the cluster subnets are invalid. Details: " +
+				"InvalidLinkedRouteTable: " + rtID + ": The provided route table '" + rtID + "' is invalid: route 'storage' must not drop traffic to Storage.eastus., " +
+				"InvalidLinkedRouteTable: " + rtID + ": The provided route table '" + rtID + "' is invalid: must have a 0.0.0.0/0 route to a virtual appliance or virtual network gateway., " +
+				"InvalidLinkedRouteTable: properties.workerProfiles[0].subnetId: The provided subnet '" + workerSubnetID + "' is invalid: must have a route table attached when outboundType is UserDefinedRouting.",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			subnets := mock_subnet.NewMockManager(controller)
+			routeTables := mock_network.NewMockRouteTablesClient(controller)
+			if tt.mocks != nil {
+				tt.mocks(subnets, routeTables)
+			}
+
+			_env := mock_env.NewMockInterface(controller)
+			_env.EXPECT().Environment().Return(&azureclient.PublicCloud).AnyTimes()
+			_env.EXPECT().ACRDomain().Return("arointsvc.azurecr.io").AnyTimes()
+
+			m := &manager{
+				log: logrus.NewEntry(logrus.StandardLogger()),
+				env: _env,
+				lookupIP: func(ctx context.Context, network, host string) ([]net.IP, error) {
+					switch host {
+					case "management.azure.com":
+						return []net.IP{net.ParseIP("192.0.2.1")}, nil
+					case "clustersuffix.blob.core.windows.net":
+						return []net.IP{net.ParseIP("20.60.1.1")}, nil
+					}
+					return nil, errors.New("no such host")
+				},
+				subnet:      subnets,
+				routeTables: routeTables,
+				oc: &api.OpenShiftCluster{
+					Properties: api.OpenShiftClusterProperties{
+						StorageSuffix: "suffix",
+						NetworkProfile: api.NetworkProfile{
+							OutboundType: tt.outboundType,
+						},
+						MasterProfile: api.MasterProfile{
+							SubnetID: masterSubnetID,
+						},
+						WorkerProfiles: []api.WorkerProfile{
+							{
+								SubnetID: workerSubnetID,
+								Count:    3,
+							},
+						},
+					},
+				},
+			}
+
+			err := m.validateRouteTables(ctx)
+			if err != nil && err.Error() != tt.wantErr ||
+				err == nil && tt.wantErr != "" {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	mgmtcompute "github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2020-06-01/compute"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/computeskus"
+)
+
+// vmSku returns the SKU of the given VM size.  Once validateVMSkus has run
+// this is the customer subscription's view of the SKU, otherwise the RP's.
+func (m *manager) vmSku(vmSize api.VMSize) (*mgmtcompute.ResourceSku, error) {
+	if m.vmSkus == nil {
+		return m.env.VMSku(string(vmSize))
+	}
+
+	sku, found := m.vmSkus[string(vmSize)]
+	if !found {
+		return nil, fmt.Errorf("sku information not found for vm size %q", vmSize)
+	}
+
+	return sku, nil
+}
+
+// validateVMSkus lists the VM SKUs available to the customer subscription in
+// the cluster location and checks that the master and worker sizes can be
+// used.  The SKUs are kept so that the install config uses the customer's
+// zones, which can differ from the RP's.
+func (m *manager) validateVMSkus(ctx context.Context) error {
+	m.log.Print("validating vm skus")
+
+	skus, err := m.resourceSkus.List(ctx, fmt.Sprintf("location eq '%s'", m.oc.Location))
+	if err != nil {
+		return err
+	}
+
+	vmSkus := computeskus.FilterVMSizes(skus, m.oc.Location)
+
+	var details []api.CloudErrorBody
+
+	details = append(details, m.validateVMSku(vmSkus, "properties.masterProfile.vmSize", m.oc.Properties.MasterProfile.VMSize, m.oc.Properties.MasterProfile.EncryptionAtHost)...)
+	for i, wp := range m.oc.Properties.WorkerProfiles {
+		details = append(details, m.validateVMSku(vmSkus, fmt.Sprintf("properties.workerProfiles[%d].vmSize", i), wp.VMSize, wp.EncryptionAtHost)...)
+	}
+
+	if len(details) > 0 {
+		cloudErr := api.NewCloudError(http.StatusBadRequest, api.CloudErrorCodeInvalidParameter, "", "The selected VM sizes cannot be used in region '%s'.", m.oc.Location)
+		cloudErr.Details = details
+		return cloudErr
+	}
+
+	m.vmSkus = vmSkus
+
+	return nil
+}
+
+func (m *manager) validateVMSku(vmSkus map[string]*mgmtcompute.ResourceSku, path string, vmSize api.VMSize, encryptionAtHost api.EncryptionAtHost) []api.CloudErrorBody {
+	var details []api.CloudErrorBody
+
+	invalid := func(format string, a ...interface{}) {
+		details = append(details, api.CloudErrorBody{
+			Code:    api.CloudErrorCodeInvalidParameter,
+			Target:  path,
+			Message: fmt.Sprintf(format, a...),
+		})
+	}
+
+	sku, found := vmSkus[string(vmSize)]
+	if !found {
+		invalid("The selected SKU '%s' is unavailable in region '%s'.", vmSize, m.oc.Location)
+		return details
+	}
+
+	if computeskus.IsRestricted(vmSkus, m.oc.Location, string(vmSize)) {
+		invalid("The selected SKU '%s' is restricted in region '%s' for selected subscription.", vmSize, m.oc.Location)
+		return details
+	}
+
+	if len(computeskus.Zones(sku)) > 0 && len(computeskus.AvailableZones(sku, m.oc.Location)) == 0 {
+		invalid("The selected SKU '%s' is restricted in all zones of region '%s' for selected subscription.", vmSize, m.oc.Location)
+	}
+
+	if !computeskus.HasCapability(sku, "PremiumIO") {
+		invalid("The selected SKU '%s' does not support premium storage.", vmSize)
+	}
+
+	if encryptionAtHost == api.EncryptionAtHostEnabled && !computeskus.HasCapability(sku, "EncryptionAtHostSupported") {
+		invalid("The selected SKU '%s' does not support encryption at host.", vmSize)
+	}
+
+	return details
+}
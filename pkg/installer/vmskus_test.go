@@ -0,0 +1,146 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	mgmtcompute "github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2020-06-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/computeskus"
+	mock_compute "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/azureclient/mgmt/compute"
+)
+
+func TestValidateVMSkus(t *testing.T) {
+	ctx := context.Background()
+
+	sku := func(name string, capabilities map[string]string, restrictions ...mgmtcompute.ResourceSkuRestrictions) mgmtcompute.ResourceSku {
+		var caps []mgmtcompute.ResourceSkuCapabilities
+		for k, v := range capabilities {
+			caps = append(caps, mgmtcompute.ResourceSkuCapabilities{Name: to.StringPtr(k), Value: to.StringPtr(v)})
+		}
+
+		return mgmtcompute.ResourceSku{
+			Name:         to.StringPtr(name),
+			ResourceType: to.StringPtr("virtualMachines"),
+			Locations:    &[]string{"eastus"},
+			LocationInfo: &[]mgmtcompute.ResourceSkuLocationInfo{
+				{Zones: &[]string{"1", "2", "3"}},
+			},
+			Capabilities: &caps,
+			Restrictions: &restrictions,
+		}
+	}
+
+	capable := map[string]string{"PremiumIO": "True", "EncryptionAtHostSupported": "True"}
+
+	for _, tt := range []struct {
+		name      string
+		skus      []mgmtcompute.ResourceSku
+		wantZones []string
+		wantErr   string
+	}{
+		{
+			name: "valid",
+			skus: []mgmtcompute.ResourceSku{
+				sku("Standard_D8s_v3", capable),
+				sku("Standard_D4s_v3", capable, mgmtcompute.ResourceSkuRestrictions{
+					Type: mgmtcompute.Zone,
+					RestrictionInfo: &mgmtcompute.ResourceSkuRestrictionInfo{
+						Locations: &[]string{"eastus"},
+						Zones:     &[]string{"2"},
+					},
+				}),
+			},
+			wantZones: []string{"1", "3"},
+		},
+		{
+			name:    "missing",
+			skus:    []mgmtcompute.ResourceSku{sku("Standard_D8s_v3", capable)},
+			wantErr: "400: InvalidParameter: : The selected VM sizes cannot be used in region 'eastus'. Details: InvalidParameter: properties.workerProfiles[0].vmSize: The selected SKU 'Standard_D4s_v3' is unavailable in region 'eastus'.",
+		},
+		{
+			name: "restricted in location",
+			skus: []mgmtcompute.ResourceSku{
+				sku("Standard_D8s_v3", capable, mgmtcompute.ResourceSkuRestrictions{
+					Type: mgmtcompute.Location,
+					RestrictionInfo: &mgmtcompute.ResourceSkuRestrictionInfo{
+						Locations: &[]string{"eastus"},
+					},
+				}),
+				sku("Standard_D4s_v3", capable),
+			},
+			wantErr: "400: InvalidParameter: : The selected VM sizes cannot be used in region 'eastus'. Details: InvalidParameter: properties.masterProfile.vmSize: The selected SKU 'Standard_D8s_v3' is restricted in region 'eastus' for selected subscription.",
+		},
+		{
+			name: "restricted in all zones and missing capabilities",
+			skus: []mgmtcompute.ResourceSku{
+				sku("Standard_D8s_v3", map[string]string{"PremiumIO": "False"}, mgmtcompute.ResourceSkuRestrictions{
+					Type: mgmtcompute.Zone,
+					RestrictionInfo: &mgmtcompute.ResourceSkuRestrictionInfo{
+						Locations: &[]string{"eastus"},
+						Zones:     &[]string{"1", "2", "3"},
+					},
+				}),
+				sku("Standard_D4s_v3", capable),
+			},
+			wantErr: "400: InvalidParameter: : The selected VM sizes cannot be used in region 'eastus'. Details: " +
+				"InvalidParameter: properties.masterProfile.vmSize: The selected SKU 'Standard_D8s_v3' is restricted in all zones of region 'eastus' for selected subscription., " +
+				"InvalidParameter: properties.masterProfile.vmSize: The selected SKU 'Standard_D8s_v3' does not support premium storage., " +
+				"InvalidParameter: properties.masterProfile.vmSize: The selected SKU 'Standard_D8s_v3' does not support encryption at host.",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			resourceSkus := mock_compute.NewMockResourceSkusClient(controller)
+			resourceSkus.EXPECT().List(ctx, "location eq 'eastus'").Return(tt.skus, nil)
+
+			m := &manager{
+				log:          logrus.NewEntry(logrus.StandardLogger()),
+				resourceSkus: resourceSkus,
+				oc: &api.OpenShiftCluster{
+					Location: "eastus",
+					Properties: api.OpenShiftClusterProperties{
+						MasterProfile: api.MasterProfile{
+							VMSize:           api.VMSizeStandardD8sV3,
+							EncryptionAtHost: api.EncryptionAtHostEnabled,
+						},
+						WorkerProfiles: []api.WorkerProfile{
+							{
+								VMSize: api.VMSizeStandardD4sV3,
+							},
+						},
+					},
+				},
+			}
+
+			err := m.validateVMSkus(ctx)
+			if err != nil && err.Error() != tt.wantErr ||
+				err == nil && tt.wantErr != "" {
+				t.Fatal(err)
+			}
+			if err != nil {
+				return
+			}
+
+			sku, err := m.vmSku(api.VMSizeStandardD4sV3)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			zones := computeskus.AvailableZones(sku, m.oc.Location)
+			if !reflect.DeepEqual(zones, tt.wantZones) {
+				t.Errorf("got zones %v, wanted %v", zones, tt.wantZones)
+			}
+		})
+	}
+}
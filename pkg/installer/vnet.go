@@ -0,0 +1,271 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	mgmtnetwork "github.com/Azure/azure-sdk-for-go/services/network/mgmt/2020-08-01/network"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/apparentlymart/go-cidr/cidr"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/subnet"
+)
+
+// azureReservedAddresses is the number of addresses Azure reserves in every
+// subnet: the first four and the broadcast address
+const azureReservedAddresses = 5
+
+// recommendedServiceEndpoints are the service endpoints which keep the nodes'
+// image pulls from the ARO container registry on the Azure backbone.  Nodes
+// can reach the registry without them, e.g. through a firewall or NAT gateway,
+// so their absence is only warned about.
+var recommendedServiceEndpoints = []string{
+	"Microsoft.ContainerRegistry",
+}
+
+// linkedSubnet is a customer subnet the cluster will deploy NICs into
+type linkedSubnet struct {
+	id       string
+	path     string
+	isMaster bool
+
+	// addresses is the number of node addresses the cluster needs in the
+	// subnet
+	addresses int
+}
+
+// linkedSubnets returns the unique master and worker subnets of the cluster
+func (m *manager) linkedSubnets() []*linkedSubnet {
+	// the bootstrap VM uses the master subnet
+	subnets := []*linkedSubnet{
+		{
+			id:        m.oc.Properties.MasterProfile.SubnetID,
+			path:      "properties.masterProfile.subnetId",
+			isMaster:  true,
+			addresses: bootstrapAndMasterCount,
+		},
+	}
+
+	for i, wp := range m.oc.Properties.WorkerProfiles {
+		var found bool
+		for _, s := range subnets {
+			if strings.EqualFold(s.id, wp.SubnetID) {
+				s.addresses += wp.Count
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			subnets = append(subnets, &linkedSubnet{
+				id:        wp.SubnetID,
+				path:      fmt.Sprintf("properties.workerProfiles[%d].subnetId", i),
+				addresses: wp.Count,
+			})
+		}
+	}
+
+	return subnets
+}
+
+// validateVnet checks the cluster's master and worker subnets and their
+// virtual network before any NICs are deployed into them
+func (m *manager) validateVnet(ctx context.Context) error {
+	m.log.Print("validating virtual network")
+
+	vnetID, _, err := subnet.Split(m.oc.Properties.MasterProfile.SubnetID)
+	if err != nil {
+		return err
+	}
+
+	r, err := azure.ParseResourceID(vnetID)
+	if err != nil {
+		return err
+	}
+
+	vnet, err := m.virtualNetworks.Get(ctx, r.ResourceGroup, r.ResourceName, "")
+	if err != nil {
+		return err
+	}
+
+	clusterCIDRs, err := m.clusterCIDRs()
+	if err != nil {
+		return err
+	}
+
+	var details []api.CloudErrorBody
+
+	for _, ls := range m.linkedSubnets() {
+		s, err := m.subnet.Get(ctx, ls.id)
+		if err != nil {
+			return err
+		}
+
+		details = append(details, m.validateSubnet(ls, s, clusterCIDRs)...)
+	}
+
+	details = append(details, validateDNSServers(vnetID, &vnet, clusterCIDRs)...)
+
+	if len(details) == 0 {
+		return nil
+	}
+
+	cloudErr := api.NewCloudError(http.StatusBadRequest, api.CloudErrorCodeInvalidLinkedVNet, "", "The provided virtual network '%s' is invalid.", vnetID)
+	cloudErr.Details = details
+
+	return cloudErr
+}
+
+// clusterCIDRs returns the pod and service CIDRs of the cluster, keyed by API
+// path
+func (m *manager) clusterCIDRs() (map[string]*net.IPNet, error) {
+	cidrs := map[string]*net.IPNet{}
+
+	for path, s := range map[string]string{
+		"properties.networkProfile.podCidr":     m.oc.Properties.NetworkProfile.PodCIDR,
+		"properties.networkProfile.serviceCidr": m.oc.Properties.NetworkProfile.ServiceCIDR,
+	} {
+		if s == "" {
+			continue
+		}
+
+		_, ipnet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+
+		cidrs[path] = ipnet
+	}
+
+	return cidrs, nil
+}
+
+func (m *manager) validateSubnet(ls *linkedSubnet, s *mgmtnetwork.Subnet, clusterCIDRs map[string]*net.IPNet) []api.CloudErrorBody {
+	var details []api.CloudErrorBody
+
+	invalid := func(format string, a ...interface{}) {
+		details = append(details, api.CloudErrorBody{
+			Code:    api.CloudErrorCodeInvalidLinkedVNet,
+			Target:  ls.path,
+			Message: fmt.Sprintf("The provided subnet '%s' is invalid: %s.", ls.id, fmt.Sprintf(format, a...)),
+		})
+	}
+
+	if s.SubnetPropertiesFormat == nil || s.AddressPrefix == nil {
+		invalid("must have an address prefix")
+		return details
+	}
+
+	_, ipnet, err := net.ParseCIDR(*s.AddressPrefix)
+	if err != nil {
+		invalid("must have a valid address prefix")
+		return details
+	}
+
+	if int64(cidr.AddressCount(ipnet))-azureReservedAddresses < int64(ls.addresses) {
+		invalid("must be large enough for %d nodes", ls.addresses)
+	}
+
+	for _, path := range sortedCIDRPaths(clusterCIDRs) {
+		if cidrsOverlap(ipnet, clusterCIDRs[path]) {
+			invalid("must not overlap with %s %s", path, clusterCIDRs[path])
+		}
+	}
+
+	if s.NetworkSecurityGroup != nil && s.NetworkSecurityGroup.ID != nil {
+		nsgID, err := subnet.NetworkSecurityGroupID(m.oc, ls.id)
+		if err != nil || !strings.EqualFold(*s.NetworkSecurityGroup.ID, nsgID) {
+			invalid("must not have a network security group attached")
+		}
+	}
+
+	if ls.isMaster && !strings.EqualFold(to.String(s.PrivateLinkServiceNetworkPolicies), "Disabled") {
+		invalid("must have privateLinkServiceNetworkPolicies disabled")
+	}
+
+	for _, service := range recommendedServiceEndpoints {
+		if !hasServiceEndpoint(s, service) {
+			m.log.Warnf("subnet %s does not have the %s service endpoint", ls.id, service)
+		}
+	}
+
+	return details
+}
+
+// validateDNSServers checks that the custom DNS servers of the virtual
+// network, if any, are addresses the nodes could reach
+func validateDNSServers(vnetID string, vnet *mgmtnetwork.VirtualNetwork, clusterCIDRs map[string]*net.IPNet) []api.CloudErrorBody {
+	if vnet.VirtualNetworkPropertiesFormat == nil || vnet.DhcpOptions == nil || vnet.DhcpOptions.DNSServers == nil {
+		return nil
+	}
+
+	var details []api.CloudErrorBody
+
+	invalid := func(server, reason string) {
+		details = append(details, api.CloudErrorBody{
+			Code:    api.CloudErrorCodeInvalidLinkedVNet,
+			Target:  vnetID,
+			Message: fmt.Sprintf("The DNS server '%s' of the provided virtual network '%s' is invalid: %s.", server, vnetID, reason),
+		})
+	}
+
+	for _, server := range *vnet.DhcpOptions.DNSServers {
+		ip := net.ParseIP(server)
+		if ip == nil {
+			invalid(server, "must be an IP address")
+			continue
+		}
+
+		if ip.IsUnspecified() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsMulticast() {
+			invalid(server, "must be a routable unicast address")
+			continue
+		}
+
+		for _, path := range sortedCIDRPaths(clusterCIDRs) {
+			if clusterCIDRs[path].Contains(ip) {
+				invalid(server, fmt.Sprintf("must not be within %s %s", path, clusterCIDRs[path]))
+			}
+		}
+	}
+
+	return details
+}
+
+func hasServiceEndpoint(s *mgmtnetwork.Subnet, service string) bool {
+	if s.ServiceEndpoints == nil {
+		return false
+	}
+
+	for _, endpoint := range *s.ServiceEndpoints {
+		if endpoint.Service != nil && strings.EqualFold(*endpoint.Service, service) &&
+			endpoint.ProvisioningState == mgmtnetwork.Succeeded {
+			return true
+		}
+	}
+
+	return false
+}
+
+func cidrsOverlap(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+func sortedCIDRPaths(cidrs map[string]*net.IPNet) []string {
+	paths := make([]string, 0, len(cidrs))
+	for path := range cidrs {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	return paths
+}
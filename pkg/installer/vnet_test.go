@@ -0,0 +1,169 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	mgmtnetwork "github.com/Azure/azure-sdk-for-go/services/network/mgmt/2020-08-01/network"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+	mock_network "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/azureclient/mgmt/network"
+	mock_subnet "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/subnet"
+)
+
+func TestValidateVnet(t *testing.T) {
+	ctx := context.Background()
+
+	vnetID := "/subscriptions/subscriptionId/resourceGroups/vnetResourceGroup/providers/Microsoft.Network/virtualNetworks/vnet"
+	masterSubnetID := vnetID + "/subnets/master"
+	workerSubnetID := vnetID + "/subnets/worker"
+
+	validSubnet := func(addressPrefix string, isMaster bool) *mgmtnetwork.Subnet {
+		s := &mgmtnetwork.Subnet{
+			SubnetPropertiesFormat: &mgmtnetwork.SubnetPropertiesFormat{
+				AddressPrefix: to.StringPtr(addressPrefix),
+				ServiceEndpoints: &[]mgmtnetwork.ServiceEndpointPropertiesFormat{
+					{
+						Service:           to.StringPtr("Microsoft.ContainerRegistry"),
+						ProvisioningState: mgmtnetwork.Succeeded,
+					},
+				},
+			},
+		}
+		if isMaster {
+			s.PrivateLinkServiceNetworkPolicies = to.StringPtr("Disabled")
+		}
+		return s
+	}
+
+	for _, tt := range []struct {
+		name    string
+		mocks   func(*mock_network.MockVirtualNetworksClient, *mock_subnet.MockManager)
+		wantErr string
+	}{
+		{
+			name: "valid",
+			mocks: func(vnets *mock_network.MockVirtualNetworksClient, subnets *mock_subnet.MockManager) {
+				vnets.EXPECT().Get(ctx, "vnetResourceGroup", "vnet", "").Return(mgmtnetwork.VirtualNetwork{
+					VirtualNetworkPropertiesFormat: &mgmtnetwork.VirtualNetworkPropertiesFormat{
+						DhcpOptions: &mgmtnetwork.DhcpOptions{
+							DNSServers: &[]string{"10.0.0.4", "168.63.129.16"},
+						},
+					},
+				}, nil)
+				subnets.EXPECT().Get(ctx, masterSubnetID).Return(validSubnet("10.0.0.0/28", true), nil)
+				subnets.EXPECT().Get(ctx, workerSubnetID).Return(validSubnet("10.0.1.0/29", false), nil)
+			},
+		},
+		{
+			name: "invalid subnets",
+			mocks: func(vnets *mock_network.MockVirtualNetworksClient, subnets *mock_subnet.MockManager) {
+				vnets.EXPECT().Get(ctx, "vnetResourceGroup", "vnet", "").Return(mgmtnetwork.VirtualNetwork{}, nil)
+
+				master := validSubnet("10.0.0.0/30", true)
+				master.PrivateLinkServiceNetworkPolicies = to.StringPtr("Enabled")
+				subnets.EXPECT().Get(ctx, masterSubnetID).Return(master, nil)
+
+				worker := validSubnet("10.128.0.0/24", false)
+				worker.ServiceEndpoints = nil
+				worker.NetworkSecurityGroup = &mgmtnetwork.SecurityGroup{
+					ID: to.StringPtr("/subscriptions/subscriptionId/resourceGroups/vnetResourceGroup/providers/Microsoft.Network/networkSecurityGroups/nsg"),
+				}
+				subnets.EXPECT().Get(ctx, workerSubnetID).Return(worker, nil)
+			},
+			wantErr: "400: InvalidLinkedVNet: : The provided virtual network '" + vnetID + "' is invalid. Details: " +
+				"InvalidLinkedVNet: properties.masterProfile.subnetId: The provided subnet '" + masterSubnetID + "' is invalid: must be large enough for 4 nodes., " +
+				"InvalidLinkedVNet: properties.masterProfile.subnetId: The provided subnet '" + masterSubnetID + "' is invalid: must have privateLinkServiceNetworkPolicies disabled., " +
+				"InvalidLinkedVNet: properties.workerProfiles[0].subnetId: The provided subnet '" + workerSubnetID + "' is invalid: must not overlap with properties.networkProfile.podCidr 10.128.0.0/14., " +
+				"InvalidLinkedVNet: properties.workerProfiles[0].subnetId: The provided subnet '" + workerSubnetID + "' is invalid: must not have a network security group attached.",
+		},
+		{
+			name: "cluster network security group is allowed",
+			mocks: func(vnets *mock_network.MockVirtualNetworksClient, subnets *mock_subnet.MockManager) {
+				vnets.EXPECT().Get(ctx, "vnetResourceGroup", "vnet", "").Return(mgmtnetwork.VirtualNetwork{}, nil)
+
+				master := validSubnet("10.0.0.0/28", true)
+				master.NetworkSecurityGroup = &mgmtnetwork.SecurityGroup{
+					ID: to.StringPtr("/subscriptions/subscriptionId/resourceGroups/clusterResourceGroup/providers/Microsoft.Network/networkSecurityGroups/infra-nsg"),
+				}
+				subnets.EXPECT().Get(ctx, masterSubnetID).Return(master, nil)
+				subnets.EXPECT().Get(ctx, workerSubnetID).Return(validSubnet("10.0.1.0/29", false), nil)
+			},
+		},
+		{
+			name: "invalid DNS servers",
+			mocks: func(vnets *mock_network.MockVirtualNetworksClient, subnets *mock_subnet.MockManager) {
+				vnets.EXPECT().Get(ctx, "vnetResourceGroup", "vnet", "").Return(mgmtnetwork.VirtualNetwork{
+					VirtualNetworkPropertiesFormat: &mgmtnetwork.VirtualNetworkPropertiesFormat{
+						DhcpOptions: &mgmtnetwork.DhcpOptions{
+							DNSServers: &[]string{"dns.example.com", "127.0.0.1", "172.30.0.10"},
+						},
+					},
+				}, nil)
+				subnets.EXPECT().Get(ctx, masterSubnetID).Return(validSubnet("10.0.0.0/28", true), nil)
+				subnets.EXPECT().Get(ctx, workerSubnetID).Return(validSubnet("10.0.1.0/29", false), nil)
+			},
+			wantErr: "400: InvalidLinkedVNet: : The provided virtual network '" + vnetID + "' is invalid. Details: " +
+				"InvalidLinkedVNet: " + vnetID + ": The DNS server 'dns.example.com' of the provided virtual network '" + vnetID + "' is invalid: must be an IP address., " +
+				"InvalidLinkedVNet: " + vnetID + ": The DNS server '127.0.0.1' of the provided virtual network '" + vnetID + "' is invalid: must be a routable unicast address., " +
+				"InvalidLinkedVNet: " + vnetID + ": The DNS server '172.30.0.10' of the provided virtual network '" + vnetID + "' is invalid: must not be within properties.networkProfile.serviceCidr 172.30.0.0/16.",
+		},
+		{
+			name: "getting virtual network fails",
+			mocks: func(vnets *mock_network.MockVirtualNetworksClient, subnets *mock_subnet.MockManager) {
+				vnets.EXPECT().Get(ctx, "vnetResourceGroup", "vnet", "").Return(mgmtnetwork.VirtualNetwork{}, errors.New("oh no"))
+			},
+			wantErr: "oh no",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			vnets := mock_network.NewMockVirtualNetworksClient(controller)
+			subnets := mock_subnet.NewMockManager(controller)
+			tt.mocks(vnets, subnets)
+
+			m := &manager{
+				log:             logrus.NewEntry(logrus.StandardLogger()),
+				virtualNetworks: vnets,
+				subnet:          subnets,
+				oc: &api.OpenShiftCluster{
+					Properties: api.OpenShiftClusterProperties{
+						ArchitectureVersion: api.ArchitectureVersionV2,
+						InfraID:             "infra",
+						ClusterProfile: api.ClusterProfile{
+							ResourceGroupID: "/subscriptions/subscriptionId/resourceGroups/clusterResourceGroup",
+						},
+						NetworkProfile: api.NetworkProfile{
+							PodCIDR:     "10.128.0.0/14",
+							ServiceCIDR: "172.30.0.0/16",
+						},
+						MasterProfile: api.MasterProfile{
+							SubnetID: masterSubnetID,
+						},
+						WorkerProfiles: []api.WorkerProfile{
+							{
+								SubnetID: workerSubnetID,
+								Count:    3,
+							},
+						},
+					},
+				},
+			}
+
+			err := m.validateVnet(ctx)
+			if err != nil && err.Error() != tt.wantErr ||
+				err == nil && tt.wantErr != "" {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest/azure"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+	"github.com/openshift/installer-aro-wrapper/pkg/env"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/arm"
+)
+
+// ignoredVMProperties are write-only VM properties, which ARM cannot compare
+// and so always reports as modified
+var ignoredVMProperties = map[string]struct{}{
+	"properties.osProfile.adminPassword": {},
+	"properties.osProfile.customData":    {},
+}
+
+// previewResourceTemplate logs what re-deploying the resources template as
+// deploymentName would change.  It is called when the template has been
+// deployed before, e.g. after a partial failure.  Modifications to existing
+// VMs are unexpected: they are logged, and block the deployment if
+// FeatureBlockUnexpectedVMChanges is set.  The preview is best effort, so a
+// failing what-if does not block the deployment.
+func (m *manager) previewResourceTemplate(ctx context.Context, resourceGroup, deploymentName string, t *arm.Template, parameters map[string]interface{}) error {
+	changes, err := arm.WhatIf(ctx, m.log, m.deployments, resourceGroup, deploymentName, t, parameters)
+	if err != nil {
+		m.log.Warnf("previewing resources template: %v", err)
+		return nil
+	}
+
+	var details []api.CloudErrorBody
+	for _, c := range changes {
+		if c.Category != arm.WhatIfModify || !isVirtualMachine(c.ResourceID) {
+			continue
+		}
+
+		var properties []string
+		for _, p := range c.Properties {
+			if _, found := ignoredVMProperties[p]; !found {
+				properties = append(properties, p)
+			}
+		}
+
+		// ARM may not be able to predict the changes of a resource, in which
+		// case there is nothing to check
+		if len(properties) == 0 {
+			continue
+		}
+
+		details = append(details, api.CloudErrorBody{
+			Code:    api.CloudErrorCodeRequestNotAllowed,
+			Target:  c.ResourceID,
+			Message: "The virtual machine properties " + strings.Join(properties, ", ") + " would be modified.",
+		})
+	}
+
+	if len(details) == 0 {
+		return nil
+	}
+
+	cloudErr := api.NewCloudError(http.StatusBadRequest, api.CloudErrorCodeRequestNotAllowed, "", "Deploying the resources template would modify existing virtual machines.")
+	cloudErr.Details = details
+
+	if !m.env.FeatureIsSet(env.FeatureBlockUnexpectedVMChanges) {
+		m.log.Warn(cloudErr)
+		return nil
+	}
+
+	return cloudErr
+}
+
+func isVirtualMachine(resourceID string) bool {
+	r, err := azure.ParseResourceID(resourceID)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(r.Provider+"/"+r.ResourceType, "Microsoft.Compute/virtualMachines")
+}
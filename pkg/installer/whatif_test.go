@@ -0,0 +1,137 @@
+package installer
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	mgmtfeatures "github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-07-01/features"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/env"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/arm"
+	mock_features "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/azureclient/mgmt/features"
+	mock_env "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/env"
+)
+
+func TestPreviewResourceTemplate(t *testing.T) {
+	ctx := context.Background()
+
+	rgID := "/subscriptions/subscriptionId/resourceGroups/clusterResourceGroup"
+	vmID := rgID + "/providers/Microsoft.Compute/virtualMachines/infra-master-0"
+	nicID := rgID + "/providers/Microsoft.Network/networkInterfaces/infra-master0-nic"
+
+	change := func(resourceID string, changeType mgmtfeatures.ChangeType, paths ...string) mgmtfeatures.WhatIfChange {
+		var delta []mgmtfeatures.WhatIfPropertyChange
+		for _, path := range paths {
+			delta = append(delta, mgmtfeatures.WhatIfPropertyChange{
+				Path:               to.StringPtr(path),
+				PropertyChangeType: mgmtfeatures.PropertyChangeTypeModify,
+			})
+		}
+
+		return mgmtfeatures.WhatIfChange{
+			ResourceID: to.StringPtr(resourceID),
+			ChangeType: changeType,
+			Delta:      &delta,
+		}
+	}
+
+	for _, tt := range []struct {
+		name    string
+		mocks   func(*mock_features.MockDeploymentsClient, *mock_env.MockInterface)
+		wantErr string
+	}{
+		{
+			name: "what-if fails",
+			mocks: func(deployments *mock_features.MockDeploymentsClient, _env *mock_env.MockInterface) {
+				deployments.EXPECT().WhatIfAndWait(ctx, "clusterResourceGroup", "resources-1", gomock.Any()).
+					Return(mgmtfeatures.WhatIfOperationResult{}, errors.New("random error"))
+			},
+		},
+		{
+			name: "expected changes",
+			mocks: func(deployments *mock_features.MockDeploymentsClient, _env *mock_env.MockInterface) {
+				deployments.EXPECT().WhatIfAndWait(ctx, "clusterResourceGroup", "resources-1", gomock.Any()).
+					Return(mgmtfeatures.WhatIfOperationResult{
+						WhatIfOperationProperties: &mgmtfeatures.WhatIfOperationProperties{
+							Changes: &[]mgmtfeatures.WhatIfChange{
+								change(vmID, mgmtfeatures.Modify, "properties.osProfile.adminPassword"),
+								change(vmID, mgmtfeatures.Deploy),
+								change(nicID, mgmtfeatures.Modify, "properties.enableAcceleratedNetworking"),
+								change(rgID+"/providers/Microsoft.Compute/virtualMachines/infra-master-1", mgmtfeatures.Create),
+							},
+						},
+					}, nil)
+			},
+		},
+		{
+			name: "unexpected VM modification is logged",
+			mocks: func(deployments *mock_features.MockDeploymentsClient, _env *mock_env.MockInterface) {
+				deployments.EXPECT().WhatIfAndWait(ctx, "clusterResourceGroup", "resources-1", gomock.Any()).
+					Return(mgmtfeatures.WhatIfOperationResult{
+						WhatIfOperationProperties: &mgmtfeatures.WhatIfOperationProperties{
+							Changes: &[]mgmtfeatures.WhatIfChange{
+								change(vmID, mgmtfeatures.Modify, "properties.hardwareProfile.vmSize"),
+							},
+						},
+					}, nil)
+				_env.EXPECT().FeatureIsSet(env.FeatureBlockUnexpectedVMChanges).Return(false)
+			},
+		},
+		{
+			name: "unexpected VM modification is blocked",
+			mocks: func(deployments *mock_features.MockDeploymentsClient, _env *mock_env.MockInterface) {
+				deployments.EXPECT().WhatIfAndWait(ctx, "clusterResourceGroup", "resources-1", gomock.Any()).
+					Return(mgmtfeatures.WhatIfOperationResult{
+						WhatIfOperationProperties: &mgmtfeatures.WhatIfOperationProperties{
+							Changes: &[]mgmtfeatures.WhatIfChange{
+								change(vmID, mgmtfeatures.Modify, "properties.hardwareProfile.vmSize", "properties.osProfile.adminPassword", "zones"),
+							},
+						},
+					}, nil)
+				_env.EXPECT().FeatureIsSet(env.FeatureBlockUnexpectedVMChanges).Return(true)
+			},
+			wantErr: "400: RequestNotAllowed: : Deploying the resources template would modify existing virtual machines. Details: RequestNotAllowed: " + vmID + ": The virtual machine properties properties.hardwareProfile.vmSize, zones would be modified.",
+		},
+		{
+			name: "write-only properties are ignored",
+			mocks: func(deployments *mock_features.MockDeploymentsClient, _env *mock_env.MockInterface) {
+				deployments.EXPECT().WhatIfAndWait(ctx, "clusterResourceGroup", "resources-1", gomock.Any()).
+					Return(mgmtfeatures.WhatIfOperationResult{
+						WhatIfOperationProperties: &mgmtfeatures.WhatIfOperationProperties{
+							Changes: &[]mgmtfeatures.WhatIfChange{
+								change(vmID, mgmtfeatures.Modify, "properties.osProfile.adminPassword", "properties.osProfile.customData"),
+							},
+						},
+					}, nil)
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			deployments := mock_features.NewMockDeploymentsClient(controller)
+			_env := mock_env.NewMockInterface(controller)
+			tt.mocks(deployments, _env)
+
+			m := &manager{
+				log:         logrus.NewEntry(logrus.StandardLogger()),
+				env:         _env,
+				deployments: deployments,
+			}
+
+			err := m.previewResourceTemplate(ctx, "clusterResourceGroup", "resources-1", &arm.Template{}, map[string]interface{}{})
+			if err != nil && err.Error() != tt.wantErr ||
+				err == nil && tt.wantErr != "" {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -5,33 +5,38 @@ package arm
 
 import (
 	"context"
-	"encoding/json"
-	"net/http"
 
 	mgmtfeatures "github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-07-01/features"
 	"github.com/Azure/go-autorest/autorest"
 	"github.com/Azure/go-autorest/autorest/azure"
 	"github.com/sirupsen/logrus"
 
-	"github.com/openshift/installer-aro-wrapper/pkg/api"
 	"github.com/openshift/installer-aro-wrapper/pkg/util/azureclient/mgmt/features"
 	"github.com/openshift/installer-aro-wrapper/pkg/util/azureerrors"
 )
 
-func DeployTemplate(ctx context.Context, log *logrus.Entry, deployments features.DeploymentsClient, resourceGroupName string, deploymentName string, template *Template, parameters map[string]interface{}) error {
-	log.Printf("deploying %s template", deploymentName)
-	err := deployments.CreateOrUpdateAndWait(ctx, resourceGroupName, deploymentName, mgmtfeatures.Deployment{
-		Properties: &mgmtfeatures.DeploymentProperties{
-			Template:   template,
-			Parameters: parameters,
-			Mode:       mgmtfeatures.Incremental,
-		},
-	})
+// DeployTemplate deploys template in Incremental mode.  If operations is not
+// nil, the provisioning state of each resource is logged as the deployment
+// runs, followed by a per-resource summary, and failures are reported per
+// failed resource.
+func DeployTemplate(ctx context.Context, log *logrus.Entry, deployments features.DeploymentsClient, operations features.DeploymentOperationsClient, resourceGroupName string, deploymentName string, template *Template, parameters map[string]interface{}) error {
+	err := watchProgress(ctx, log, operations, resourceGroupName, deploymentName, func() error {
+		log.Printf("deploying %s template", deploymentName)
+		err := deployments.CreateOrUpdateAndWait(ctx, resourceGroupName, deploymentName, mgmtfeatures.Deployment{
+			Properties: &mgmtfeatures.DeploymentProperties{
+				Template:   template,
+				Parameters: parameters,
+				Mode:       mgmtfeatures.Incremental,
+			},
+		})
 
-	if azureerrors.IsDeploymentActiveError(err) {
-		log.Printf("waiting for %s template to be deployed", deploymentName)
-		err = deployments.Wait(ctx, resourceGroupName, deploymentName)
-	}
+		if azureerrors.IsDeploymentActiveError(err) {
+			log.Printf("waiting for %s template to be deployed", deploymentName)
+			err = deployments.Wait(ctx, resourceGroupName, deploymentName)
+		}
+
+		return err
+	})
 
 	if azureerrors.HasAuthorizationFailedError(err) ||
 		azureerrors.HasLinkedAuthorizationFailedError(err) {
@@ -46,20 +51,7 @@ func DeployTemplate(ctx context.Context, log *logrus.Entry, deployments features
 	}
 
 	if serviceErr != nil {
-		b, _ := json.Marshal(serviceErr)
-
-		return &api.CloudError{
-			StatusCode: http.StatusBadRequest,
-			CloudErrorBody: &api.CloudErrorBody{
-				Code:    api.CloudErrorCodeDeploymentFailed,
-				Message: "Deployment failed.",
-				Details: []api.CloudErrorBody{
-					{
-						Message: string(b),
-					},
-				},
-			},
-		}
+		return deploymentError(ctx, log, operations, resourceGroupName, deploymentName, serviceErr)
 	}
 
 	return err
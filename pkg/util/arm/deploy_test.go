@@ -85,7 +85,7 @@ func TestDeployARMTemplate(t *testing.T) {
 						},
 					})
 			},
-			wantErr: `400: DeploymentFailed: : Deployment failed. Details: : : {"code":"AccountIsDisabled","message":"","target":null,"details":null,"innererror":null,"additionalInfo":null}`,
+			wantErr: "400: DeploymentFailed: : Deployment failed. Details: AccountIsDisabled: : ",
 		},
 		{
 			name: "ServiceError which should be returned to user",
@@ -96,7 +96,7 @@ func TestDeployARMTemplate(t *testing.T) {
 						Code: "AccountIsDisabled",
 					})
 			},
-			wantErr: `400: DeploymentFailed: : Deployment failed. Details: : : {"code":"AccountIsDisabled","message":"","target":null,"details":null,"innererror":null,"additionalInfo":null}`,
+			wantErr: "400: DeploymentFailed: : Deployment failed. Details: AccountIsDisabled: : ",
 		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
@@ -108,7 +108,7 @@ func TestDeployARMTemplate(t *testing.T) {
 
 			log := logrus.NewEntry(logrus.StandardLogger())
 
-			err := DeployTemplate(ctx, log, deploymentsClient, resourceGroup, deploymentName, armTemplate, params)
+			err := DeployTemplate(ctx, log, deploymentsClient, nil, resourceGroup, deploymentName, armTemplate, params)
 
 			if err != nil && err.Error() != tt.wantErr ||
 				err == nil && tt.wantErr != "" {
@@ -0,0 +1,199 @@
+package arm
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/azureclient/mgmt/features"
+)
+
+// remediation maps well-known ARM error codes to the CloudError code returned
+// to the user and a hint on how to resolve the failure
+var remediation = map[string]struct {
+	code    string
+	message string
+}{
+	"SkuNotAvailable": {
+		code:    api.CloudErrorCodeSkuNotAvailable,
+		message: "Deployment failed because a requested VM size is not available in the region or zone. Choose a different VM size or region.",
+	},
+	"QuotaExceeded": {
+		code:    api.CloudErrorCodeQuotaExceeded,
+		message: "Deployment failed because the subscription quota is insufficient. Request a quota increase for the region and retry.",
+	},
+	"AllocationFailed": {
+		code:    api.CloudErrorCodeAllocationFailed,
+		message: "Deployment failed because Azure could not allocate the requested VMs. Retry later, or choose a different VM size or region.",
+	},
+	"RequestDisallowedByPolicy": {
+		code:    api.CloudErrorCodeRequestDisallowedByPolicy,
+		message: "Deployment failed because a resource was disallowed by an Azure Policy assignment. Exempt the cluster resource group from the policy and retry.",
+	},
+	"InvalidTemplateDeployment": {
+		code:    api.CloudErrorCodeInvalidTemplateDeployment,
+		message: "Deployment failed because the deployment template was rejected by ARM validation. Review the details and retry.",
+	},
+}
+
+// deploymentFailure is an error reported by ARM for a deployment, or for one
+// of its operations
+type deploymentFailure struct {
+	Code    string              `json:"code"`
+	Target  string              `json:"target"`
+	Message string              `json:"message"`
+	Details []deploymentFailure `json:"details"`
+}
+
+// leaves returns the most specific errors of f, one for each leaf of its
+// tree of details.  ARM nests errors in details, and sometimes serialises a
+// nested error into the message.
+func (f deploymentFailure) leaves() []deploymentFailure {
+	if len(f.Details) > 0 {
+		var leaves []deploymentFailure
+		for _, detail := range f.Details {
+			leaves = append(leaves, detail.leaves()...)
+		}
+		return leaves
+	}
+
+	var nested struct {
+		Error *deploymentFailure `json:"error"`
+	}
+	if json.Unmarshal([]byte(f.Message), &nested) == nil && nested.Error != nil {
+		return nested.Error.leaves()
+	}
+
+	return []deploymentFailure{f}
+}
+
+// deploymentError converts serviceErr, returned by a failed deployment, into
+// a CloudError with a detail for each failed resource.  If operations is not
+// nil, the failed resources are read from the deployment operations,
+// otherwise from serviceErr.  The raw error is logged at debug level.
+func deploymentError(ctx context.Context, log *logrus.Entry, operations features.DeploymentOperationsClient, resourceGroupName, deploymentName string, serviceErr *azure.ServiceError) *api.CloudError {
+	b, _ := json.Marshal(serviceErr)
+	log.Debugf("%s template failed: %s", deploymentName, string(b))
+
+	var failures []deploymentFailure
+	if operations != nil {
+		var err error
+		failures, err = failedOperations(ctx, operations, resourceGroupName, deploymentName)
+		if err != nil {
+			log.Debugf("listing %s template operations: %v", deploymentName, err)
+		}
+	}
+
+	if len(failures) == 0 {
+		failures = serviceErrorFailures(serviceErr)
+	}
+
+	cloudErr := api.NewCloudError(http.StatusBadRequest, api.CloudErrorCodeDeploymentFailed, "", "Deployment failed.")
+
+	// the first failure with a well-known code determines the error returned,
+	// falling back to the code of the deployment itself
+	codes := make([]string, 0, len(failures)+1)
+	for _, f := range failures {
+		codes = append(codes, f.Code)
+	}
+	codes = append(codes, serviceErr.Code)
+
+	for _, code := range codes {
+		if r, found := remediation[code]; found {
+			cloudErr.Code = r.code
+			cloudErr.Message = r.message
+			break
+		}
+	}
+
+	for _, f := range failures {
+		cloudErr.Details = append(cloudErr.Details, api.CloudErrorBody{
+			Code:    f.Code,
+			Target:  f.Target,
+			Message: f.Message,
+		})
+	}
+
+	return cloudErr
+}
+
+// failedOperations returns the most specific errors of each failed operation
+// of the deployment, targeted at the operation's resource
+func failedOperations(ctx context.Context, operations features.DeploymentOperationsClient, resourceGroupName, deploymentName string) ([]deploymentFailure, error) {
+	ops, err := operations.List(ctx, resourceGroupName, deploymentName)
+	if err != nil {
+		return nil, err
+	}
+
+	var failures []deploymentFailure
+	for _, op := range ops {
+		if op.Properties == nil ||
+			!strings.EqualFold(to.String(op.Properties.ProvisioningState), "Failed") {
+			continue
+		}
+
+		// StatusMessage is untyped in the SDK; it is usually of the form
+		// {"status": "Failed", "error": {...}}
+		b, err := json.Marshal(op.Properties.StatusMessage)
+		if err != nil {
+			continue
+		}
+
+		var statusMessage struct {
+			Error *deploymentFailure `json:"error"`
+		}
+		err = json.Unmarshal(b, &statusMessage)
+		if err != nil || statusMessage.Error == nil {
+			continue
+		}
+
+		for _, f := range statusMessage.Error.leaves() {
+			if op.Properties.TargetResource != nil {
+				f.Target = to.String(op.Properties.TargetResource.ResourceType) + "/" + to.String(op.Properties.TargetResource.ResourceName)
+			}
+
+			failures = append(failures, f)
+		}
+	}
+
+	return failures, nil
+}
+
+// serviceErrorFailures returns the most specific errors of each detail of
+// serviceErr, or of serviceErr itself if it has no details
+func serviceErrorFailures(serviceErr *azure.ServiceError) []deploymentFailure {
+	var failures []deploymentFailure
+	for _, detail := range serviceErr.Details {
+		b, err := json.Marshal(detail)
+		if err != nil {
+			continue
+		}
+
+		var f deploymentFailure
+		err = json.Unmarshal(b, &f)
+		if err != nil {
+			continue
+		}
+
+		failures = append(failures, f.leaves()...)
+	}
+
+	if len(failures) == 0 {
+		failures = deploymentFailure{
+			Code:    serviceErr.Code,
+			Target:  to.String(serviceErr.Target),
+			Message: serviceErr.Message,
+		}.leaves()
+	}
+
+	return failures
+}
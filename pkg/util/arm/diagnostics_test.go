@@ -0,0 +1,185 @@
+package arm
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	mgmtfeatures "github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-07-01/features"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+
+	mock_features "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/azureclient/mgmt/features"
+)
+
+func failedOperation(resourceType, resourceName string, statusMessage interface{}) mgmtfeatures.DeploymentOperation {
+	op := operation(resourceType, resourceName, "Failed", "PT1S")
+	op.Properties.StatusMessage = statusMessage
+	return op
+}
+
+func TestDeploymentError(t *testing.T) {
+	ctx := context.Background()
+
+	deploymentFailed := &azure.ServiceError{
+		Code:    "DeploymentFailed",
+		Message: "At least one resource deployment operation failed.",
+	}
+
+	for _, tt := range []struct {
+		name       string
+		serviceErr *azure.ServiceError
+		mocks      func(*mock_features.MockDeploymentOperationsClient)
+		noOps      bool
+		wantErr    string
+	}{
+		{
+			name:       "failed operations with a well-known code",
+			serviceErr: deploymentFailed,
+			mocks: func(operations *mock_features.MockDeploymentOperationsClient) {
+				operations.EXPECT().List(ctx, "rg", deploymentName).Return([]mgmtfeatures.DeploymentOperation{
+					operation("Microsoft.Network/loadBalancers", "lb", "Succeeded", "PT1S"),
+					failedOperation("Microsoft.Compute/virtualMachines", "master-0", map[string]interface{}{
+						"status": "Failed",
+						"error": map[string]interface{}{
+							"code":    "BadRequest",
+							"message": "Bad request.",
+							"details": []interface{}{
+								map[string]interface{}{
+									"code":    "SkuNotAvailable",
+									"message": "The requested VM size is not available.",
+								},
+							},
+						},
+					}),
+					failedOperation("Microsoft.Compute/virtualMachines", "master-1", map[string]interface{}{
+						"error": map[string]interface{}{
+							"code":    "Conflict",
+							"message": "Conflict.",
+						},
+					}),
+				}, nil)
+			},
+			wantErr: "400: SkuNotAvailable: : Deployment failed because a requested VM size is not available in the region or zone. Choose a different VM size or region. Details: SkuNotAvailable: Microsoft.Compute/virtualMachines/master-0: The requested VM size is not available., Conflict: Microsoft.Compute/virtualMachines/master-1: Conflict.",
+		},
+		{
+			name:       "failed operation with several nested errors",
+			serviceErr: deploymentFailed,
+			mocks: func(operations *mock_features.MockDeploymentOperationsClient) {
+				operations.EXPECT().List(ctx, "rg", deploymentName).Return([]mgmtfeatures.DeploymentOperation{
+					failedOperation("Microsoft.Resources/deployments", "roleassignments", map[string]interface{}{
+						"error": map[string]interface{}{
+							"code":    "DeploymentFailed",
+							"message": "At least one resource deployment operation failed.",
+							"details": []interface{}{
+								map[string]interface{}{
+									"code":    "Conflict",
+									"message": "Conflict.",
+									"details": []interface{}{
+										map[string]interface{}{
+											"code":    "RoleAssignmentExists",
+											"message": "The role assignment already exists.",
+										},
+									},
+								},
+								map[string]interface{}{
+									"code":    "Forbidden",
+									"message": `{"error": {"code": "AuthorizationFailed", "message": "The client does not have authorization."}}`,
+								},
+							},
+						},
+					}),
+				}, nil)
+			},
+			wantErr: "400: DeploymentFailed: : Deployment failed. Details: RoleAssignmentExists: Microsoft.Resources/deployments/roleassignments: The role assignment already exists., AuthorizationFailed: Microsoft.Resources/deployments/roleassignments: The client does not have authorization.",
+		},
+		{
+			name:       "failed operations with an unknown code",
+			serviceErr: deploymentFailed,
+			mocks: func(operations *mock_features.MockDeploymentOperationsClient) {
+				operations.EXPECT().List(ctx, "rg", deploymentName).Return([]mgmtfeatures.DeploymentOperation{
+					failedOperation("Microsoft.Storage/storageAccounts", "cluster", map[string]interface{}{
+						"error": map[string]interface{}{
+							"code":    "AccountIsDisabled",
+							"message": "The account is disabled.",
+						},
+					}),
+				}, nil)
+			},
+			wantErr: "400: DeploymentFailed: : Deployment failed. Details: AccountIsDisabled: Microsoft.Storage/storageAccounts/cluster: The account is disabled.",
+		},
+		{
+			name: "listing operations fails, fall back to the service error details",
+			serviceErr: &azure.ServiceError{
+				Code:    "DeploymentFailed",
+				Message: "At least one resource deployment operation failed.",
+				Details: []map[string]interface{}{
+					{
+						"code":    "Conflict",
+						"message": `{"error": {"code": "QuotaExceeded", "message": "Operation could not be completed as it results in exceeding approved quota."}}`,
+					},
+				},
+			},
+			mocks: func(operations *mock_features.MockDeploymentOperationsClient) {
+				operations.EXPECT().List(ctx, "rg", deploymentName).Return(nil, errors.New("random error"))
+			},
+			wantErr: "400: QuotaExceeded: : Deployment failed because the subscription quota is insufficient. Request a quota increase for the region and retry. Details: QuotaExceeded: : Operation could not be completed as it results in exceeding approved quota.",
+		},
+		{
+			name: "template validation failure without operations",
+			serviceErr: &azure.ServiceError{
+				Code:    "InvalidTemplateDeployment",
+				Message: "The template deployment failed because of policy violation.",
+				Details: []map[string]interface{}{
+					{
+						"code":    "RequestDisallowedByPolicy",
+						"target":  "cluster",
+						"message": "Resource 'cluster' was disallowed by policy.",
+					},
+				},
+			},
+			noOps:   true,
+			wantErr: "400: RequestDisallowedByPolicy: : Deployment failed because a resource was disallowed by an Azure Policy assignment. Exempt the cluster resource group from the policy and retry. Details: RequestDisallowedByPolicy: cluster: Resource 'cluster' was disallowed by policy.",
+		},
+		{
+			name: "service error without details",
+			serviceErr: &azure.ServiceError{
+				Code:    "InvalidTemplateDeployment",
+				Message: "The template is invalid.",
+				Target:  to.StringPtr("template"),
+			},
+			noOps:   true,
+			wantErr: "400: InvalidTemplateDeployment: : Deployment failed because the deployment template was rejected by ARM validation. Review the details and retry. Details: InvalidTemplateDeployment: template: The template is invalid.",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			var operations *mock_features.MockDeploymentOperationsClient
+			if !tt.noOps {
+				operations = mock_features.NewMockDeploymentOperationsClient(controller)
+				tt.mocks(operations)
+			}
+
+			log := logrus.NewEntry(logrus.StandardLogger())
+
+			var err error
+			if tt.noOps {
+				err = deploymentError(ctx, log, nil, "rg", deploymentName, tt.serviceErr)
+			} else {
+				err = deploymentError(ctx, log, operations, "rg", deploymentName, tt.serviceErr)
+			}
+
+			if err != nil && err.Error() != tt.wantErr ||
+				err == nil && tt.wantErr != "" {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package expression
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gofrs/uuid"
+)
+
+// guidNamespace is the UUID namespace of the ARM guid() function
+var guidNamespace = uuid.Must(uuid.FromString("11fb06fb-712d-4ddd-98c7-e71bbd588830"))
+
+// Env is the deployment context an expression is evaluated in.  It is
+// intended for unit tests, so that templates can be checked without
+// deploying them.
+type Env struct {
+	SubscriptionID    string
+	ResourceGroupName string
+	Location          string
+
+	// CopyIndex is the iteration of the resource copy loop
+	CopyIndex int
+
+	Parameters map[string]interface{}
+
+	// SASToken is returned by listAccountSas and listServiceSas
+	SASToken string
+}
+
+// Evaluate evaluates e in env.  Only the functions with constructors in this
+// package are supported, and uniqueString cannot be evaluated.
+func Evaluate(e Expression, env *Env) (interface{}, error) {
+	return e.eval(env)
+}
+
+// EvaluateString evaluates the ARM template string value s in env, e.g.
+// [concat('a', copyIndex())]
+func EvaluateString(s string, env *Env) (interface{}, error) {
+	e, err := Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return Evaluate(e, env)
+}
+
+func (l literal) eval(*Env) (interface{}, error) {
+	return string(l), nil
+}
+
+func (i integer) eval(*Env) (interface{}, error) {
+	return int(i), nil
+}
+
+func (p *property) eval(env *Env) (interface{}, error) {
+	v, err := p.e.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: not an object", p.e)
+	}
+
+	v, found := m[p.name]
+	if !found {
+		return nil, fmt.Errorf("%s: property %q not found", p.e, p.name)
+	}
+
+	return v, nil
+}
+
+func (c *call) eval(env *Env) (interface{}, error) {
+	args := make([]interface{}, 0, len(c.args))
+	for _, arg := range c.args {
+		v, err := arg.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+
+	f, found := functions[c.name]
+	if !found {
+		return nil, fmt.Errorf("%s: unsupported function %q", c, c.name)
+	}
+
+	v, err := f(env, args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c, err)
+	}
+
+	return v, nil
+}
+
+var functions = map[string]func(env *Env, args []interface{}) (interface{}, error){
+	"concat": func(env *Env, args []interface{}) (interface{}, error) {
+		var sb strings.Builder
+		for _, arg := range args {
+			s, err := toString(arg)
+			if err != nil {
+				return nil, err
+			}
+			sb.WriteString(s)
+		}
+		return sb.String(), nil
+	},
+	"copyIndex": func(env *Env, args []interface{}) (interface{}, error) {
+		switch len(args) {
+		case 0:
+			return env.CopyIndex, nil
+		case 1:
+			offset, ok := args[0].(int)
+			if !ok {
+				return nil, fmt.Errorf("offset must be an integer")
+			}
+			return env.CopyIndex + offset, nil
+		}
+		return nil, fmt.Errorf("expected at most 1 argument")
+	},
+	"parameters": func(env *Env, args []interface{}) (interface{}, error) {
+		names, err := stringArgs(args, 1, 1)
+		if err != nil {
+			return nil, err
+		}
+		v, found := env.Parameters[names[0]]
+		if !found {
+			return nil, fmt.Errorf("parameter %q not found", names[0])
+		}
+		return v, nil
+	},
+	"resourceId": func(env *Env, args []interface{}) (interface{}, error) {
+		return resourceID("/subscriptions/"+env.SubscriptionID+"/resourceGroups/"+env.ResourceGroupName, args)
+	},
+	"subscriptionResourceId": func(env *Env, args []interface{}) (interface{}, error) {
+		return resourceID("/subscriptions/"+env.SubscriptionID, args)
+	},
+	"resourceGroup": func(env *Env, args []interface{}) (interface{}, error) {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("expected no arguments")
+		}
+		return map[string]interface{}{
+			"id":       "/subscriptions/" + env.SubscriptionID + "/resourceGroups/" + env.ResourceGroupName,
+			"name":     env.ResourceGroupName,
+			"location": env.Location,
+		}, nil
+	},
+	"listAccountSas": func(env *Env, args []interface{}) (interface{}, error) {
+		return map[string]interface{}{"accountSasToken": env.SASToken}, nil
+	},
+	"listServiceSas": func(env *Env, args []interface{}) (interface{}, error) {
+		return map[string]interface{}{"serviceSasToken": env.SASToken}, nil
+	},
+	"base64": func(env *Env, args []interface{}) (interface{}, error) {
+		s, err := stringArgs(args, 1, 1)
+		if err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.EncodeToString([]byte(s[0])), nil
+	},
+	"string": func(env *Env, args []interface{}) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("expected 1 argument")
+		}
+		return toString(args[0])
+	},
+	"guid": func(env *Env, args []interface{}) (interface{}, error) {
+		s, err := stringArgs(args, 1, -1)
+		if err != nil {
+			return nil, err
+		}
+		return uuid.NewV5(guidNamespace, strings.Join(s, "-")).String(), nil
+	},
+}
+
+// resourceID interleaves the resource type segments and names in args, e.g.
+// ('Microsoft.Network/loadBalancers/backendAddressPools', 'lb', 'pool')
+// becomes <prefix>/providers/Microsoft.Network/loadBalancers/lb/backendAddressPools/pool
+func resourceID(prefix string, args []interface{}) (interface{}, error) {
+	s, err := stringArgs(args, 2, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	types := strings.Split(s[0], "/")
+	names := s[1:]
+	if len(types) != len(names)+1 {
+		return nil, fmt.Errorf("resource type %q needs %d names, got %d", s[0], len(types)-1, len(names))
+	}
+
+	id := prefix + "/providers/" + types[0]
+	for i, name := range names {
+		id += "/" + types[i+1] + "/" + name
+	}
+
+	return id, nil
+}
+
+func stringArgs(args []interface{}, min, max int) ([]string, error) {
+	if len(args) < min || max >= 0 && len(args) > max {
+		return nil, fmt.Errorf("unexpected number of arguments %d", len(args))
+	}
+
+	s := make([]string, 0, len(args))
+	for _, arg := range args {
+		v, ok := arg.(string)
+		if !ok {
+			return nil, fmt.Errorf("argument %v is not a string", arg)
+		}
+		s = append(s, v)
+	}
+
+	return s, nil
+}
+
+func toString(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case int:
+		return fmt.Sprint(v), nil
+	}
+
+	b, err := json.Marshal(v)
+	return string(b), err
+}
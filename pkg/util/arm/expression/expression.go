@@ -0,0 +1,193 @@
+package expression
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Expression is a typed ARM template expression.  Build expressions with the
+// constructors in this package rather than by formatting strings, so that
+// string literals are always quoted and escaped correctly, then use Format to
+// get the template string.
+type Expression interface {
+	// String returns the expression without the enclosing brackets, e.g.
+	// concat('a', copyIndex())
+	String() string
+
+	eval(env *Env) (interface{}, error)
+}
+
+// Format returns e as an ARM template string value, e.g.
+// [concat('a', copyIndex())].  A literal is returned as is, except that a
+// leading bracket is escaped so that ARM does not evaluate it.
+func Format(e Expression) string {
+	if l, ok := e.(literal); ok {
+		if strings.HasPrefix(string(l), "[") && strings.HasSuffix(string(l), "]") {
+			return "[" + string(l)
+		}
+		return string(l)
+	}
+
+	return "[" + e.String() + "]"
+}
+
+// FormatPtr returns a pointer to Format(e), for use in SDK types
+func FormatPtr(e Expression) *string {
+	s := Format(e)
+	return &s
+}
+
+type literal string
+
+func (l literal) String() string {
+	return "'" + Escape(string(l)) + "'"
+}
+
+type integer int
+
+func (i integer) String() string {
+	return strconv.Itoa(int(i))
+}
+
+type call struct {
+	name string
+	args []Expression
+}
+
+func (c *call) String() string {
+	args := make([]string, 0, len(c.args))
+	for _, arg := range c.args {
+		args = append(args, arg.String())
+	}
+
+	return c.name + "(" + strings.Join(args, ", ") + ")"
+}
+
+type property struct {
+	e    Expression
+	name string
+}
+
+func (p *property) String() string {
+	return p.e.String() + "." + p.name
+}
+
+// Escape escapes s for use inside a single-quoted ARM string literal
+func Escape(s string) string {
+	return strings.ReplaceAll(s, "'", "''")
+}
+
+// Literal returns the string literal s
+func Literal(s string) Expression {
+	return literal(s)
+}
+
+// Int returns the integer literal i
+func Int(i int) Expression {
+	return integer(i)
+}
+
+// Call returns a call of the ARM template function name.  Prefer the typed
+// constructors below where one exists.
+func Call(name string, args ...Expression) Expression {
+	return &call{name: name, args: args}
+}
+
+// Property returns the property name of the object e, e.g.
+// resourceGroup().id
+func Property(e Expression, name string) Expression {
+	return &property{e: e, name: name}
+}
+
+// Concat returns concat(args...)
+func Concat(args ...Expression) Expression {
+	return Call("concat", args...)
+}
+
+// CopyIndex returns copyIndex(), or copyIndex(offset) if offset is not 0
+func CopyIndex(offset int) Expression {
+	if offset == 0 {
+		return Call("copyIndex")
+	}
+	return Call("copyIndex", Int(offset))
+}
+
+// Parameters returns parameters('name')
+func Parameters(name string) Expression {
+	return Call("parameters", Literal(name))
+}
+
+// ResourceID returns resourceId('resourceType', names...)
+func ResourceID(resourceType string, names ...Expression) Expression {
+	return Call("resourceId", append([]Expression{Literal(resourceType)}, names...)...)
+}
+
+// SubscriptionResourceID returns
+// subscriptionResourceId('resourceType', names...)
+func SubscriptionResourceID(resourceType string, names ...Expression) Expression {
+	return Call("subscriptionResourceId", append([]Expression{Literal(resourceType)}, names...)...)
+}
+
+// ResourceGroup returns resourceGroup()
+func ResourceGroup() Expression {
+	return Call("resourceGroup")
+}
+
+// ResourceGroupID returns resourceGroup().id
+func ResourceGroupID() Expression {
+	return Property(ResourceGroup(), "id")
+}
+
+// ListAccountSas returns
+// listAccountSas(resourceID, 'apiVersion', parameters).accountSasToken
+func ListAccountSas(resourceID Expression, apiVersion string, parameters Expression) Expression {
+	return Property(Call("listAccountSas", resourceID, Literal(apiVersion), parameters), "accountSasToken")
+}
+
+// ListServiceSas returns
+// listServiceSas(resourceID, 'apiVersion', parameters).serviceSasToken
+func ListServiceSas(resourceID Expression, apiVersion string, parameters Expression) Expression {
+	return Property(Call("listServiceSas", resourceID, Literal(apiVersion), parameters), "serviceSasToken")
+}
+
+// Base64 returns base64(e)
+func Base64(e Expression) Expression {
+	return Call("base64", e)
+}
+
+// ToString returns string(e)
+func ToString(e Expression) Expression {
+	return Call("string", e)
+}
+
+// GUID returns guid(args...)
+func GUID(args ...Expression) Expression {
+	return Call("guid", args...)
+}
+
+// UniqueString returns uniqueString(args...)
+func UniqueString(args ...Expression) Expression {
+	return Call("uniqueString", args...)
+}
+
+// LiteralValue returns the value of e if it is a string literal
+func LiteralValue(e Expression) (string, bool) {
+	l, ok := e.(literal)
+	return string(l), ok
+}
+
+// Walk calls fn for each function call in e, outermost first
+func Walk(e Expression, fn func(name string, args []Expression)) {
+	switch e := e.(type) {
+	case *call:
+		fn(e.name, e.args)
+		for _, arg := range e.args {
+			Walk(arg, fn)
+		}
+	case *property:
+		Walk(e.e, fn)
+	}
+}
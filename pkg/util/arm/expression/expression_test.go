@@ -0,0 +1,198 @@
+package expression
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpression(t *testing.T) {
+	env := &Env{
+		SubscriptionID:    "sub",
+		ResourceGroupName: "rg",
+		Location:          "eastus",
+		CopyIndex:         2,
+		Parameters: map[string]interface{}{
+			"sas": map[string]interface{}{"signedPermission": "r"},
+		},
+		SASToken: "sv=2019-04-01&sig=x",
+	}
+
+	for _, tt := range []struct {
+		name       string
+		e          Expression
+		wantFormat string
+		want       interface{}
+		wantErr    string
+	}{
+		{
+			name:       "literal",
+			e:          Literal("it's"),
+			wantFormat: "it's",
+			want:       "it's",
+		},
+		{
+			name:       "bracketed literal is escaped",
+			e:          Literal("[not an expression]"),
+			wantFormat: "[[not an expression]",
+			want:       "[not an expression]",
+		},
+		{
+			name:       "concat with copyIndex",
+			e:          Concat(Literal("infra-master"), CopyIndex(0), Literal("-nic")),
+			wantFormat: "[concat('infra-master', copyIndex(), '-nic')]",
+			want:       "infra-master2-nic",
+		},
+		{
+			name:       "string of copyIndex with offset",
+			e:          ToString(CopyIndex(1)),
+			wantFormat: "[string(copyIndex(1))]",
+			want:       "3",
+		},
+		{
+			name:       "quotes are escaped",
+			e:          Concat(Literal(`{"a":"it's"}`), Literal("'")),
+			wantFormat: `[concat('{"a":"it''s"}', '''')]`,
+			want:       `{"a":"it's"}'`,
+		},
+		{
+			name:       "nested resourceId",
+			e:          ResourceID("Microsoft.Network/loadBalancers/backendAddressPools", Literal("infra-internal"), Concat(Literal("ssh-"), CopyIndex(0))),
+			wantFormat: "[resourceId('Microsoft.Network/loadBalancers/backendAddressPools', 'infra-internal', concat('ssh-', copyIndex()))]",
+			want:       "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/loadBalancers/infra-internal/backendAddressPools/ssh-2",
+		},
+		{
+			name:       "subscriptionResourceId",
+			e:          SubscriptionResourceID("Microsoft.Authorization/roleDefinitions", Literal("acdd72a7-3385-48ef-bd42-f606fba81ae7")),
+			wantFormat: "[subscriptionResourceId('Microsoft.Authorization/roleDefinitions', 'acdd72a7-3385-48ef-bd42-f606fba81ae7')]",
+			want:       "/subscriptions/sub/providers/Microsoft.Authorization/roleDefinitions/acdd72a7-3385-48ef-bd42-f606fba81ae7",
+		},
+		{
+			name:       "base64 of a SAS pointer",
+			e:          Base64(Concat(Literal("https://cluster/ignition/master.ign?"), ListServiceSas(ResourceID("Microsoft.Storage/storageAccounts", Literal("cluster")), "2019-04-01", Parameters("sas")))),
+			wantFormat: "[base64(concat('https://cluster/ignition/master.ign?', listServiceSas(resourceId('Microsoft.Storage/storageAccounts', 'cluster'), '2019-04-01', parameters('sas')).serviceSasToken))]",
+			want:       "aHR0cHM6Ly9jbHVzdGVyL2lnbml0aW9uL21hc3Rlci5pZ24/c3Y9MjAxOS0wNC0wMSZzaWc9eA==",
+		},
+		{
+			name:       "listAccountSas",
+			e:          ListAccountSas(ResourceID("Microsoft.Storage/storageAccounts", Literal("cluster")), "2019-04-01", Parameters("sas")),
+			wantFormat: "[listAccountSas(resourceId('Microsoft.Storage/storageAccounts', 'cluster'), '2019-04-01', parameters('sas')).accountSasToken]",
+			want:       "sv=2019-04-01&sig=x",
+		},
+		{
+			name:       "guid",
+			e:          GUID(ResourceGroupID(), Literal("role")),
+			wantFormat: "[guid(resourceGroup().id, 'role')]",
+			want:       "c5c2114f-7e90-5b02-9d21-4fcd060e72a7",
+		},
+		{
+			name:       "missing parameter",
+			e:          Parameters("missing"),
+			wantFormat: "[parameters('missing')]",
+			wantErr:    `parameters('missing'): parameter "missing" not found`,
+		},
+		{
+			name:       "resourceId with too few names",
+			e:          ResourceID("Microsoft.Network/loadBalancers/backendAddressPools", Literal("lb")),
+			wantFormat: "[resourceId('Microsoft.Network/loadBalancers/backendAddressPools', 'lb')]",
+			wantErr:    `resourceId('Microsoft.Network/loadBalancers/backendAddressPools', 'lb'): resource type "Microsoft.Network/loadBalancers/backendAddressPools" needs 2 names, got 1`,
+		},
+		{
+			name:       "uniqueString is not supported",
+			e:          UniqueString(ResourceGroupID()),
+			wantFormat: "[uniqueString(resourceGroup().id)]",
+			wantErr:    `uniqueString(resourceGroup().id): unsupported function "uniqueString"`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			format := Format(tt.e)
+			if format != tt.wantFormat {
+				t.Errorf("got format %q", format)
+			}
+
+			got, err := Evaluate(tt.e, env)
+			if err != nil && err.Error() != tt.wantErr ||
+				err == nil && tt.wantErr != "" {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) && tt.wantErr == "" {
+				t.Errorf("got %#v", got)
+			}
+
+			// the formatted expression must parse back to the same expression
+			parsed, err := Parse(format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(parsed, tt.e) {
+				t.Errorf("got parsed %s", parsed)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		s       string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "whitespace is ignored",
+			s:    "[concat( 'a' ,copyIndex( ) )]",
+			want: "concat('a', copyIndex())",
+		},
+		{
+			name: "not bracketed",
+			s:    "[a",
+			want: "'[a'",
+		},
+		{
+			name: "negative integer",
+			s:    "[copyIndex(-1)]",
+			want: "copyIndex(-1)",
+		},
+		{
+			name:    "unterminated string",
+			s:       "[concat('a)]",
+			wantErr: `parsing "[concat('a)]": unterminated string at offset 7`,
+		},
+		{
+			name: "missing closing bracket",
+			s:    "[concat('a'",
+			want: "'[concat(''a'''",
+		},
+		{
+			name:    "unbalanced parenthesis",
+			s:       "[concat('a']",
+			wantErr: `parsing "[concat('a']": expected ')' at offset 10`,
+		},
+		{
+			name:    "trailing input",
+			s:       "[copyIndex() copyIndex()]",
+			wantErr: `parsing "[copyIndex() copyIndex()]": unexpected "copyIndex()" at offset 12`,
+		},
+		{
+			name:    "missing property name",
+			s:       "[resourceGroup().]",
+			wantErr: `parsing "[resourceGroup().]": expected property name at offset 16`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := Parse(tt.s)
+			if err != nil && err.Error() != tt.wantErr ||
+				err == nil && tt.wantErr != "" {
+				t.Fatal(err)
+			}
+
+			if err == nil && e.String() != tt.want {
+				t.Errorf("got %s", e)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package expression
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parse parses the ARM template string value s.  If s is not bracketed, or
+// its leading bracket is escaped, it is returned as a literal.
+func Parse(s string) (Expression, error) {
+	if !strings.HasPrefix(s, "[") || !strings.HasSuffix(s, "]") {
+		return Literal(s), nil
+	}
+
+	if strings.HasPrefix(s, "[[") {
+		return Literal(s[1:]), nil
+	}
+
+	p := &parser{s: s[1 : len(s)-1]}
+
+	e, err := p.expression()
+	if err != nil {
+		return nil, fmt.Errorf("parsing %q: %w", s, err)
+	}
+
+	p.skipSpace()
+	if p.pos != len(p.s) {
+		return nil, fmt.Errorf("parsing %q: unexpected %q at offset %d", s, p.s[p.pos:], p.pos)
+	}
+
+	return e, nil
+}
+
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *parser) peek() byte {
+	p.skipSpace()
+	if p.pos == len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *parser) expect(c byte) error {
+	if p.peek() != c {
+		return fmt.Errorf("expected %q at offset %d", c, p.pos)
+	}
+	p.pos++
+	return nil
+}
+
+// expression := primary ('.' identifier)*
+func (p *parser) expression() (Expression, error) {
+	e, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek() == '.' {
+		p.pos++
+		name := p.identifier()
+		if name == "" {
+			return nil, fmt.Errorf("expected property name at offset %d", p.pos)
+		}
+		e = Property(e, name)
+	}
+
+	return e, nil
+}
+
+// primary := string | integer | identifier '(' [expression (',' expression)*] ')'
+func (p *parser) primary() (Expression, error) {
+	switch c := p.peek(); {
+	case c == '\'':
+		return p.literal()
+	case c == '-' || c >= '0' && c <= '9':
+		return p.integer()
+	}
+
+	name := p.identifier()
+	if name == "" {
+		return nil, fmt.Errorf("expected expression at offset %d", p.pos)
+	}
+
+	err := p.expect('(')
+	if err != nil {
+		return nil, err
+	}
+
+	var args []Expression
+	if p.peek() != ')' {
+		for {
+			arg, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+
+			if p.peek() != ',' {
+				break
+			}
+			p.pos++
+		}
+	}
+
+	err = p.expect(')')
+	if err != nil {
+		return nil, err
+	}
+
+	return Call(name, args...), nil
+}
+
+func (p *parser) literal() (Expression, error) {
+	start := p.pos
+	p.pos++ // opening quote
+
+	var sb strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+
+		if c != '\'' {
+			sb.WriteByte(c)
+			continue
+		}
+
+		// '' is an escaped quote
+		if p.pos < len(p.s) && p.s[p.pos] == '\'' {
+			sb.WriteByte('\'')
+			p.pos++
+			continue
+		}
+
+		return Literal(sb.String()), nil
+	}
+
+	return nil, fmt.Errorf("unterminated string at offset %d", start)
+}
+
+func (p *parser) integer() (Expression, error) {
+	start := p.pos
+	if p.s[p.pos] == '-' {
+		p.pos++
+	}
+	for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		p.pos++
+	}
+
+	i, err := strconv.Atoi(p.s[start:p.pos])
+	if err != nil {
+		return nil, fmt.Errorf("invalid integer at offset %d", start)
+	}
+
+	return Int(i), nil
+}
+
+func (p *parser) identifier() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (p.pos == start || c < '0' || c > '9') {
+			break
+		}
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
@@ -7,13 +7,21 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"strings"
 
+	mgmtcompute "github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2020-06-01/compute"
+	mgmtnetwork "github.com/Azure/azure-sdk-for-go/services/network/mgmt/2020-08-01/network"
+	mgmtauthorization "github.com/Azure/azure-sdk-for-go/services/preview/authorization/mgmt/2018-09-01-preview/authorization"
 	"github.com/gofrs/uuid"
 )
 
 // MarshalJSON marshals the nested r.Resource ignoring any MarshalJSON() methods
 // on its types.  It then merges remaining fields of r over the result
 func (r *Resource) MarshalJSON() ([]byte, error) {
+	if m, ok := r.Resource.(map[string]interface{}); ok {
+		return r.marshalMap(m)
+	}
+
 	resource := reflect.ValueOf(shadowCopy(r.Resource))
 	outer := reflect.ValueOf(*r)
 
@@ -62,9 +70,69 @@ func (r *Resource) MarshalJSON() ([]byte, error) {
 	return json.Marshal(combined.Interface())
 }
 
-// UnmarshalJSON is not implemented
+// marshalMap marshals the generic resource m, with the non-zero fields of r
+// merged over it.  Resources of unknown types are unmarshalled this way.
+func (r *Resource) marshalMap(m map[string]interface{}) ([]byte, error) {
+	combined := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		combined[k] = v
+	}
+
+	outer := reflect.ValueOf(*r)
+	for i := 1; i < outer.NumField(); i++ {
+		if outer.Field(i).IsZero() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(outer.Type().Field(i).Tag.Get("json"), ",")
+		combined[name] = outer.Field(i).Interface()
+	}
+
+	return json.Marshal(combined)
+}
+
+// resourceTypes maps the lower cased resource types which are unmarshalled
+// into typed resources to a constructor of their SDK type
+var resourceTypes = map[string]func() interface{}{
+	"microsoft.authorization/roleassignments": func() interface{} { return &mgmtauthorization.RoleAssignment{} },
+	"microsoft.compute/virtualmachines":       func() interface{} { return &mgmtcompute.VirtualMachine{} },
+	"microsoft.network/networkinterfaces":     func() interface{} { return &mgmtnetwork.Interface{} },
+}
+
+// UnmarshalJSON unmarshals the fields of r, and r.Resource into the SDK type
+// of the resource type if it is known, otherwise into a
+// map[string]interface{}.  The fields of r are unmarshalled into both, which
+// is harmless: they are identical when marshalled again.
 func (r *Resource) UnmarshalJSON(b []byte) error {
-	return fmt.Errorf("not implemented")
+	// resource has the fields of Resource but not its methods
+	type resource Resource
+
+	var outer resource
+	err := json.Unmarshal(b, &outer)
+	if err != nil {
+		return err
+	}
+
+	var inner interface{}
+	if f, found := resourceTypes[strings.ToLower(outer.Type)]; found {
+		inner = f()
+	} else {
+		inner = &map[string]interface{}{}
+	}
+
+	err = json.Unmarshal(b, inner)
+	if err != nil {
+		return err
+	}
+
+	if m, ok := inner.(*map[string]interface{}); ok {
+		inner = *m
+	}
+
+	*r = Resource(outer)
+	r.Resource = inner
+
+	return nil
 }
 
 var (
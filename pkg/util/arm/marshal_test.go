@@ -7,8 +7,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"reflect"
 	"testing"
 
+	mgmtcompute "github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2020-06-01/compute"
+	mgmtnetwork "github.com/Azure/azure-sdk-for-go/services/network/mgmt/2020-08-01/network"
+	mgmtauthorization "github.com/Azure/azure-sdk-for-go/services/preview/authorization/mgmt/2018-09-01-preview/authorization"
 	"github.com/Azure/go-autorest/autorest/to"
 )
 
@@ -136,3 +140,163 @@ type testResource struct {
 func (r *testResource) MarshalJSON() ([]byte, error) {
 	return nil, fmt.Errorf("should not be called")
 }
+
+func TestResourceRoundTrip(t *testing.T) {
+	template := &Template{
+		Schema:         "https://schema.management.azure.com/schemas/2015-01-01/deploymentTemplate.json#",
+		ContentVersion: "1.0.0.0",
+		Parameters: map[string]*TemplateParameter{
+			"sas": {Type: "object"},
+		},
+		Resources: []*Resource{
+			{
+				Resource: &mgmtnetwork.Interface{
+					InterfacePropertiesFormat: &mgmtnetwork.InterfacePropertiesFormat{
+						IPConfigurations: &[]mgmtnetwork.InterfaceIPConfiguration{
+							{
+								InterfaceIPConfigurationPropertiesFormat: &mgmtnetwork.InterfaceIPConfigurationPropertiesFormat{
+									Subnet: &mgmtnetwork.Subnet{
+										ID: to.StringPtr("subnetID"),
+									},
+								},
+								Name: to.StringPtr("pipConfig"),
+							},
+						},
+					},
+					Name:     to.StringPtr("[concat('infra-master', copyIndex(), '-nic')]"),
+					Type:     to.StringPtr("Microsoft.Network/networkInterfaces"),
+					Location: to.StringPtr("eastus"),
+				},
+				APIVersion: "2020-08-01",
+				Copy: &Copy{
+					Name:  "networkcopy",
+					Count: 3,
+				},
+			},
+			{
+				Resource: &mgmtcompute.VirtualMachine{
+					VirtualMachineProperties: &mgmtcompute.VirtualMachineProperties{
+						HardwareProfile: &mgmtcompute.HardwareProfile{
+							VMSize: mgmtcompute.VirtualMachineSizeTypesStandardD8sV3,
+						},
+						OsProfile: &mgmtcompute.OSProfile{
+							CustomData: to.StringPtr("[base64(parameters('sas'))]"),
+						},
+					},
+					Zones:    &[]string{"[string(copyIndex(1))]"},
+					Name:     to.StringPtr("[concat('infra-master-', copyIndex())]"),
+					Type:     to.StringPtr("Microsoft.Compute/virtualMachines"),
+					Location: to.StringPtr("eastus"),
+				},
+				APIVersion: "2020-06-01",
+				Copy: &Copy{
+					Name:  "computecopy",
+					Count: 3,
+				},
+				DependsOn: []string{
+					"[concat('Microsoft.Network/networkInterfaces/infra-master', copyIndex(), '-nic')]",
+				},
+			},
+			{
+				Resource: mgmtauthorization.RoleAssignment{
+					Name: to.StringPtr("[guid(resourceGroup().id, 'role')]"),
+					Type: to.StringPtr("Microsoft.Authorization/roleAssignments"),
+					RoleAssignmentPropertiesWithScope: &mgmtauthorization.RoleAssignmentPropertiesWithScope{
+						Scope:            to.StringPtr("[resourceGroup().id]"),
+						RoleDefinitionID: to.StringPtr("[subscriptionResourceId('Microsoft.Authorization/roleDefinitions', 'acdd72a7-3385-48ef-bd42-f606fba81ae7')]"),
+						PrincipalID:      to.StringPtr("principalID"),
+					},
+				},
+				APIVersion: "2018-09-01-preview",
+			},
+			{
+				Resource: &testResource{
+					Name: "nested",
+				},
+				Type:       "Microsoft.Resources/deployments",
+				APIVersion: "2021-04-01",
+				Condition:  true,
+				Tags: map[string]interface{}{
+					"tag": "value",
+				},
+			},
+		},
+	}
+
+	b, err := json.MarshalIndent(template, "", "    ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got *Template
+	err = json.Unmarshal(b, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []interface{}{
+		&mgmtnetwork.Interface{},
+		&mgmtcompute.VirtualMachine{},
+		&mgmtauthorization.RoleAssignment{},
+		map[string]interface{}{},
+	} {
+		if reflect.TypeOf(got.Resources[i].Resource) != reflect.TypeOf(want) {
+			t.Errorf("resources[%d] is %T, want %T", i, got.Resources[i].Resource, want)
+		}
+	}
+
+	vm := got.Resources[1].Resource.(*mgmtcompute.VirtualMachine)
+	if vm.VirtualMachineProperties == nil || vm.OsProfile == nil || to.String(vm.OsProfile.CustomData) != "[base64(parameters('sas'))]" {
+		t.Errorf("virtual machine properties were not unmarshalled: %#v", vm)
+	}
+
+	if got.Resources[1].Copy.Name != "computecopy" || len(got.Resources[1].DependsOn) != 1 {
+		t.Errorf("resource fields were not unmarshalled: %#v", got.Resources[1])
+	}
+
+	b2, err := json.MarshalIndent(got, "", "    ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// resources of unknown types are unmarshalled into maps, whose keys are
+	// marshalled in sorted order, so only the JSON values can be compared
+	var want, gotValue interface{}
+	err = json.Unmarshal(b, &want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = json.Unmarshal(b2, &gotValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(gotValue, want) {
+		t.Errorf("round trip changed the template:\n%s\n%s", string(b), string(b2))
+	}
+
+	// from then on, round trips are byte for byte stable
+	var got2 *Template
+	err = json.Unmarshal(b2, &got2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b3, err := json.MarshalIndent(got2, "", "    ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b2, b3) {
+		t.Errorf("round trip is not stable:\n%s\n%s", string(b2), string(b3))
+	}
+}
+
+func TestResourceUnmarshalInvalid(t *testing.T) {
+	var r *Resource
+	err := json.Unmarshal([]byte(`{"type": "Microsoft.Compute/virtualMachines", "properties": "invalid"}`), &r)
+	if err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,181 @@
+package arm
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/util/azureclient/mgmt/features"
+)
+
+// progressInterval is how often the operations of a running deployment are
+// polled
+var progressInterval = 10 * time.Second
+
+// ResourceProgress records the provisioning of a resource by a deployment
+type ResourceProgress struct {
+	ResourceType      string
+	ResourceName      string
+	ProvisioningState string
+
+	// Duration is ARM's view of how long the resource's operation took
+	Duration string
+
+	// FirstSeen and LastTransition are when the watcher first saw the
+	// resource and last saw its provisioning state change
+	FirstSeen      time.Time
+	LastTransition time.Time
+}
+
+// progressWatcher polls the operations of a running deployment and logs each
+// resource's provisioning state transitions
+type progressWatcher struct {
+	log               *logrus.Entry
+	operations        features.DeploymentOperationsClient
+	resourceGroupName string
+	deploymentName    string
+	now               func() time.Time
+
+	resources map[string]*ResourceProgress
+	order     []string
+}
+
+func newProgressWatcher(log *logrus.Entry, operations features.DeploymentOperationsClient, resourceGroupName, deploymentName string) *progressWatcher {
+	return &progressWatcher{
+		log:               log,
+		operations:        operations,
+		resourceGroupName: resourceGroupName,
+		deploymentName:    deploymentName,
+		now:               time.Now,
+		resources:         map[string]*ResourceProgress{},
+	}
+}
+
+// run polls until ctx is cancelled.  Polling errors are only logged: progress
+// reporting must never fail a deployment.
+func (w *progressWatcher) run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			err := w.poll(ctx)
+			if err != nil && ctx.Err() == nil {
+				w.log.Debugf("listing %s template operations: %v", w.deploymentName, err)
+			}
+		}
+	}
+}
+
+// poll lists the deployment operations once and logs any resource whose
+// provisioning state has changed since the previous poll
+func (w *progressWatcher) poll(ctx context.Context) error {
+	operations, err := w.operations.List(ctx, w.resourceGroupName, w.deploymentName)
+	if err != nil {
+		return err
+	}
+
+	now := w.now()
+
+	for _, op := range operations {
+		if op.Properties == nil || op.Properties.TargetResource == nil || op.Properties.TargetResource.ID == nil {
+			continue
+		}
+
+		key := strings.ToLower(*op.Properties.TargetResource.ID)
+		state := to.String(op.Properties.ProvisioningState)
+
+		r, found := w.resources[key]
+		if !found {
+			r = &ResourceProgress{
+				ResourceType: to.String(op.Properties.TargetResource.ResourceType),
+				ResourceName: to.String(op.Properties.TargetResource.ResourceName),
+				FirstSeen:    now,
+			}
+			w.resources[key] = r
+			w.order = append(w.order, key)
+		}
+
+		r.Duration = to.String(op.Properties.Duration)
+
+		if r.ProvisioningState == state {
+			continue
+		}
+
+		if r.ProvisioningState == "" {
+			w.log.Printf("%s template: %s %s is %s", w.deploymentName, r.ResourceType, r.ResourceName, state)
+		} else {
+			w.log.Printf("%s template: %s %s is %s after %s in %s", w.deploymentName, r.ResourceType, r.ResourceName, state, now.Sub(r.LastTransition).Round(time.Second), r.ProvisioningState)
+		}
+
+		r.ProvisioningState = state
+		r.LastTransition = now
+	}
+
+	return nil
+}
+
+// summary returns the progress of each resource seen, in the order the
+// resources were first seen
+func (w *progressWatcher) summary() []*ResourceProgress {
+	summary := make([]*ResourceProgress, 0, len(w.order))
+	for _, key := range w.order {
+		summary = append(summary, w.resources[key])
+	}
+
+	return summary
+}
+
+// logSummary logs the final state of each resource with structured fields,
+// so that it can be picked up from the log output
+func (w *progressWatcher) logSummary() {
+	for _, r := range w.summary() {
+		w.log.WithFields(logrus.Fields{
+			"deployment":        w.deploymentName,
+			"resourceType":      r.ResourceType,
+			"resourceName":      r.ResourceName,
+			"provisioningState": r.ProvisioningState,
+			"duration":          r.Duration,
+		}).Info("deployment resource summary")
+	}
+}
+
+// watchProgress runs a progressWatcher for the deployment while deploy runs,
+// then logs the per-resource summary.  If operations is nil, deploy is run
+// unwatched.
+func watchProgress(ctx context.Context, log *logrus.Entry, operations features.DeploymentOperationsClient, resourceGroupName, deploymentName string, deploy func() error) error {
+	if operations == nil {
+		return deploy()
+	}
+
+	w := newProgressWatcher(log, operations, resourceGroupName, deploymentName)
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.run(watchCtx, progressInterval)
+	}()
+
+	err := deploy()
+
+	cancel()
+	<-done
+
+	// catch the final states, which the watcher may not have seen yet
+	if pollErr := w.poll(ctx); pollErr != nil {
+		log.Debugf("listing %s template operations: %v", deploymentName, pollErr)
+	}
+	w.logSummary()
+
+	return err
+}
@@ -0,0 +1,153 @@
+package arm
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	mgmtfeatures "github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-07-01/features"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+
+	mock_features "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/azureclient/mgmt/features"
+)
+
+func operation(resourceType, resourceName, provisioningState, duration string) mgmtfeatures.DeploymentOperation {
+	return mgmtfeatures.DeploymentOperation{
+		Properties: &mgmtfeatures.DeploymentOperationProperties{
+			ProvisioningState: to.StringPtr(provisioningState),
+			Duration:          to.StringPtr(duration),
+			TargetResource: &mgmtfeatures.TargetResource{
+				ID:           to.StringPtr("/subscriptions/sub/resourceGroups/rg/providers/" + resourceType + "/" + resourceName),
+				ResourceType: to.StringPtr(resourceType),
+				ResourceName: to.StringPtr(resourceName),
+			},
+		},
+	}
+}
+
+func TestProgressWatcherPoll(t *testing.T) {
+	ctx := context.Background()
+
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	operations := mock_features.NewMockDeploymentOperationsClient(controller)
+	gomock.InOrder(
+		operations.EXPECT().List(ctx, "rg", deploymentName).Return([]mgmtfeatures.DeploymentOperation{
+			operation("Microsoft.Network/loadBalancers", "lb", "Running", "PT1S"),
+			{}, // operations without a target resource are ignored
+		}, nil),
+		operations.EXPECT().List(ctx, "rg", deploymentName).Return([]mgmtfeatures.DeploymentOperation{
+			operation("Microsoft.Network/loadBalancers", "lb", "Running", "PT11S"),
+			operation("Microsoft.Compute/virtualMachines", "vm", "Running", "PT1S"),
+		}, nil),
+		operations.EXPECT().List(ctx, "rg", deploymentName).Return([]mgmtfeatures.DeploymentOperation{
+			operation("Microsoft.Network/loadBalancers", "lb", "Succeeded", "PT21S"),
+			operation("Microsoft.Compute/virtualMachines", "vm", "Failed", "PT11S"),
+		}, nil),
+		operations.EXPECT().List(ctx, "rg", deploymentName).Return(nil, errors.New("random error")),
+	)
+
+	logger, hook := test.NewNullLogger()
+
+	start := time.Unix(0, 0)
+	now := start
+
+	w := newProgressWatcher(logrus.NewEntry(logger), operations, "rg", deploymentName)
+	w.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		err := w.poll(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(10 * time.Second)
+	}
+
+	err := w.poll(ctx)
+	if err == nil || err.Error() != "random error" {
+		t.Error(err)
+	}
+
+	var messages []string
+	for _, e := range hook.AllEntries() {
+		messages = append(messages, e.Message)
+	}
+
+	wantMessages := []string{
+		"test template: Microsoft.Network/loadBalancers lb is Running",
+		"test template: Microsoft.Compute/virtualMachines vm is Running",
+		"test template: Microsoft.Network/loadBalancers lb is Succeeded after 20s in Running",
+		"test template: Microsoft.Compute/virtualMachines vm is Failed after 10s in Running",
+	}
+	if !reflect.DeepEqual(messages, wantMessages) {
+		t.Errorf("got messages %#v", messages)
+	}
+
+	wantSummary := []*ResourceProgress{
+		{
+			ResourceType:      "Microsoft.Network/loadBalancers",
+			ResourceName:      "lb",
+			ProvisioningState: "Succeeded",
+			Duration:          "PT21S",
+			FirstSeen:         start,
+			LastTransition:    start.Add(20 * time.Second),
+		},
+		{
+			ResourceType:      "Microsoft.Compute/virtualMachines",
+			ResourceName:      "vm",
+			ProvisioningState: "Failed",
+			Duration:          "PT11S",
+			FirstSeen:         start.Add(10 * time.Second),
+			LastTransition:    start.Add(20 * time.Second),
+		},
+	}
+	if !reflect.DeepEqual(w.summary(), wantSummary) {
+		t.Errorf("got summary %#v", w.summary())
+	}
+}
+
+func TestWatchProgress(t *testing.T) {
+	ctx := context.Background()
+
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	operations := mock_features.NewMockDeploymentOperationsClient(controller)
+	operations.EXPECT().List(gomock.Any(), "rg", deploymentName).Return([]mgmtfeatures.DeploymentOperation{
+		operation("Microsoft.Network/loadBalancers", "lb", "Succeeded", "PT21S"),
+	}, nil).MinTimes(1)
+
+	logger, hook := test.NewNullLogger()
+
+	err := watchProgress(ctx, logrus.NewEntry(logger), operations, "rg", deploymentName, func() error {
+		return errors.New("deployment failed")
+	})
+	if err == nil || err.Error() != "deployment failed" {
+		t.Error(err)
+	}
+
+	e := hook.LastEntry()
+	if e == nil || e.Message != "deployment resource summary" {
+		t.Fatalf("got last entry %v", e)
+	}
+
+	wantFields := logrus.Fields{
+		"deployment":        deploymentName,
+		"resourceType":      "Microsoft.Network/loadBalancers",
+		"resourceName":      "lb",
+		"provisioningState": "Succeeded",
+		"duration":          "PT21S",
+	}
+	if !reflect.DeepEqual(e.Data, wantFields) {
+		t.Errorf("got fields %#v", e.Data)
+	}
+}
@@ -0,0 +1,297 @@
+package arm
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/util/arm/expression"
+)
+
+// Validate checks the structure of t offline, so that mistakes which would
+// otherwise only show up at deployment time are caught early:
+//
+//   - every resource has a name, a type and an apiVersion
+//   - copy loops are named, and their names are unique
+//   - every expression parses, and references only declared parameters and
+//     variables
+//   - every dependsOn matches a resource or copy loop of the template
+//
+// Nested deployment templates are not validated.
+func Validate(t *Template) error {
+	v := &validator{
+		t:         t,
+		targets:   map[string]struct{}{},
+		copyNames: map[string]struct{}{},
+	}
+
+	resources, err := v.resources()
+	if err != nil {
+		return err
+	}
+
+	v.validateExpressions()
+	v.validateResources(resources)
+	v.validateDependsOn(resources)
+
+	if len(v.errs) > 0 {
+		return fmt.Errorf("invalid template: %s", strings.Join(v.errs, "; "))
+	}
+
+	return nil
+}
+
+// validatorEnv is the environment expressions are evaluated in, to resolve
+// resource names and dependsOn
+var validatorEnv = expression.Env{
+	SubscriptionID:    "subscription",
+	ResourceGroupName: "resourceGroup",
+}
+
+type validator struct {
+	t    *Template
+	errs []string
+
+	// targets holds each form a dependsOn may take for each resource: its
+	// name, its type/name and its resource ID, lower cased
+	targets   map[string]struct{}
+	copyNames map[string]struct{}
+}
+
+// resource is the marshalled form of a Resource, in which the fields of the
+// nested SDK type and the outer fields have been merged
+type resource struct {
+	Name       string   `json:"name,omitempty"`
+	Type       string   `json:"type,omitempty"`
+	APIVersion string   `json:"apiVersion,omitempty"`
+	DependsOn  []string `json:"dependsOn,omitempty"`
+	Copy       *Copy    `json:"copy,omitempty"`
+}
+
+func (v *validator) errorf(format string, a ...interface{}) {
+	v.errs = append(v.errs, fmt.Sprintf(format, a...))
+}
+
+func (v *validator) resources() ([]*resource, error) {
+	resources := make([]*resource, 0, len(v.t.Resources))
+	for _, r := range v.t.Resources {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return nil, err
+		}
+
+		var res *resource
+		err = json.Unmarshal(b, &res)
+		if err != nil {
+			return nil, err
+		}
+
+		resources = append(resources, res)
+	}
+
+	return resources, nil
+}
+
+// validateExpressions parses every string of the template and checks the
+// parameters and variables it references
+func (v *validator) validateExpressions() {
+	b, err := json.Marshal(v.t)
+	if err != nil {
+		v.errorf("%v", err)
+		return
+	}
+
+	var t struct {
+		Variables map[string]interface{} `json:"variables,omitempty"`
+		Resources []interface{}          `json:"resources,omitempty"`
+		Outputs   map[string]interface{} `json:"outputs,omitempty"`
+	}
+	err = json.Unmarshal(b, &t)
+	if err != nil {
+		v.errorf("%v", err)
+		return
+	}
+
+	// nested templates reference their own parameters and variables
+	for _, r := range t.Resources {
+		if r, ok := r.(map[string]interface{}); ok && strings.EqualFold(fmt.Sprint(r["type"]), "Microsoft.Resources/deployments") {
+			if properties, ok := r["properties"].(map[string]interface{}); ok {
+				delete(properties, "template")
+			}
+		}
+	}
+
+	walkStrings([]interface{}{t.Variables, t.Resources, t.Outputs}, func(s string) {
+		e, err := expression.Parse(s)
+		if err != nil {
+			v.errorf("%v", err)
+			return
+		}
+
+		expression.Walk(e, func(name string, args []expression.Expression) {
+			if len(args) == 0 {
+				return
+			}
+			arg, ok := expression.LiteralValue(args[0])
+			if !ok {
+				return
+			}
+
+			switch name {
+			case "parameters":
+				if _, found := v.t.Parameters[arg]; !found {
+					v.errorf("%q references undeclared parameter %q", s, arg)
+				}
+			case "variables":
+				if _, found := v.t.Variables[arg]; !found {
+					v.errorf("%q references undeclared variable %q", s, arg)
+				}
+			}
+		})
+	})
+}
+
+// walkStrings calls fn for each string in the unmarshalled JSON value i,
+// including map keys, in a stable order
+func walkStrings(i interface{}, fn func(string)) {
+	switch i := i.(type) {
+	case string:
+		fn(i)
+	case []interface{}:
+		for _, e := range i {
+			walkStrings(e, fn)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(i))
+		for k := range i {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			fn(k)
+			walkStrings(i[k], fn)
+		}
+	}
+}
+
+// validateResources checks the required fields and copy loops of each
+// resource, and records the forms a dependsOn on it may take
+func (v *validator) validateResources(resources []*resource) {
+	for i, r := range resources {
+		if r.Name == "" {
+			v.errorf("resources[%d] has no name", i)
+		}
+		if r.Type == "" {
+			v.errorf("resources[%d] has no type", i)
+		}
+		if r.APIVersion == "" {
+			v.errorf("resources[%d] has no apiVersion", i)
+		}
+
+		if r.Copy != nil {
+			if r.Copy.Name == "" {
+				v.errorf("resources[%d] has an unnamed copy loop", i)
+			} else if _, found := v.copyNames[strings.ToLower(r.Copy.Name)]; found {
+				v.errorf("resources[%d] copy loop name %q is not unique", i, r.Copy.Name)
+			} else {
+				v.copyNames[strings.ToLower(r.Copy.Name)] = struct{}{}
+			}
+		}
+
+		if r.Name == "" || r.Type == "" {
+			continue
+		}
+
+		// names which cannot be evaluated, e.g. using uniqueString(), can
+		// only be depended on verbatim
+		v.targets[strings.ToLower(r.Name)] = struct{}{}
+
+		for _, env := range copyEnvs(r) {
+			name, err := expression.EvaluateString(r.Name, env)
+			if err != nil {
+				continue
+			}
+			n, ok := name.(string)
+			if !ok {
+				continue
+			}
+
+			v.targets[strings.ToLower(n)] = struct{}{}
+			v.targets[strings.ToLower(r.Type+"/"+n)] = struct{}{}
+
+			names := make([]expression.Expression, 0, strings.Count(n, "/")+1)
+			for _, segment := range strings.Split(n, "/") {
+				names = append(names, expression.Literal(segment))
+			}
+
+			id, err := expression.Evaluate(expression.ResourceID(r.Type, names...), env)
+			if err == nil {
+				v.targets[strings.ToLower(id.(string))] = struct{}{}
+			}
+		}
+	}
+
+	for name := range v.copyNames {
+		if _, found := v.targets[name]; found {
+			v.errorf("copy loop name %q is also the name of a resource", name)
+		}
+	}
+}
+
+// validateDependsOn checks that each dependsOn of each resource, evaluated
+// for each iteration of its copy loop, matches a resource or copy loop
+func (v *validator) validateDependsOn(resources []*resource) {
+	for i, r := range resources {
+		for _, dependsOn := range r.DependsOn {
+			if _, found := v.copyNames[strings.ToLower(dependsOn)]; found {
+				continue
+			}
+
+			if _, found := v.targets[strings.ToLower(dependsOn)]; found {
+				continue
+			}
+
+			for _, env := range copyEnvs(r) {
+				target, err := expression.EvaluateString(dependsOn, env)
+				if err != nil {
+					v.errorf("resources[%d] dependsOn %q: %v", i, dependsOn, err)
+					break
+				}
+
+				t, ok := target.(string)
+				if !ok {
+					v.errorf("resources[%d] dependsOn %q is not a string", i, dependsOn)
+					break
+				}
+
+				if _, found := v.targets[strings.ToLower(t)]; !found {
+					v.errorf("resources[%d] dependsOn %q does not match a resource in the template", i, dependsOn)
+					break
+				}
+			}
+		}
+	}
+}
+
+// copyEnvs returns an evaluation environment for each iteration of the copy
+// loop of r, or a single environment if r has no copy loop
+func copyEnvs(r *resource) []*expression.Env {
+	count := 1
+	if r.Copy != nil && r.Copy.Count > 1 {
+		count = r.Copy.Count
+	}
+
+	envs := make([]*expression.Env, 0, count)
+	for i := 0; i < count; i++ {
+		env := validatorEnv
+		env.CopyIndex = i
+		envs = append(envs, &env)
+	}
+
+	return envs
+}
@@ -0,0 +1,213 @@
+package arm
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"testing"
+)
+
+type validateResource struct {
+	Name       string      `json:"name,omitempty"`
+	Type       string      `json:"type,omitempty"`
+	Properties interface{} `json:"properties,omitempty"`
+}
+
+func TestValidate(t *testing.T) {
+	nics := func() *Resource {
+		return &Resource{
+			Resource: &validateResource{
+				Name: "[concat('infra-master', copyIndex(), '-nic')]",
+				Type: "Microsoft.Network/networkInterfaces",
+			},
+			APIVersion: "2020-08-01",
+			Copy: &Copy{
+				Name:  "networkcopy",
+				Count: 3,
+			},
+		}
+	}
+
+	vms := func() *Resource {
+		return &Resource{
+			Resource: &validateResource{
+				Name: "[concat('infra-master-', copyIndex())]",
+				Type: "Microsoft.Compute/virtualMachines",
+				Properties: map[string]interface{}{
+					"customData": "[base64(parameters('sas'))]",
+				},
+			},
+			APIVersion: "2020-06-01",
+			Copy: &Copy{
+				Name:  "computecopy",
+				Count: 3,
+			},
+			DependsOn: []string{
+				"[concat('Microsoft.Network/networkInterfaces/infra-master', copyIndex(), '-nic')]",
+			},
+		}
+	}
+
+	template := func() *Template {
+		return &Template{
+			Parameters: map[string]*TemplateParameter{
+				"sas": {Type: "object"},
+			},
+			Resources: []*Resource{nics(), vms()},
+		}
+	}
+
+	for _, tt := range []struct {
+		name    string
+		mutate  func(*Template)
+		wantErr string
+	}{
+		{
+			name: "valid",
+		},
+		{
+			name: "valid dependsOn forms",
+			mutate: func(t *Template) {
+				t.Resources = append(t.Resources, &Resource{
+					Resource: &validateResource{
+						Name: "lb",
+						Type: "Microsoft.Network/loadBalancers",
+					},
+					APIVersion: "2020-08-01",
+					DependsOn: []string{
+						"networkcopy",
+						"[resourceId('Microsoft.Network/networkInterfaces', 'infra-master0-nic')]",
+						"Microsoft.Compute/virtualMachines/infra-master-2",
+						"infra-master-1",
+					},
+				})
+			},
+		},
+		{
+			name: "dependsOn a non-existent resource",
+			mutate: func(t *Template) {
+				t.Resources[1].DependsOn = []string{
+					"[concat('Microsoft.Network/networkInterfaces/infra-master', copyIndex(1), '-nic')]",
+					"Microsoft.Network/loadBalancers/infra",
+				}
+			},
+			wantErr: `invalid template: resources[1] dependsOn "[concat('Microsoft.Network/networkInterfaces/infra-master', copyIndex(1), '-nic')]" does not match a resource in the template; resources[1] dependsOn "Microsoft.Network/loadBalancers/infra" does not match a resource in the template`,
+		},
+		{
+			name: "copy name collision",
+			mutate: func(t *Template) {
+				t.Resources[1].Copy.Name = "networkcopy"
+			},
+			wantErr: `invalid template: resources[1] copy loop name "networkcopy" is not unique`,
+		},
+		{
+			name: "copy name is a resource name",
+			mutate: func(t *Template) {
+				t.Resources[1].Copy.Name = "infra-master0-nic"
+			},
+			wantErr: `invalid template: copy loop name "infra-master0-nic" is also the name of a resource`,
+		},
+		{
+			name: "missing fields",
+			mutate: func(t *Template) {
+				t.Resources[0].APIVersion = ""
+				t.Resources[0].Copy.Name = ""
+				t.Resources[1].Resource.(*validateResource).Name = ""
+				t.Resources[1].DependsOn = nil
+			},
+			wantErr: `invalid template: resources[0] has no apiVersion; resources[0] has an unnamed copy loop; resources[1] has no name`,
+		},
+		{
+			name: "undeclared parameter",
+			mutate: func(t *Template) {
+				delete(t.Parameters, "sas")
+			},
+			wantErr: `invalid template: "[base64(parameters('sas'))]" references undeclared parameter "sas"`,
+		},
+		{
+			name: "undeclared variable",
+			mutate: func(t *Template) {
+				t.Outputs = map[string]*Output{
+					"name": {Type: "string", Value: "[variables('name')]"},
+				}
+			},
+			wantErr: `invalid template: "[variables('name')]" references undeclared variable "name"`,
+		},
+		{
+			name: "nested templates are not validated",
+			mutate: func(t *Template) {
+				t.Resources = append(t.Resources, &Resource{
+					Resource: &validateResource{
+						Name: "nested",
+						Type: "Microsoft.Resources/deployments",
+						Properties: map[string]interface{}{
+							"parameters": map[string]interface{}{
+								"sas": map[string]interface{}{
+									"value": "[parameters('sas')]",
+								},
+							},
+							"template": map[string]interface{}{
+								"parameters": map[string]interface{}{
+									"nestedSas": map[string]interface{}{
+										"type": "object",
+									},
+								},
+								"resources": []interface{}{
+									map[string]interface{}{
+										"name": "[variables('nestedName')]",
+										"properties": map[string]interface{}{
+											"customData": "[base64(parameters('nestedSas'))]",
+										},
+									},
+								},
+							},
+						},
+					},
+					APIVersion: "2021-04-01",
+				})
+			},
+		},
+		{
+			name: "nested deployment parameters are validated",
+			mutate: func(t *Template) {
+				t.Resources = append(t.Resources, &Resource{
+					Resource: &validateResource{
+						Name: "nested",
+						Type: "Microsoft.Resources/deployments",
+						Properties: map[string]interface{}{
+							"parameters": map[string]interface{}{
+								"sas": map[string]interface{}{
+									"value": "[parameters('missing')]",
+								},
+							},
+						},
+					},
+					APIVersion: "2021-04-01",
+				})
+			},
+			wantErr: `invalid template: "[parameters('missing')]" references undeclared parameter "missing"`,
+		},
+		{
+			name: "invalid expression",
+			mutate: func(t *Template) {
+				t.Resources[1].Resource.(*validateResource).Properties = map[string]interface{}{
+					"customData": "[base64(parameters('sas')]",
+				}
+			},
+			wantErr: `invalid template: parsing "[base64(parameters('sas')]": expected ')' at offset 24`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			template := template()
+			if tt.mutate != nil {
+				tt.mutate(template)
+			}
+
+			err := Validate(template)
+			if err != nil && err.Error() != tt.wantErr ||
+				err == nil && tt.wantErr != "" {
+				t.Error(err)
+			}
+		})
+	}
+}
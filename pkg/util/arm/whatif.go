@@ -0,0 +1,137 @@
+package arm
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	mgmtfeatures "github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-07-01/features"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/sirupsen/logrus"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/util/azureclient/mgmt/features"
+)
+
+// WhatIfCategory is the kind of change a deployment would make to a resource
+type WhatIfCategory string
+
+// WhatIfCategory constants
+const (
+	WhatIfCreate WhatIfCategory = "create"
+	WhatIfModify WhatIfCategory = "modify"
+	WhatIfDelete WhatIfCategory = "delete"
+	WhatIfNoOp   WhatIfCategory = "no-op"
+)
+
+// propertyChangeTypeNoEffect is the change type of properties which differ
+// but which ARM will not change, e.g. read-only ones.  It is more recent than
+// the SDK.
+const propertyChangeTypeNoEffect mgmtfeatures.PropertyChangeType = "NoEffect"
+
+// WhatIfChange is a change a deployment would make to a resource
+type WhatIfChange struct {
+	ResourceID string
+	Category   WhatIfCategory
+
+	// Properties are the paths of the properties which would be modified
+	Properties []string
+}
+
+// WhatIf asks ARM what deploying template in Incremental mode would change
+// and logs the result, without deploying anything
+func WhatIf(ctx context.Context, log *logrus.Entry, deployments features.DeploymentsClient, resourceGroupName string, deploymentName string, template *Template, parameters map[string]interface{}) ([]*WhatIfChange, error) {
+	log.Printf("previewing %s template", deploymentName)
+	result, err := deployments.WhatIfAndWait(ctx, resourceGroupName, deploymentName, mgmtfeatures.DeploymentWhatIf{
+		Properties: &mgmtfeatures.DeploymentWhatIfProperties{
+			Template:   template,
+			Parameters: parameters,
+			Mode:       mgmtfeatures.Incremental,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("%s: %s", to.String(result.Error.Code), to.String(result.Error.Message))
+	}
+
+	var changes []*WhatIfChange
+	if result.WhatIfOperationProperties != nil && result.Changes != nil {
+		for _, c := range *result.Changes {
+			change := &WhatIfChange{
+				ResourceID: to.String(c.ResourceID),
+				Category:   whatIfCategory(c.ChangeType),
+			}
+
+			if c.Delta != nil {
+				change.Properties = propertyPaths("", *c.Delta)
+			}
+
+			changes = append(changes, change)
+		}
+	}
+
+	logWhatIf(log, deploymentName, changes)
+
+	return changes, nil
+}
+
+// whatIfCategory maps an ARM change type to its category.  Resources which
+// ARM cannot predict the changes of are assumed to be modified.
+func whatIfCategory(changeType mgmtfeatures.ChangeType) WhatIfCategory {
+	switch changeType {
+	case mgmtfeatures.Create:
+		return WhatIfCreate
+	case mgmtfeatures.Modify, mgmtfeatures.Deploy:
+		return WhatIfModify
+	case mgmtfeatures.Delete:
+		return WhatIfDelete
+	}
+
+	return WhatIfNoOp
+}
+
+// propertyPaths returns the paths of the leaf property changes of delta which
+// would take effect
+func propertyPaths(prefix string, delta []mgmtfeatures.WhatIfPropertyChange) []string {
+	var paths []string
+	for _, d := range delta {
+		if d.PropertyChangeType == propertyChangeTypeNoEffect {
+			continue
+		}
+
+		path := to.String(d.Path)
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+
+		if d.Children != nil && len(*d.Children) > 0 {
+			paths = append(paths, propertyPaths(path, *d.Children)...)
+		} else {
+			paths = append(paths, path)
+		}
+	}
+
+	return paths
+}
+
+func logWhatIf(log *logrus.Entry, deploymentName string, changes []*WhatIfChange) {
+	counts := map[WhatIfCategory]int{}
+	for _, c := range changes {
+		counts[c.Category]++
+
+		switch {
+		case c.Category == WhatIfNoOp:
+		case len(c.Properties) > 0:
+			log.Printf("%s template would %s %s (%s)", deploymentName, c.Category, c.ResourceID, strings.Join(c.Properties, ", "))
+		default:
+			log.Printf("%s template would %s %s", deploymentName, c.Category, c.ResourceID)
+		}
+	}
+
+	log.Printf("%s template would create %d, modify %d and delete %d resources, and leave %d unchanged", deploymentName, counts[WhatIfCreate], counts[WhatIfModify], counts[WhatIfDelete], counts[WhatIfNoOp])
+}
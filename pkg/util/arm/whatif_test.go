@@ -0,0 +1,104 @@
+package arm
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	mgmtfeatures "github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-07-01/features"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+
+	mock_features "github.com/openshift/installer-aro-wrapper/pkg/util/mocks/azureclient/mgmt/features"
+)
+
+func TestWhatIf(t *testing.T) {
+	ctx := context.Background()
+
+	template := &Template{}
+	parameters := map[string]interface{}{}
+
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	deployments := mock_features.NewMockDeploymentsClient(controller)
+	deployments.EXPECT().WhatIfAndWait(ctx, "rg", deploymentName, mgmtfeatures.DeploymentWhatIf{
+		Properties: &mgmtfeatures.DeploymentWhatIfProperties{
+			Template:   template,
+			Parameters: parameters,
+			Mode:       mgmtfeatures.Incremental,
+		},
+	}).Return(mgmtfeatures.WhatIfOperationResult{
+		WhatIfOperationProperties: &mgmtfeatures.WhatIfOperationProperties{
+			Changes: &[]mgmtfeatures.WhatIfChange{
+				{
+					ResourceID: to.StringPtr("vm"),
+					ChangeType: mgmtfeatures.Modify,
+					Delta: &[]mgmtfeatures.WhatIfPropertyChange{
+						{
+							Path: to.StringPtr("properties.hardwareProfile.vmSize"),
+						},
+						{
+							Path:               to.StringPtr("properties.provisioningState"),
+							PropertyChangeType: propertyChangeTypeNoEffect,
+						},
+						{
+							Path: to.StringPtr("tags"),
+							Children: &[]mgmtfeatures.WhatIfPropertyChange{
+								{Path: to.StringPtr("a")},
+								{Path: to.StringPtr("b")},
+								{Path: to.StringPtr("c"), PropertyChangeType: propertyChangeTypeNoEffect},
+							},
+						},
+					},
+				},
+				{ResourceID: to.StringPtr("nic"), ChangeType: mgmtfeatures.Create},
+				{ResourceID: to.StringPtr("lb"), ChangeType: mgmtfeatures.Delete},
+				{ResourceID: to.StringPtr("disk"), ChangeType: mgmtfeatures.NoChange},
+				{ResourceID: to.StringPtr("other"), ChangeType: mgmtfeatures.Ignore},
+				{ResourceID: to.StringPtr("deployment"), ChangeType: mgmtfeatures.Deploy},
+			},
+		},
+	}, nil)
+
+	logger, hook := test.NewNullLogger()
+
+	changes, err := WhatIf(ctx, logrus.NewEntry(logger), deployments, "rg", deploymentName, template, parameters)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantChanges := []*WhatIfChange{
+		{ResourceID: "vm", Category: WhatIfModify, Properties: []string{"properties.hardwareProfile.vmSize", "tags.a", "tags.b"}},
+		{ResourceID: "nic", Category: WhatIfCreate},
+		{ResourceID: "lb", Category: WhatIfDelete},
+		{ResourceID: "disk", Category: WhatIfNoOp},
+		{ResourceID: "other", Category: WhatIfNoOp},
+		{ResourceID: "deployment", Category: WhatIfModify},
+	}
+	if !reflect.DeepEqual(changes, wantChanges) {
+		t.Errorf("got changes %#v", changes)
+	}
+
+	var messages []string
+	for _, e := range hook.AllEntries() {
+		messages = append(messages, e.Message)
+	}
+
+	wantMessages := []string{
+		"previewing test template",
+		"test template would modify vm (properties.hardwareProfile.vmSize, tags.a, tags.b)",
+		"test template would create nic",
+		"test template would delete lb",
+		"test template would modify deployment",
+		"test template would create 1, modify 2 and delete 1 resources, and leave 2 unchanged",
+	}
+	if !reflect.DeepEqual(messages, wantMessages) {
+		t.Errorf("got messages %#v", messages)
+	}
+}
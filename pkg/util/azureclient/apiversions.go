@@ -24,6 +24,7 @@ var apiVersions = map[string]string{
 	"microsoft.network":                       "2020-08-01",
 	"microsoft.network/dnszones":              "2018-05-01",
 	"microsoft.network/privatednszones":       "2018-09-01",
+	"microsoft.resources":                     "2019-07-01",
 	"microsoft.storage":                       "2019-04-01",
 }
 
@@ -16,6 +16,7 @@ import (
 type VirtualMachinesClient interface {
 	VirtualMachinesClientAddons
 	Get(ctx context.Context, resourceGroupName string, VMName string, expand mgmtcompute.InstanceViewTypes) (result mgmtcompute.VirtualMachine, err error)
+	RetrieveBootDiagnosticsData(ctx context.Context, resourceGroupName string, VMName string, sasURIExpirationTimeInMinutes *int32) (result mgmtcompute.RetrieveBootDiagnosticsDataResult, err error)
 }
 
 type virtualMachinesClient struct {
@@ -0,0 +1,32 @@
+package features
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	mgmtfeatures "github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-07-01/features"
+	"github.com/Azure/go-autorest/autorest"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/util/azureclient"
+)
+
+// DeploymentOperationsClient is a minimal interface for azure DeploymentOperationsClient
+type DeploymentOperationsClient interface {
+	DeploymentOperationsClientAddons
+}
+
+type deploymentOperationsClient struct {
+	mgmtfeatures.DeploymentOperationsClient
+}
+
+var _ DeploymentOperationsClient = &deploymentOperationsClient{}
+
+// NewDeploymentOperationsClient creates a new DeploymentOperationsClient
+func NewDeploymentOperationsClient(environment *azureclient.AROEnvironment, subscriptionID string, authorizer autorest.Authorizer) DeploymentOperationsClient {
+	client := mgmtfeatures.NewDeploymentOperationsClientWithBaseURI(environment.ResourceManagerEndpoint, subscriptionID)
+	client.Authorizer = authorizer
+
+	return &deploymentOperationsClient{
+		DeploymentOperationsClient: client,
+	}
+}
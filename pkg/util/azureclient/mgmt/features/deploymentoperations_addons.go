@@ -0,0 +1,32 @@
+package features
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+
+	mgmtfeatures "github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-07-01/features"
+)
+
+// DeploymentOperationsClientAddons contains addons for DeploymentOperationsClient
+type DeploymentOperationsClientAddons interface {
+	List(ctx context.Context, resourceGroupName string, deploymentName string) ([]mgmtfeatures.DeploymentOperation, error)
+}
+
+func (c *deploymentOperationsClient) List(ctx context.Context, resourceGroupName string, deploymentName string) (operations []mgmtfeatures.DeploymentOperation, err error) {
+	page, err := c.DeploymentOperationsClient.List(ctx, resourceGroupName, deploymentName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for page.NotDone() {
+		operations = append(operations, page.Values()...)
+		err = page.NextWithContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return operations, nil
+}
@@ -16,7 +16,9 @@ type DeploymentsClientAddons interface {
 	CreateOrUpdateAndWait(ctx context.Context, resourceGroupName string, deploymentName string, parameters mgmtfeatures.Deployment) error
 	CreateOrUpdateAtSubscriptionScopeAndWait(ctx context.Context, deploymentName string, parameters mgmtfeatures.Deployment) error
 	DeleteAndWait(ctx context.Context, resourceGroupName string, deploymentName string) error
+	ListByResourceGroup(ctx context.Context, resourceGroupName string, filter string, top *int32) ([]mgmtfeatures.DeploymentExtended, error)
 	Wait(ctx context.Context, resourceGroupName string, deploymentName string) error
+	WhatIfAndWait(ctx context.Context, resourceGroupName string, deploymentName string, parameters mgmtfeatures.DeploymentWhatIf) (mgmtfeatures.WhatIfOperationResult, error)
 }
 
 func (c *deploymentsClient) CreateOrUpdateAtSubscriptionScopeAndWait(ctx context.Context, deploymentName string, parameters mgmtfeatures.Deployment) error {
@@ -46,6 +48,37 @@ func (c *deploymentsClient) DeleteAndWait(ctx context.Context, resourceGroupName
 	return future.WaitForCompletionRef(ctx, c.Client)
 }
 
+func (c *deploymentsClient) WhatIfAndWait(ctx context.Context, resourceGroupName string, deploymentName string, parameters mgmtfeatures.DeploymentWhatIf) (mgmtfeatures.WhatIfOperationResult, error) {
+	future, err := c.DeploymentsClient.WhatIf(ctx, resourceGroupName, deploymentName, parameters)
+	if err != nil {
+		return mgmtfeatures.WhatIfOperationResult{}, err
+	}
+
+	err = future.WaitForCompletionRef(ctx, c.Client)
+	if err != nil {
+		return mgmtfeatures.WhatIfOperationResult{}, err
+	}
+
+	return future.Result(c.DeploymentsClient)
+}
+
+func (c *deploymentsClient) ListByResourceGroup(ctx context.Context, resourceGroupName string, filter string, top *int32) (deployments []mgmtfeatures.DeploymentExtended, err error) {
+	page, err := c.DeploymentsClient.ListByResourceGroup(ctx, resourceGroupName, filter, top)
+	if err != nil {
+		return nil, err
+	}
+
+	for page.NotDone() {
+		deployments = append(deployments, page.Values()...)
+		err = page.NextWithContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return deployments, nil
+}
+
 func (c *deploymentsClient) Wait(ctx context.Context, resourceGroupName string, deploymentName string) error {
 	return wait.PollUntilContextTimeout(ctx, c.Client.PollingDelay, c.Client.PollingDuration, false, func(_ context.Context) (bool, error) {
 		deployment, err := c.DeploymentsClient.Get(ctx, resourceGroupName, deploymentName)
@@ -17,6 +17,7 @@ type AccountsClient interface {
 	GetProperties(ctx context.Context, resourceGroupName string, accountName string, expand mgmtstorage.AccountExpand) (result mgmtstorage.Account, err error)
 	Update(ctx context.Context, resourceGroupName string, accountName string, parameters mgmtstorage.AccountUpdateParameters) (result mgmtstorage.Account, err error)
 	ListAccountSAS(ctx context.Context, resourceGroupName string, accountName string, parameters mgmtstorage.AccountSasParameters) (result mgmtstorage.ListAccountSasResponse, err error)
+	ListServiceSAS(ctx context.Context, resourceGroupName string, accountName string, parameters mgmtstorage.ServiceSasParameters) (result mgmtstorage.ListServiceSasResponse, err error)
 	ListKeys(ctx context.Context, resourceGroupName string, accountName string, expand mgmtstorage.ListKeyExpand) (result mgmtstorage.AccountListKeysResult, err error)
 }
 
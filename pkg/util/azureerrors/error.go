@@ -5,6 +5,7 @@ package azureerrors
 
 import (
 	"encoding/json"
+	"net/http"
 	"strings"
 
 	"github.com/Azure/go-autorest/autorest"
@@ -71,6 +72,33 @@ func IsDeploymentActiveError(err error) bool {
 	return false
 }
 
+// IsRoleAssignmentExistsError returns true if the error is a RoleAssignmentExists
+// error, or a failed deployment whose failures are all RoleAssignmentExists
+// errors
+func IsRoleAssignmentExistsError(err error) bool {
+	if cloudErr, ok := err.(*api.CloudError); ok && cloudErr.CloudErrorBody != nil {
+		if len(cloudErr.Details) == 0 {
+			return cloudErr.Code == "RoleAssignmentExists"
+		}
+		for _, d := range cloudErr.Details {
+			if d.Code != "RoleAssignmentExists" {
+				return false
+			}
+		}
+		return true
+	}
+
+	if detailedErr, ok := err.(autorest.DetailedError); ok {
+		err = detailedErr.Original
+	}
+	if serviceErr, ok := err.(*azure.ServiceError); ok &&
+		serviceErr.Code == "RoleAssignmentExists" {
+		return true
+	}
+
+	return false
+}
+
 // IsInvalidSecretError returns if errors is InvalidCredentials error
 // Example: (adal.tokenRefreshError) adal: Refresh request failed. Status Code = '401'.
 // Response body: {"error":"invalid_client","error_description":"AADSTS7000215:
@@ -106,3 +134,11 @@ func ResourceGroupNotFound(err error) bool {
 	}
 	return false
 }
+
+// IsNotFoundError returns true if the error is a NotFound error
+func IsNotFoundError(err error) bool {
+	if detailedErr, ok := err.(autorest.DetailedError); ok {
+		return detailedErr.StatusCode == http.StatusNotFound
+	}
+	return false
+}
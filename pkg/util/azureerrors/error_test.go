@@ -10,6 +10,8 @@ import (
 
 	"github.com/Azure/go-autorest/autorest"
 	"github.com/Azure/go-autorest/autorest/azure"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/api"
 )
 
 // The tests in this file contain verbatim copies of errors returned from Azure
@@ -120,3 +122,108 @@ func TestIsDeploymentActiveError(t *testing.T) {
 		})
 	}
 }
+
+func TestIsNotFoundError(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "Another error",
+			err:  errors.New("something happened"),
+		},
+		{
+			name: "Not found",
+			err: autorest.DetailedError{
+				StatusCode: http.StatusNotFound,
+			},
+			want: true,
+		},
+		{
+			name: "Forbidden",
+			err: autorest.DetailedError{
+				StatusCode: http.StatusForbidden,
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := IsNotFoundError(tt.err)
+			if got != tt.want {
+				t.Error(got)
+			}
+		})
+	}
+}
+
+func TestIsRoleAssignmentExistsError(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "Another error",
+			err:  errors.New("something happened"),
+		},
+		{
+			name: "Role assignment exists",
+			err: autorest.DetailedError{
+				Original: &azure.ServiceError{
+					Code:    "RoleAssignmentExists",
+					Message: "The role assignment already exists.",
+				},
+				PackageType: "authorization.RoleAssignmentsClient",
+				Method:      "Create",
+				StatusCode:  http.StatusConflict,
+				Message:     "Failure responding to request",
+			},
+			want: true,
+		},
+		{
+			name: "Deployment failed on existing role assignments",
+			err: &api.CloudError{
+				StatusCode: http.StatusBadRequest,
+				CloudErrorBody: &api.CloudErrorBody{
+					Code:    api.CloudErrorCodeDeploymentFailed,
+					Message: "Deployment failed.",
+					Details: []api.CloudErrorBody{
+						{
+							Code:    "RoleAssignmentExists",
+							Target:  "Microsoft.Authorization/roleAssignments/2b4d0d6a-1b8d-5c0e-9f0f-3a3c0e1f5f55",
+							Message: "The role assignment already exists.",
+						},
+					},
+				},
+			},
+			want: true,
+		},
+		{
+			name: "Deployment failed on other resources",
+			err: &api.CloudError{
+				StatusCode: http.StatusBadRequest,
+				CloudErrorBody: &api.CloudErrorBody{
+					Code:    api.CloudErrorCodeDeploymentFailed,
+					Message: "Deployment failed.",
+					Details: []api.CloudErrorBody{
+						{
+							Code:    "RoleAssignmentExists",
+							Message: "The role assignment already exists.",
+						},
+						{
+							Code:    "InternalServerError",
+							Message: "An error has occurred.",
+						},
+					},
+				},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := IsRoleAssignmentExistsError(tt.err)
+			if got != tt.want {
+				t.Error(got)
+			}
+		})
+	}
+}
@@ -35,9 +35,36 @@ func HasCapability(sku *mgmtcompute.ResourceSku, capabilityName string) bool {
 	return false
 }
 
-// IsRestricted checks whether given resource SKU is restricted in a given location
+// GetCapability returns the value of the given capability of the resource
+// SKU, or the empty string if the SKU doesn't have it
+func GetCapability(sku *mgmtcompute.ResourceSku, capabilityName string) string {
+	if sku.Capabilities == nil {
+		return ""
+	}
+
+	for _, c := range *sku.Capabilities {
+		if *c.Name == capabilityName {
+			return *c.Value
+		}
+	}
+
+	return ""
+}
+
+// IsRestricted checks whether given resource SKU is restricted in a given
+// location.  Zone restrictions are not considered: see AvailableZones.
 func IsRestricted(skus map[string]*mgmtcompute.ResourceSku, location, VMSize string) bool {
-	for _, restriction := range *skus[VMSize].Restrictions {
+	sku, found := skus[VMSize]
+	if !found || sku.Restrictions == nil {
+		return false
+	}
+
+	for _, restriction := range *sku.Restrictions {
+		if restriction.Type == mgmtcompute.Zone ||
+			restriction.RestrictionInfo == nil || restriction.RestrictionInfo.Locations == nil {
+			continue
+		}
+
 		for _, restrictedLocation := range *restriction.RestrictionInfo.Locations {
 			if restrictedLocation == location {
 				return true
@@ -48,7 +75,48 @@ func IsRestricted(skus map[string]*mgmtcompute.ResourceSku, location, VMSize str
 	return false
 }
 
-// FilterVMSizes filters resource SKU by location and returns only virtual machines, their names, restrictions, location info, and capabilities.
+// AvailableZones returns the zones of the resource SKU which are not
+// restricted in a given location
+func AvailableZones(sku *mgmtcompute.ResourceSku, location string) []string {
+	restricted := map[string]bool{}
+	if sku.Restrictions != nil {
+		for _, restriction := range *sku.Restrictions {
+			if restriction.Type != mgmtcompute.Zone ||
+				restriction.RestrictionInfo == nil || restriction.RestrictionInfo.Zones == nil {
+				continue
+			}
+
+			if restriction.RestrictionInfo.Locations != nil && !containsFold(*restriction.RestrictionInfo.Locations, location) {
+				continue
+			}
+
+			for _, zone := range *restriction.RestrictionInfo.Zones {
+				restricted[zone] = true
+			}
+		}
+	}
+
+	var zones []string
+	for _, zone := range Zones(sku) {
+		if !restricted[zone] {
+			zones = append(zones, zone)
+		}
+	}
+
+	return zones
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FilterVMSizes filters resource SKU by location and returns only virtual machines, their names, families, restrictions, location info, and capabilities.
 func FilterVMSizes(skus []mgmtcompute.ResourceSku, location string) map[string]*mgmtcompute.ResourceSku {
 	vmskus := map[string]*mgmtcompute.ResourceSku{}
 	for _, sku := range skus {
@@ -69,6 +137,7 @@ func FilterVMSizes(skus []mgmtcompute.ResourceSku, location string) map[string]*
 		// a lot of data in memory.
 		vmskus[*sku.Name] = &mgmtcompute.ResourceSku{
 			Name:         sku.Name,
+			Family:       sku.Family,
 			Restrictions: sku.Restrictions,
 			LocationInfo: sku.LocationInfo,
 			Capabilities: sku.Capabilities,
@@ -103,6 +103,41 @@ func TestHasCapability(t *testing.T) {
 	}
 }
 
+func TestGetCapability(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		sku  *mgmtcompute.ResourceSku
+		want string
+	}{
+		{
+			name: "sku has capability",
+			sku: &mgmtcompute.ResourceSku{
+				Capabilities: &([]mgmtcompute.ResourceSkuCapabilities{
+					{Name: to.StringPtr("vCPUs"), Value: to.StringPtr("4")},
+				}),
+			},
+			want: "4",
+		},
+		{
+			name: "capability missing from the list",
+			sku: &mgmtcompute.ResourceSku{
+				Capabilities: &([]mgmtcompute.ResourceSkuCapabilities{}),
+			},
+		},
+		{
+			name: "capabilities info missing",
+			sku:  &mgmtcompute.ResourceSku{},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := GetCapability(tt.sku, "vCPUs")
+			if got != tt.want {
+				t.Error(got)
+			}
+		})
+	}
+}
+
 func TestFilterVmSizes(t *testing.T) {
 	for _, tt := range []struct {
 		name             string
@@ -125,7 +160,8 @@ func TestFilterVmSizes(t *testing.T) {
 
 			wantResult: map[string]*mgmtcompute.ResourceSku{
 				"Fake_Sku": {
-					Name: to.StringPtr("Fake_Sku"),
+					Name:   to.StringPtr("Fake_Sku"),
+					Family: to.StringPtr("fakeFamily"),
 					Restrictions: &[]mgmtcompute.ResourceSkuRestrictions{{
 						ReasonCode: mgmtcompute.NotAvailableForSubscription}},
 					LocationInfo: &[]mgmtcompute.ResourceSkuLocationInfo{{
@@ -173,7 +209,8 @@ func TestFilterVmSizes(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			sku := []mgmtcompute.ResourceSku{
 				{
-					Name: to.StringPtr("Fake_Sku"),
+					Name:   to.StringPtr("Fake_Sku"),
+					Family: to.StringPtr("fakeFamily"),
 					Capabilities: &[]mgmtcompute.ResourceSkuCapabilities{
 						{
 							Name: to.StringPtr(tt.skuCapabilities),
@@ -232,6 +269,29 @@ func TestIsRestricted(t *testing.T) {
 			},
 			wantResult: true,
 		},
+		{
+			name:     "sku is only restricted in some zones",
+			location: "eastus",
+			vmsize:   "Standard_Sku_1",
+			sku: map[string]*mgmtcompute.ResourceSku{
+				"Standard_Sku_1": {Restrictions: &[]mgmtcompute.ResourceSkuRestrictions{
+					{
+						Type: mgmtcompute.Zone,
+						RestrictionInfo: &mgmtcompute.ResourceSkuRestrictionInfo{
+							Locations: &[]string{"eastus"},
+							Zones:     &[]string{"2"},
+						},
+					},
+				}},
+			},
+			wantResult: false,
+		},
+		{
+			name:     "sku is not found",
+			location: "eastus",
+			vmsize:   "Standard_Sku_3",
+			sku:      map[string]*mgmtcompute.ResourceSku{},
+		},
 		{
 			name:     "sku is not restricted",
 			location: "eastus",
@@ -255,3 +315,57 @@ func TestIsRestricted(t *testing.T) {
 		})
 	}
 }
+
+func TestAvailableZones(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		sku  *mgmtcompute.ResourceSku
+		want []string
+	}{
+		{
+			name: "no zones",
+			sku:  &mgmtcompute.ResourceSku{},
+		},
+		{
+			name: "no restrictions",
+			sku: &mgmtcompute.ResourceSku{
+				LocationInfo: &[]mgmtcompute.ResourceSkuLocationInfo{
+					{Zones: &[]string{"1", "2", "3"}},
+				},
+			},
+			want: []string{"1", "2", "3"},
+		},
+		{
+			name: "zone restriction",
+			sku: &mgmtcompute.ResourceSku{
+				LocationInfo: &[]mgmtcompute.ResourceSkuLocationInfo{
+					{Zones: &[]string{"1", "2", "3"}},
+				},
+				Restrictions: &[]mgmtcompute.ResourceSkuRestrictions{
+					{
+						Type: mgmtcompute.Zone,
+						RestrictionInfo: &mgmtcompute.ResourceSkuRestrictionInfo{
+							Locations: &[]string{"eastus"},
+							Zones:     &[]string{"2"},
+						},
+					},
+					{
+						Type: mgmtcompute.Zone,
+						RestrictionInfo: &mgmtcompute.ResourceSkuRestrictionInfo{
+							Locations: &[]string{"westus"},
+							Zones:     &[]string{"3"},
+						},
+					},
+				},
+			},
+			want: []string{"1", "3"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AvailableZones(tt.sku, "eastus")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Error(got)
+			}
+		})
+	}
+}
@@ -197,6 +197,21 @@ func (mr *MockVirtualMachinesClientMockRecorder) RedeployAndWait(arg0, arg1, arg
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RedeployAndWait", reflect.TypeOf((*MockVirtualMachinesClient)(nil).RedeployAndWait), arg0, arg1, arg2)
 }
 
+// RetrieveBootDiagnosticsData mocks base method.
+func (m *MockVirtualMachinesClient) RetrieveBootDiagnosticsData(arg0 context.Context, arg1, arg2 string, arg3 *int32) (compute.RetrieveBootDiagnosticsDataResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RetrieveBootDiagnosticsData", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(compute.RetrieveBootDiagnosticsDataResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RetrieveBootDiagnosticsData indicates an expected call of RetrieveBootDiagnosticsData.
+func (mr *MockVirtualMachinesClientMockRecorder) RetrieveBootDiagnosticsData(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetrieveBootDiagnosticsData", reflect.TypeOf((*MockVirtualMachinesClient)(nil).RetrieveBootDiagnosticsData), arg0, arg1, arg2, arg3)
+}
+
 // StartAndWait mocks base method.
 func (m *MockVirtualMachinesClient) StartAndWait(arg0 context.Context, arg1, arg2 string) error {
 	m.ctrl.T.Helper()
@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/openshift/installer-aro-wrapper/pkg/util/azureclient/mgmt/features (interfaces: DeploymentsClient,ProvidersClient,ResourceGroupsClient,ResourcesClient)
+// Source: github.com/openshift/installer-aro-wrapper/pkg/util/azureclient/mgmt/features (interfaces: DeploymentOperationsClient,DeploymentsClient,ProvidersClient,ResourceGroupsClient,ResourcesClient)
 
 // Package mock_features is a generated GoMock package.
 package mock_features
@@ -13,6 +13,44 @@ import (
 	gomock "github.com/golang/mock/gomock"
 )
 
+// MockDeploymentOperationsClient is a mock of DeploymentOperationsClient interface.
+type MockDeploymentOperationsClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockDeploymentOperationsClientMockRecorder
+}
+
+// MockDeploymentOperationsClientMockRecorder is the mock recorder for MockDeploymentOperationsClient.
+type MockDeploymentOperationsClientMockRecorder struct {
+	mock *MockDeploymentOperationsClient
+}
+
+// NewMockDeploymentOperationsClient creates a new mock instance.
+func NewMockDeploymentOperationsClient(ctrl *gomock.Controller) *MockDeploymentOperationsClient {
+	mock := &MockDeploymentOperationsClient{ctrl: ctrl}
+	mock.recorder = &MockDeploymentOperationsClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDeploymentOperationsClient) EXPECT() *MockDeploymentOperationsClientMockRecorder {
+	return m.recorder
+}
+
+// List mocks base method.
+func (m *MockDeploymentOperationsClient) List(arg0 context.Context, arg1, arg2 string) ([]features.DeploymentOperation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]features.DeploymentOperation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockDeploymentOperationsClientMockRecorder) List(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockDeploymentOperationsClient)(nil).List), arg0, arg1, arg2)
+}
+
 // MockDeploymentsClient is a mock of DeploymentsClient interface.
 type MockDeploymentsClient struct {
 	ctrl     *gomock.Controller
@@ -93,6 +131,21 @@ func (mr *MockDeploymentsClientMockRecorder) Get(arg0, arg1, arg2 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockDeploymentsClient)(nil).Get), arg0, arg1, arg2)
 }
 
+// ListByResourceGroup mocks base method.
+func (m *MockDeploymentsClient) ListByResourceGroup(arg0 context.Context, arg1, arg2 string, arg3 *int32) ([]features.DeploymentExtended, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListByResourceGroup", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]features.DeploymentExtended)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListByResourceGroup indicates an expected call of ListByResourceGroup.
+func (mr *MockDeploymentsClientMockRecorder) ListByResourceGroup(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListByResourceGroup", reflect.TypeOf((*MockDeploymentsClient)(nil).ListByResourceGroup), arg0, arg1, arg2, arg3)
+}
+
 // Wait mocks base method.
 func (m *MockDeploymentsClient) Wait(arg0 context.Context, arg1, arg2 string) error {
 	m.ctrl.T.Helper()
@@ -107,6 +160,21 @@ func (mr *MockDeploymentsClientMockRecorder) Wait(arg0, arg1, arg2 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Wait", reflect.TypeOf((*MockDeploymentsClient)(nil).Wait), arg0, arg1, arg2)
 }
 
+// WhatIfAndWait mocks base method.
+func (m *MockDeploymentsClient) WhatIfAndWait(arg0 context.Context, arg1, arg2 string, arg3 features.DeploymentWhatIf) (features.WhatIfOperationResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WhatIfAndWait", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(features.WhatIfOperationResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WhatIfAndWait indicates an expected call of WhatIfAndWait.
+func (mr *MockDeploymentsClientMockRecorder) WhatIfAndWait(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WhatIfAndWait", reflect.TypeOf((*MockDeploymentsClient)(nil).WhatIfAndWait), arg0, arg1, arg2, arg3)
+}
+
 // MockProvidersClient is a mock of ProvidersClient interface.
 type MockProvidersClient struct {
 	ctrl     *gomock.Controller
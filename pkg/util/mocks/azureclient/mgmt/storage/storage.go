@@ -80,6 +80,21 @@ func (mr *MockAccountsClientMockRecorder) ListKeys(arg0, arg1, arg2, arg3 interf
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListKeys", reflect.TypeOf((*MockAccountsClient)(nil).ListKeys), arg0, arg1, arg2, arg3)
 }
 
+// ListServiceSAS mocks base method.
+func (m *MockAccountsClient) ListServiceSAS(arg0 context.Context, arg1, arg2 string, arg3 storage.ServiceSasParameters) (storage.ListServiceSasResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListServiceSAS", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(storage.ListServiceSasResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListServiceSAS indicates an expected call of ListServiceSAS.
+func (mr *MockAccountsClientMockRecorder) ListServiceSAS(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListServiceSAS", reflect.TypeOf((*MockAccountsClient)(nil).ListServiceSAS), arg0, arg1, arg2, arg3)
+}
+
 // Update mocks base method.
 func (m *MockAccountsClient) Update(arg0 context.Context, arg1, arg2 string, arg3 storage.AccountUpdateParameters) (storage.Account, error) {
 	m.ctrl.T.Helper()
@@ -0,0 +1,75 @@
+package permissions
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"regexp"
+	"strings"
+
+	mgmtauthorization "github.com/Azure/azure-sdk-for-go/services/preview/authorization/mgmt/2018-09-01-preview/authorization"
+)
+
+// CanDoAction returns true if the given permissions allow action.  An action is
+// allowed if any permission has a matching action and no matching not-action.
+// Actions may contain "*" wildcards and are matched case-insensitively.
+func CanDoAction(ps []mgmtauthorization.Permission, action string) (bool, error) {
+	return canDo(ps, action, func(p *mgmtauthorization.Permission) (*[]string, *[]string) {
+		return p.Actions, p.NotActions
+	})
+}
+
+// CanDoDataAction returns true if the given permissions allow the data action,
+// e.g. Microsoft.KeyVault/vaults/keys/wrap/action.  It follows the same rules
+// as CanDoAction, using DataActions and NotDataActions.
+func CanDoDataAction(ps []mgmtauthorization.Permission, dataAction string) (bool, error) {
+	return canDo(ps, dataAction, func(p *mgmtauthorization.Permission) (*[]string, *[]string) {
+		return p.DataActions, p.NotDataActions
+	})
+}
+
+func canDo(ps []mgmtauthorization.Permission, action string, actions func(*mgmtauthorization.Permission) (*[]string, *[]string)) (bool, error) {
+	for i := range ps {
+		allowed, denied := actions(&ps[i])
+		if allowed == nil {
+			continue
+		}
+
+		matched, err := matchesAny(*allowed, action)
+		if err != nil {
+			return false, err
+		}
+		if !matched {
+			continue
+		}
+
+		if denied != nil {
+			excluded, err := matchesAny(*denied, action)
+			if err != nil {
+				return false, err
+			}
+			if excluded {
+				continue
+			}
+		}
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func matchesAny(patterns []string, action string) (bool, error) {
+	for _, pattern := range patterns {
+		rx, err := regexp.Compile("(?i)^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$")
+		if err != nil {
+			return false, err
+		}
+
+		if rx.MatchString(action) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,132 @@
+package permissions
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"testing"
+
+	mgmtauthorization "github.com/Azure/azure-sdk-for-go/services/preview/authorization/mgmt/2018-09-01-preview/authorization"
+)
+
+func TestCanDoAction(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		ps     []mgmtauthorization.Permission
+		action string
+		want   bool
+	}{
+		{
+			name:   "no permissions",
+			action: "Microsoft.Network/virtualNetworks/read",
+		},
+		{
+			name: "exact match",
+			ps: []mgmtauthorization.Permission{
+				{Actions: &[]string{"Microsoft.Network/virtualNetworks/read"}},
+			},
+			action: "Microsoft.Network/virtualNetworks/read",
+			want:   true,
+		},
+		{
+			name: "case-insensitive wildcard match",
+			ps: []mgmtauthorization.Permission{
+				{Actions: &[]string{"microsoft.network/*"}},
+			},
+			action: "Microsoft.Network/virtualNetworks/subnets/join/action",
+			want:   true,
+		},
+		{
+			name: "global wildcard",
+			ps: []mgmtauthorization.Permission{
+				{Actions: &[]string{"*"}},
+			},
+			action: "Microsoft.Compute/diskEncryptionSets/read",
+			want:   true,
+		},
+		{
+			name: "excluded by not-action",
+			ps: []mgmtauthorization.Permission{
+				{
+					Actions:    &[]string{"*"},
+					NotActions: &[]string{"Microsoft.Network/*/join/action"},
+				},
+			},
+			action: "Microsoft.Network/routeTables/join/action",
+		},
+		{
+			name: "excluded in one permission but allowed by another",
+			ps: []mgmtauthorization.Permission{
+				{
+					Actions:    &[]string{"*"},
+					NotActions: &[]string{"Microsoft.Network/*/join/action"},
+				},
+				{Actions: &[]string{"Microsoft.Network/routeTables/*"}},
+			},
+			action: "Microsoft.Network/routeTables/join/action",
+			want:   true,
+		},
+		{
+			name: "dots are not wildcards",
+			ps: []mgmtauthorization.Permission{
+				{Actions: &[]string{"Microsoft.Network/virtualNetworks/read"}},
+			},
+			action: "MicrosoftXNetwork/virtualNetworks/read",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CanDoAction(tt.ps, tt.action)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Error(got)
+			}
+		})
+	}
+}
+
+func TestCanDoDataAction(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		ps         []mgmtauthorization.Permission
+		dataAction string
+		want       bool
+	}{
+		{
+			name: "actions do not grant data actions",
+			ps: []mgmtauthorization.Permission{
+				{Actions: &[]string{"*"}},
+			},
+			dataAction: "Microsoft.KeyVault/vaults/keys/wrap/action",
+		},
+		{
+			name: "wildcard data action",
+			ps: []mgmtauthorization.Permission{
+				{DataActions: &[]string{"Microsoft.KeyVault/vaults/keys/*"}},
+			},
+			dataAction: "Microsoft.KeyVault/vaults/keys/wrap/action",
+			want:       true,
+		},
+		{
+			name: "excluded by not-data-action",
+			ps: []mgmtauthorization.Permission{
+				{
+					DataActions:    &[]string{"Microsoft.KeyVault/vaults/keys/*"},
+					NotDataActions: &[]string{"Microsoft.KeyVault/vaults/keys/unwrap/action"},
+				},
+			},
+			dataAction: "Microsoft.KeyVault/vaults/keys/unwrap/action",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CanDoDataAction(tt.ps, tt.dataAction)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Error(got)
+			}
+		})
+	}
+}
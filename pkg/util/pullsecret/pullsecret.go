@@ -6,8 +6,10 @@ package pullsecret
 import (
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"os"
 	"reflect"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 
@@ -139,6 +141,38 @@ func RemoveKey(_ps, key string) (string, error) {
 	return string(b), err
 }
 
+// Credentials returns the username and password _ps holds for registry, or
+// empty strings if it holds none
+func Credentials(_ps, registry string) (string, string, error) {
+	if _ps == "" {
+		_ps = "{}"
+	}
+
+	var ps *pullSecret
+
+	err := json.Unmarshal([]byte(_ps), &ps)
+	if err != nil {
+		return "", "", err
+	}
+
+	auth, _ := ps.Auths[registry]["auth"].(string)
+	if auth == "" {
+		return "", "", nil
+	}
+
+	b, err := base64.StdEncoding.DecodeString(auth)
+	if err != nil {
+		return "", "", err
+	}
+
+	username, password, found := strings.Cut(string(b), ":")
+	if !found {
+		return "", "", fmt.Errorf("invalid auth for registry %q", registry)
+	}
+
+	return username, password, nil
+}
+
 func Validate(_ps string) error {
 	if _ps == "" {
 		_ps = "{}"
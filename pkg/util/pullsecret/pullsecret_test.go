@@ -373,3 +373,51 @@ func TestUnmarshalSecretData(t *testing.T) {
 		})
 	}
 }
+
+func TestCredentials(t *testing.T) {
+	ps := `{"auths":{"quay.io":{"auth":"ZnJlZDplbnRlcg=="},"broken.io":{"auth":"ZnJlZA=="}}}`
+
+	for _, tt := range []struct {
+		name         string
+		ps           string
+		registry     string
+		wantUsername string
+		wantPassword string
+		wantErr      string
+	}{
+		{
+			name:         "found",
+			ps:           ps,
+			registry:     "quay.io",
+			wantUsername: "fred",
+			wantPassword: "enter",
+		},
+		{
+			name:     "not found",
+			ps:       ps,
+			registry: "registry.redhat.io",
+		},
+		{
+			name:     "empty pull secret",
+			registry: "quay.io",
+		},
+		{
+			name:     "invalid auth",
+			ps:       ps,
+			registry: "broken.io",
+			wantErr:  `invalid auth for registry "broken.io"`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			username, password, err := Credentials(tt.ps, tt.registry)
+			if err != nil && err.Error() != tt.wantErr ||
+				err == nil && tt.wantErr != "" {
+				t.Fatal(err)
+			}
+
+			if username != tt.wantUsername || password != tt.wantPassword {
+				t.Error(username, password)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package release
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/util/pullsecret"
+	"github.com/openshift/installer-aro-wrapper/pkg/util/version"
+)
+
+const (
+	// releaseLabel is the label of the release image config holding the
+	// OpenShift version of the release
+	releaseLabel = "io.openshift.release"
+
+	mediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+// Version returns the OpenShift version of the digest-pinned release image
+// pullSpec, read from the config of the image in its registry using the
+// credentials in pullSecret.  The manifest and config are checked against
+// their digests, so the version is as trustworthy as the digest of pullSpec.
+func Version(ctx context.Context, cli *http.Client, pullSpec, pullSecret string) (*version.Version, error) {
+	ref, err := canonicalReference(pullSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	username, password, err := pullsecret.Credentials(pullSecret, reference.Domain(ref))
+	if err != nil {
+		return nil, err
+	}
+
+	r := &registry{
+		cli:      cli,
+		host:     reference.Domain(ref),
+		path:     reference.Path(ref),
+		username: username,
+		password: password,
+	}
+
+	manifest, err := r.manifest(ctx, ref.Digest())
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := r.get(ctx, "blobs", manifest.Config.Digest, manifest.Config.MediaType)
+	if err != nil {
+		return nil, err
+	}
+
+	var config ocispec.Image
+	err = json.Unmarshal(b, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	v, found := config.Config.Labels[releaseLabel]
+	if !found {
+		return nil, fmt.Errorf("release image %q has no %s label", pullSpec, releaseLabel)
+	}
+
+	return version.ParseVersion(v)
+}
+
+// registry reads the manifests and blobs of a repository using the registry
+// v2 API
+type registry struct {
+	cli      *http.Client
+	host     string
+	path     string
+	username string
+	password string
+
+	token string
+}
+
+// manifest returns the image manifest with digest d.  A manifest list is
+// resolved to its linux/amd64 manifest.
+func (r *registry) manifest(ctx context.Context, d digest.Digest) (*ocispec.Manifest, error) {
+	b, err := r.get(ctx, "manifests", d, strings.Join([]string{
+		mediaTypeDockerManifest,
+		mediaTypeDockerManifestList,
+		ocispec.MediaTypeImageManifest,
+		ocispec.MediaTypeImageIndex,
+	}, ", "))
+	if err != nil {
+		return nil, err
+	}
+
+	var index ocispec.Index
+	err = json.Unmarshal(b, &index)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(index.Manifests) > 0 {
+		for _, desc := range index.Manifests {
+			if desc.Platform != nil && desc.Platform.OS == "linux" && desc.Platform.Architecture == "amd64" {
+				return r.manifest(ctx, desc.Digest)
+			}
+		}
+
+		return nil, fmt.Errorf("manifest list %s has no linux/amd64 manifest", d)
+	}
+
+	var m ocispec.Manifest
+	err = json.Unmarshal(b, &m)
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// get returns the manifest or blob with digest d, having checked it against d
+func (r *registry) get(ctx context.Context, kind string, d digest.Digest, accept string) ([]byte, error) {
+	err := d.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	u := "https://" + r.host + "/v2/" + r.path + "/" + kind + "/" + d.String()
+
+	resp, err := r.do(ctx, u, accept)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized && r.token == "" {
+		resp.Body.Close()
+
+		err = r.authenticate(ctx, resp.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err = r.do(ctx, u, accept)
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d getting %s", resp.StatusCode, u)
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.Algorithm().FromBytes(b) != d {
+		return nil, fmt.Errorf("content of %s does not match its digest", u)
+	}
+
+	return b, nil
+}
+
+func (r *registry) do(ctx context.Context, u, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", accept)
+
+	switch {
+	case r.token != "":
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	case r.username != "":
+		req.SetBasicAuth(r.username, r.password)
+	}
+
+	return r.cli.Do(req)
+}
+
+// authenticate gets a bearer token for the repository as challenged by the
+// registry in the WWW-Authenticate header
+func (r *registry) authenticate(ctx context.Context, challenge string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return fmt.Errorf("unsupported authentication challenge %q from %s", challenge, r.host)
+	}
+
+	p := map[string]string{}
+	for _, param := range strings.Split(params, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+		p[k] = strings.Trim(v, `"`)
+	}
+
+	u, err := url.Parse(p["realm"])
+	if err != nil {
+		return err
+	}
+
+	q := u.Query()
+	q.Set("service", p["service"])
+	q.Set("scope", "repository:"+r.path+":pull")
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	if r.username != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+
+	resp, err := r.cli.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d getting a token from %s", resp.StatusCode, u.Host)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&token)
+	if err != nil {
+		return err
+	}
+
+	r.token = token.Token
+	if r.token == "" {
+		r.token = token.AccessToken
+	}
+	if r.token == "" {
+		return fmt.Errorf("no token received from %s", u.Host)
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package release
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestVersion(t *testing.T) {
+	ctx := context.Background()
+
+	blobs := map[digest.Digest][]byte{}
+	add := func(v interface{}) digest.Digest {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d := digest.FromBytes(b)
+		blobs[d] = b
+		return d
+	}
+
+	image := func(labels map[string]string) digest.Digest {
+		return add(&ocispec.Manifest{
+			MediaType: ocispec.MediaTypeImageManifest,
+			Config: ocispec.Descriptor{
+				MediaType: ocispec.MediaTypeImageConfig,
+				Digest: add(&ocispec.Image{
+					Config: ocispec.ImageConfig{Labels: labels},
+				}),
+			},
+		})
+	}
+
+	release := image(map[string]string{"io.openshift.release": "4.14.16"})
+	unlabelled := image(nil)
+	list := add(&ocispec.Index{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{
+			{
+				Digest:   unlabelled,
+				Platform: &ocispec.Platform{OS: "linux", Architecture: "arm64"},
+			},
+			{
+				Digest:   release,
+				Platform: &ocispec.Platform{OS: "linux", Architecture: "amd64"},
+			},
+		},
+	})
+
+	// the registry serves different content for the tampered digest
+	tampered := digest.FromString("tampered")
+	blobs[tampered] = blobs[release]
+
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			username, password, _ := r.BasicAuth()
+			if username != "fred" || password != "enter" ||
+				r.URL.Query().Get("scope") != "repository:openshift-release-dev/ocp-release:pull" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			_, _ = w.Write([]byte(`{"token": "token"}`))
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		i := strings.LastIndexByte(r.URL.Path, '/')
+		b, found := blobs[digest.Digest(r.URL.Path[i+1:])]
+		if !strings.HasPrefix(r.URL.Path, "/v2/openshift-release-dev/ocp-release/") || !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write(b)
+	}))
+	defer server.Close()
+
+	host := server.Listener.Addr().String()
+
+	for _, tt := range []struct {
+		name       string
+		digest     digest.Digest
+		pullSecret string
+		want       string
+		wantErr    string
+	}{
+		{
+			name:   "manifest",
+			digest: release,
+			want:   "4.14.16",
+		},
+		{
+			name:   "manifest list",
+			digest: list,
+			want:   "4.14.16",
+		},
+		{
+			name:    "no release label",
+			digest:  unlabelled,
+			wantErr: `release image "` + host + `/openshift-release-dev/ocp-release@` + unlabelled.String() + `" has no io.openshift.release label`,
+		},
+		{
+			name:    "content does not match the digest",
+			digest:  tampered,
+			wantErr: "content of https://" + host + "/v2/openshift-release-dev/ocp-release/manifests/" + tampered.String() + " does not match its digest",
+		},
+		{
+			name:       "invalid credentials",
+			digest:     release,
+			pullSecret: `{"auths":{"` + host + `":{"auth":"ZnJlZDp3cm9uZw=="}}}`, // fred:wrong
+			wantErr:    "unexpected status code 401 getting a token from " + host,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pullSecret := tt.pullSecret
+			if pullSecret == "" {
+				// fred:enter
+				pullSecret = `{"auths":{"` + host + `":{"auth":"ZnJlZDplbnRlcg=="}}}`
+			}
+
+			v, err := Version(ctx, server.Client(), host+"/openshift-release-dev/ocp-release@"+tt.digest.String(), pullSecret)
+			if err != nil && err.Error() != tt.wantErr ||
+				err == nil && tt.wantErr != "" {
+				t.Fatal(err)
+			}
+
+			if err == nil && v.String() != tt.want {
+				t.Error(v)
+			}
+		})
+	}
+}
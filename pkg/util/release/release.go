@@ -0,0 +1,50 @@
+package release
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"fmt"
+
+	"github.com/containers/image/v5/docker/reference"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/util/version"
+)
+
+// Stream returns the stream of the digest-pinned release image pullSpec.  The
+// digest and repository path of pullSpec must match one of streams; the
+// registry host may differ, so that mirrors of the streams are accepted.
+func Stream(pullSpec string, streams []*version.Stream) (*version.Stream, error) {
+	ref, err := canonicalReference(pullSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range streams {
+		streamRef, err := canonicalReference(s.PullSpec)
+		if err != nil {
+			return nil, err
+		}
+
+		if ref.Digest() == streamRef.Digest() &&
+			reference.Path(ref) == reference.Path(streamRef) {
+			return s, nil
+		}
+	}
+
+	return nil, fmt.Errorf("release image %q is not in the list of supported releases", pullSpec)
+}
+
+func canonicalReference(pullSpec string) (reference.Canonical, error) {
+	ref, err := reference.ParseNormalizedNamed(pullSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	canonical, ok := ref.(reference.Canonical)
+	if !ok {
+		return nil, fmt.Errorf("release image %q is not pinned by digest", pullSpec)
+	}
+
+	return canonical, nil
+}
@@ -0,0 +1,66 @@
+package release
+
+// Copyright (c) Microsoft Corporation.
+// Licensed under the Apache License 2.0.
+
+import (
+	"testing"
+
+	"github.com/openshift/installer-aro-wrapper/pkg/util/version"
+)
+
+const (
+	releaseDigest = "sha256:97410a5db655a9d3017b735c2c0747c849d09ff551765e49d5272b80c024a844"
+	pullSpec      = "quay.io/openshift-release-dev/ocp-release@" + releaseDigest
+)
+
+func TestStream(t *testing.T) {
+	streams := []*version.Stream{
+		{
+			Version:  version.NewVersion(4, 11, 16),
+			PullSpec: pullSpec,
+		},
+	}
+
+	for _, tt := range []struct {
+		name     string
+		pullSpec string
+		wantErr  string
+	}{
+		{
+			name:     "supported",
+			pullSpec: pullSpec,
+		},
+		{
+			name:     "supported from a mirror",
+			pullSpec: "arointsvc.azurecr.io/openshift-release-dev/ocp-release@" + releaseDigest,
+		},
+		{
+			name:     "not pinned by digest",
+			pullSpec: "quay.io/openshift-release-dev/ocp-release:4.11.16-x86_64",
+			wantErr:  `release image "quay.io/openshift-release-dev/ocp-release:4.11.16-x86_64" is not pinned by digest`,
+		},
+		{
+			name:     "unsupported repository",
+			pullSpec: "quay.io/openshift-release-dev/ocp-release-nightly@" + releaseDigest,
+			wantErr:  `release image "quay.io/openshift-release-dev/ocp-release-nightly@` + releaseDigest + `" is not in the list of supported releases`,
+		},
+		{
+			name:     "unsupported digest",
+			pullSpec: "quay.io/openshift-release-dev/ocp-release@sha256:b89ada9261a1b257012469e90d7d4839d0d2f99654f5ce76394fa3f06522b600",
+			wantErr:  `release image "quay.io/openshift-release-dev/ocp-release@sha256:b89ada9261a1b257012469e90d7d4839d0d2f99654f5ce76394fa3f06522b600" is not in the list of supported releases`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Stream(tt.pullSpec, streams)
+			if err != nil && err.Error() != tt.wantErr ||
+				err == nil && tt.wantErr != "" {
+				t.Fatal(err)
+			}
+
+			if err == nil && s != streams[0] {
+				t.Errorf("got stream %v", s)
+			}
+		})
+	}
+}
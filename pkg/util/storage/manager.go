@@ -12,6 +12,7 @@ import (
 	azstorage "github.com/Azure/azure-sdk-for-go/storage"
 	"github.com/Azure/go-autorest/autorest"
 	"github.com/Azure/go-autorest/autorest/date"
+	"github.com/Azure/go-autorest/autorest/to"
 
 	"github.com/openshift/installer-aro-wrapper/pkg/env"
 	"github.com/openshift/installer-aro-wrapper/pkg/util/azureclient/mgmt/storage"
@@ -19,6 +20,7 @@ import (
 
 type Manager interface {
 	BlobService(ctx context.Context, resourceGroup, account string, p mgmtstorage.Permissions, r mgmtstorage.SignedResourceTypes) (*azstorage.BlobStorageClient, error)
+	Blob(ctx context.Context, resourceGroup, account, container, blob string, p mgmtstorage.Permissions) (*azstorage.Blob, error)
 }
 
 type manager struct {
@@ -56,3 +58,29 @@ func (m *manager) BlobService(ctx context.Context, resourceGroup, account string
 
 	return &blobcli, nil
 }
+
+// Blob returns a reference to a single blob, authorized by a service SAS which
+// grants p on that blob only
+func (m *manager) Blob(ctx context.Context, resourceGroup, account, container, blob string, p mgmtstorage.Permissions) (*azstorage.Blob, error) {
+	t := time.Now().UTC().Truncate(time.Second)
+	res, err := m.storageAccounts.ListServiceSAS(ctx, resourceGroup, account, mgmtstorage.ServiceSasParameters{
+		CanonicalizedResource:  to.StringPtr("/blob/" + account + "/" + container + "/" + blob),
+		Resource:               mgmtstorage.SignedResourceB,
+		Permissions:            p,
+		Protocols:              mgmtstorage.HTTPS,
+		SharedAccessStartTime:  &date.Time{Time: t},
+		SharedAccessExpiryTime: &date.Time{Time: t.Add(time.Hour)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := url.ParseQuery(*res.ServiceSasToken)
+	if err != nil {
+		return nil, err
+	}
+
+	blobcli := azstorage.NewAccountSASClient(account, v, (*m.env.Environment()).Environment).GetBlobService()
+
+	return blobcli.GetContainerReference(container).GetBlobReference(blob), nil
+}
@@ -3,6 +3,11 @@ package version
 // Copyright (c) Microsoft Corporation.
 // Licensed under the Apache License 2.0.
 
+import (
+	"encoding/json"
+	"os"
+)
+
 type Stream struct {
 	Version  *Version `json:"version"`
 	PullSpec string   `json:"-"`
@@ -48,3 +53,36 @@ func getStream(streams []*Stream, v *Version) *Stream {
 
 	return nil
 }
+
+// StreamsFromFile reads a list of streams from a JSON file of the form
+// [{"version": "4.11.16", "pullSpec": "quay.io/...@sha256:..."}]
+func StreamsFromFile(path string) ([]*Stream, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []struct {
+		Version  string `json:"version"`
+		PullSpec string `json:"pullSpec"`
+	}
+	err = json.Unmarshal(b, &entries)
+	if err != nil {
+		return nil, err
+	}
+
+	streams := make([]*Stream, 0, len(entries))
+	for _, e := range entries {
+		v, err := ParseVersion(e.Version)
+		if err != nil {
+			return nil, err
+		}
+
+		streams = append(streams, &Stream{
+			Version:  v,
+			PullSpec: e.PullSpec,
+		})
+	}
+
+	return streams, nil
+}